@@ -1,10 +1,24 @@
 package godatabend
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
 )
 
 // AccessTokenLoader is used on Bearer authentication. The token may have a limited
@@ -57,3 +71,304 @@ func (l *FileAccessTokenLoader) LoadAccessToken(ctx context.Context, forceRotate
 
 	return content, nil
 }
+
+// OAuth2AccessTokenLoader obtains the access token with the OAuth2 client credentials
+// flow, the token is cached and refreshed before it expires.
+type OAuth2AccessTokenLoader struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// EndpointParams are the additional parameters sent to the token endpoint, like audience.
+	EndpointParams url.Values
+	// RefreshBefore is how long before the expiry the token is refreshed, default to 1 minute.
+	RefreshBefore time.Duration
+	HTTPClient    *http.Client
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+type oauth2TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+func NewOAuth2AccessTokenLoader(tokenURL, clientID, clientSecret string, scopes ...string) *OAuth2AccessTokenLoader {
+	return &OAuth2AccessTokenLoader{
+		TokenURL:      tokenURL,
+		ClientID:      clientID,
+		ClientSecret:  clientSecret,
+		Scopes:        scopes,
+		RefreshBefore: time.Minute,
+	}
+}
+
+func (l *OAuth2AccessTokenLoader) LoadAccessToken(ctx context.Context, forceRotate bool) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !forceRotate && l.token != "" && (l.expiry.IsZero() || time.Now().Add(l.RefreshBefore).Before(l.expiry)) {
+		return l.token, nil
+	}
+	resp, err := l.fetchToken(ctx)
+	if err != nil {
+		return "", err
+	}
+	l.token = resp.AccessToken
+	l.expiry = time.Time{}
+	if resp.ExpiresIn > 0 {
+		l.expiry = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	}
+	return l.token, nil
+}
+
+func (l *OAuth2AccessTokenLoader) fetchToken(ctx context.Context) (*oauth2TokenResponse, error) {
+	form := url.Values{}
+	for k, v := range l.EndpointParams {
+		form[k] = v
+	}
+	form.Set("grant_type", "client_credentials")
+	if len(l.Scopes) > 0 {
+		form.Set("scope", strings.Join(l.Scopes, " "))
+	}
+	req, err := http.NewRequest("POST", l.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create token request")
+	}
+	req = req.WithContext(ctx)
+	req.SetBasicAuth(url.QueryEscape(l.ClientID), url.QueryEscape(l.ClientSecret))
+	req.Header.Set(contentType, "application/x-www-form-urlencoded")
+	req.Header.Set(accept, "application/json")
+
+	httpClient := l.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+	httpResp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to request oauth2 token")
+	}
+	defer httpResp.Body.Close()
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read oauth2 token response")
+	}
+	if httpResp.StatusCode >= 400 {
+		return nil, NewAPIError("failed to request oauth2 token.", httpResp.StatusCode, body)
+	}
+	resp := &oauth2TokenResponse{}
+	if err = json.Unmarshal(body, resp); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal oauth2 token response")
+	}
+	if resp.AccessToken == "" {
+		return nil, errors.New("no access_token in oauth2 token response")
+	}
+	return resp, nil
+}
+
+// CachedAccessTokenLoader caches the token of the wrapped loader until it is about to
+// expire. The expiry is taken from the exp claim if the token is a JWT, otherwise the
+// token is considered valid for TTL. When a token is requested within RefreshBefore of
+// its expiry, the cached token is returned and a new one is loaded in the background,
+// so the requests only wait for the wrapped loader when the token has expired.
+type CachedAccessTokenLoader struct {
+	Loader        AccessTokenLoader
+	TTL           time.Duration
+	RefreshBefore time.Duration
+
+	mu         sync.Mutex
+	token      string
+	expiry     time.Time
+	refreshing bool
+}
+
+func NewCachedAccessTokenLoader(loader AccessTokenLoader, ttl time.Duration) *CachedAccessTokenLoader {
+	return &CachedAccessTokenLoader{
+		Loader:        loader,
+		TTL:           ttl,
+		RefreshBefore: 5 * time.Minute,
+	}
+}
+
+func (l *CachedAccessTokenLoader) LoadAccessToken(ctx context.Context, forceRotate bool) (string, error) {
+	l.mu.Lock()
+	now := time.Now()
+	if forceRotate || l.token == "" || !now.Before(l.expiry) {
+		defer l.mu.Unlock()
+		token, err := l.Loader.LoadAccessToken(ctx, forceRotate)
+		if err != nil {
+			return "", err
+		}
+		l.store(token)
+		return token, nil
+	}
+	token := l.token
+	if !l.refreshing && !now.Add(l.RefreshBefore).Before(l.expiry) {
+		l.refreshing = true
+		go l.refresh()
+	}
+	l.mu.Unlock()
+	return token, nil
+}
+
+// refresh loads a new token without l.mu held, so the requests keep using the cached
+// token meanwhile.
+func (l *CachedAccessTokenLoader) refresh() {
+	ctx := context.Background()
+	token, err := l.Loader.LoadAccessToken(ctx, true)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refreshing = false
+	if err != nil {
+		logger.WithContext(ctx).Warnf("refresh access token in background failed: %v", err)
+		return
+	}
+	l.store(token)
+}
+
+// store must be called with l.mu held.
+func (l *CachedAccessTokenLoader) store(token string) {
+	l.token = token
+	if exp, ok := parseJWTExpiry(token); ok {
+		l.expiry = exp
+	} else {
+		l.expiry = time.Now().Add(l.TTL)
+	}
+}
+
+// parseJWTExpiry returns the exp claim of a JWT without verifying it.
+func parseJWTExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+	buf, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+	claims := struct {
+		Exp int64 `json:"exp"`
+	}{}
+	if err = json.Unmarshal(buf, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(claims.Exp, 0), true
+}
+
+// CommandAccessTokenLoader obtains the access token by running an external command,
+// the stdout of the command is used as the token, it can be either the plain token
+// or a JSON object with an access_token or token field. The token is kept until the
+// rotation is forced.
+type CommandAccessTokenLoader struct {
+	Command string
+
+	mu    sync.Mutex
+	token string
+}
+
+// NewCommandAccessTokenLoader creates a loader running the command with the system
+// shell, like NewCommandAccessTokenLoader("get-token --env prod").
+func NewCommandAccessTokenLoader(command string) *CommandAccessTokenLoader {
+	return &CommandAccessTokenLoader{
+		Command: command,
+	}
+}
+
+func (l *CommandAccessTokenLoader) LoadAccessToken(ctx context.Context, forceRotate bool) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.token != "" && !forceRotate {
+		return l.token, nil
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", l.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", l.Command)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to run access token command: %s", strings.TrimSpace(stderr.String()))
+	}
+	token := strings.TrimSpace(string(out))
+	data := struct {
+		AccessToken string `json:"access_token"`
+		Token       string `json:"token"`
+	}{}
+	if err = json.Unmarshal([]byte(token), &data); err == nil {
+		token = data.AccessToken
+		if token == "" {
+			token = data.Token
+		}
+	}
+	if token == "" {
+		return "", errors.New("access token command returns an empty token")
+	}
+	l.token = token
+	return l.token, nil
+}
+
+// EnvAccessTokenLoader reads the access token from an environment variable.
+type EnvAccessTokenLoader struct {
+	Name string
+}
+
+func NewEnvAccessTokenLoader(name string) *EnvAccessTokenLoader {
+	return &EnvAccessTokenLoader{
+		Name: name,
+	}
+}
+
+func (l *EnvAccessTokenLoader) LoadAccessToken(ctx context.Context, forceRotate bool) (string, error) {
+	token := strings.TrimSpace(os.Getenv(l.Name))
+	if token == "" {
+		return "", errors.Errorf("environment variable %s is not set", l.Name)
+	}
+	return token, nil
+}
+
+// ChainAccessTokenLoader tries the loaders in order and sticks to the first one which
+// returns a token. The loaders are tried again when the chosen one fails.
+type ChainAccessTokenLoader struct {
+	Loaders []AccessTokenLoader
+
+	mu      sync.Mutex
+	current AccessTokenLoader
+}
+
+func NewChainAccessTokenLoader(loaders ...AccessTokenLoader) *ChainAccessTokenLoader {
+	return &ChainAccessTokenLoader{
+		Loaders: loaders,
+	}
+}
+
+func (l *ChainAccessTokenLoader) LoadAccessToken(ctx context.Context, forceRotate bool) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.current != nil {
+		token, err := l.current.LoadAccessToken(ctx, forceRotate)
+		if err == nil && token != "" {
+			return token, nil
+		}
+		l.current = nil
+	}
+	var errs []string
+	for _, loader := range l.Loaders {
+		token, err := loader.LoadAccessToken(ctx, forceRotate)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if token == "" {
+			errs = append(errs, fmt.Sprintf("%T returns an empty token", loader))
+			continue
+		}
+		l.current = loader
+		return token, nil
+	}
+	return "", errors.Errorf("no access token loader succeeded: %s", strings.Join(errs, "; "))
+}
@@ -0,0 +1,182 @@
+package godatabend
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOAuth2AccessTokenLoader(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		require.NoError(t, r.ParseForm())
+		user, password, _ := r.BasicAuth()
+		assert.Equal(t, "id1", user)
+		assert.Equal(t, "secret1", password)
+		assert.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+		assert.Equal(t, "a b", r.PostForm.Get("scope"))
+		assert.Equal(t, "databend", r.PostForm.Get("audience"))
+		_, _ = fmt.Fprintf(w, `{"access_token":"token%d","token_type":"Bearer","expires_in":3600}`, requests)
+	}))
+	defer ts.Close()
+
+	l := NewOAuth2AccessTokenLoader(ts.URL, "id1", "secret1", "a", "b")
+	l.EndpointParams = map[string][]string{"audience": {"databend"}}
+
+	token, err := l.LoadAccessToken(context.Background(), false)
+	require.NoError(t, err)
+	assert.Equal(t, "token1", token)
+
+	token, err = l.LoadAccessToken(context.Background(), false)
+	require.NoError(t, err)
+	assert.Equal(t, "token1", token)
+
+	token, err = l.LoadAccessToken(context.Background(), true)
+	require.NoError(t, err)
+	assert.Equal(t, "token2", token)
+
+	l.RefreshBefore = 2 * time.Hour
+	token, err = l.LoadAccessToken(context.Background(), false)
+	require.NoError(t, err)
+	assert.Equal(t, "token3", token)
+}
+
+type countingTokenLoader struct {
+	mu    sync.Mutex
+	count int
+}
+
+func (l *countingTokenLoader) LoadAccessToken(ctx context.Context, forceRotate bool) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.count++
+	return fmt.Sprintf("token%d", l.count), nil
+}
+
+func TestCachedAccessTokenLoader(t *testing.T) {
+	inner := &countingTokenLoader{}
+	l := NewCachedAccessTokenLoader(inner, time.Hour)
+	token, err := l.LoadAccessToken(context.Background(), false)
+	require.NoError(t, err)
+	assert.Equal(t, "token1", token)
+	token, err = l.LoadAccessToken(context.Background(), false)
+	require.NoError(t, err)
+	assert.Equal(t, "token1", token)
+
+	token, err = l.LoadAccessToken(context.Background(), true)
+	require.NoError(t, err)
+	assert.Equal(t, "token2", token)
+
+	// within the refresh window, the cached token is returned and refreshed in background
+	l.RefreshBefore = 2 * time.Hour
+	token, err = l.LoadAccessToken(context.Background(), false)
+	require.NoError(t, err)
+	assert.Equal(t, "token2", token)
+	assert.Eventually(t, func() bool {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		return l.token == "token3"
+	}, time.Second, 10*time.Millisecond)
+}
+
+type blockingTokenLoader struct {
+	release chan struct{}
+}
+
+func (l *blockingTokenLoader) LoadAccessToken(ctx context.Context, forceRotate bool) (string, error) {
+	if forceRotate {
+		<-l.release
+		return "token2", nil
+	}
+	return "token1", nil
+}
+
+func TestCachedAccessTokenLoaderRefreshNotBlocking(t *testing.T) {
+	inner := &blockingTokenLoader{release: make(chan struct{})}
+	l := NewCachedAccessTokenLoader(inner, time.Hour)
+	l.RefreshBefore = 2 * time.Hour
+	token, err := l.LoadAccessToken(context.Background(), false)
+	require.NoError(t, err)
+	assert.Equal(t, "token1", token)
+
+	// the refresh is blocked, the cached token is still returned
+	for i := 0; i < 3; i++ {
+		token, err = l.LoadAccessToken(context.Background(), false)
+		require.NoError(t, err)
+		assert.Equal(t, "token1", token)
+	}
+	close(inner.release)
+	assert.Eventually(t, func() bool {
+		token, _ := l.LoadAccessToken(context.Background(), false)
+		return token == "token2"
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestParseJWTExpiry(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+	claims := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d}`, exp.Unix())))
+	got, ok := parseJWTExpiry("e30." + claims + ".sig")
+	assert.True(t, ok)
+	assert.True(t, exp.Equal(got))
+
+	_, ok = parseJWTExpiry("plain-token")
+	assert.False(t, ok)
+}
+
+func TestCommandAccessTokenLoader(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh is required")
+	}
+	l := NewCommandAccessTokenLoader("echo ' abc '")
+	token, err := l.LoadAccessToken(context.Background(), false)
+	require.NoError(t, err)
+	assert.Equal(t, "abc", token)
+
+	l = NewCommandAccessTokenLoader(`echo '{"access_token":"xyz","expires_in":10}'`)
+	token, err = l.LoadAccessToken(context.Background(), false)
+	require.NoError(t, err)
+	assert.Equal(t, "xyz", token)
+
+	l = NewCommandAccessTokenLoader("echo oops >&2; exit 1")
+	_, err = l.LoadAccessToken(context.Background(), false)
+	assert.ErrorContains(t, err, "oops")
+}
+
+func TestChainAccessTokenLoader(t *testing.T) {
+	t.Setenv("TEST_DATABEND_TOKEN", "env-token")
+	l := NewChainAccessTokenLoader(
+		NewFileAccessTokenLoader(filepath.Join(t.TempDir(), "missing")),
+		NewEnvAccessTokenLoader("TEST_DATABEND_TOKEN"),
+		NewStaticAccessTokenLoader("static-token"),
+	)
+	token, err := l.LoadAccessToken(context.Background(), false)
+	require.NoError(t, err)
+	assert.Equal(t, "env-token", token)
+
+	t.Setenv("TEST_DATABEND_TOKEN", "")
+	token, err = l.LoadAccessToken(context.Background(), false)
+	require.NoError(t, err)
+	assert.Equal(t, "static-token", token)
+
+	l = NewChainAccessTokenLoader(NewEnvAccessTokenLoader("TEST_DATABEND_TOKEN"))
+	_, err = l.LoadAccessToken(context.Background(), false)
+	assert.ErrorContains(t, err, "TEST_DATABEND_TOKEN is not set")
+
+	cfg := NewConfig()
+	cfg.AccessTokenFile = "/path/to/token"
+	cfg.AccessToken = "abc"
+	assert.IsType(t, &ChainAccessTokenLoader{}, initAccessTokenLoader(cfg))
+	cfg.AccessTokenFile = ""
+	assert.IsType(t, &StaticAccessTokenLoader{}, initAccessTokenLoader(cfg))
+}
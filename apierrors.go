@@ -18,7 +18,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
+	"net/http"
+	"strconv"
 	"strings"
+	"time"
 )
 
 var (
@@ -28,6 +32,78 @@ var (
 	ErrReadResponse = errors.New("ReadResponseFailed")
 )
 
+// The common server errors, the ServerErrors match them with errors.Is by the
+// error codes or kinds.
+var (
+	ErrUnknownDatabase    = errors.New("databend: unknown database")
+	ErrUnknownTable       = errors.New("databend: unknown table")
+	ErrSyntax             = errors.New("databend: syntax error")
+	ErrPermissionDenied   = errors.New("databend: permission denied")
+	ErrWarehouseSuspended = errors.New("databend: warehouse suspended")
+)
+
+var serverErrorCodes = map[int]error{
+	1003: ErrUnknownDatabase,
+	1025: ErrUnknownTable,
+	1005: ErrSyntax,
+	1063: ErrPermissionDenied,
+}
+
+var serverErrorKinds = map[string]error{
+	"UnknownDatabase":    ErrUnknownDatabase,
+	"UnknownTable":       ErrUnknownTable,
+	"SyntaxException":    ErrSyntax,
+	"PermissionDenied":   ErrPermissionDenied,
+	"WarehouseSuspended": ErrWarehouseSuspended,
+}
+
+// isServerError tells whether e is the sentinel target.
+func isServerError(e ServerError, target error) bool {
+	if err, ok := serverErrorCodes[e.ErrorCode()]; ok && err == target {
+		return true
+	}
+	err, ok := serverErrorKinds[e.ErrorKind()]
+	return ok && err == target
+}
+
+func IsUnknownDatabase(err error) bool {
+	return errors.Is(err, ErrUnknownDatabase)
+}
+
+func IsUnknownTable(err error) bool {
+	return errors.Is(err, ErrUnknownTable)
+}
+
+func IsSyntaxError(err error) bool {
+	return errors.Is(err, ErrSyntax)
+}
+
+func IsPermissionDenied(err error) bool {
+	return errors.Is(err, ErrPermissionDenied) || IsAuthFailed(err)
+}
+
+func IsWarehouseSuspended(err error) bool {
+	return errors.Is(err, ErrWarehouseSuspended)
+}
+
+// ServerError is implemented by the errors returned by the server, use errors.As to
+// read them rather than matching the messages.
+type ServerError interface {
+	error
+	// ErrorCode returns the Databend error code, 0 if there is none.
+	ErrorCode() int
+	ErrorMessage() string
+	ErrorKind() string
+	HTTPStatus() int
+	// ErrorDetail returns the raw detail of the error.
+	ErrorDetail() string
+}
+
+var (
+	_ ServerError = APIError{}
+	_ ServerError = (*QueryError)(nil)
+)
+
 type APIErrorResponseBody struct {
 	Error   string `json:"error"`
 	Message string `json:"message"`
@@ -38,30 +114,137 @@ type APIError struct {
 	RespText   string
 	StatusCode int
 	Hint       string
+	// RetryAfter is the delay asked by the server with a Retry-After header on 429/503.
+	RetryAfter time.Duration
+	// QueryErr is the query error in the response body, like {"error": {"code": 1025, ...}}.
+	QueryErr *QueryError
+	// RequestID is the server side id of the request from the response headers, for
+	// the support tickets.
+	RequestID string
 }
 
-func (e APIError) Error() string {
-	message := e.RespBody.Message
-	if message == "" {
-		message = e.RespText
+func (e APIError) ErrorCode() int {
+	if e.QueryErr != nil {
+		return e.QueryErr.Code
+	}
+	return 0
+}
+
+func (e APIError) ErrorMessage() string {
+	if e.QueryErr != nil {
+		return e.QueryErr.Message
+	}
+	if e.RespBody.Message != "" {
+		return e.RespBody.Message
+	}
+	return e.RespText
+}
+
+// ErrorKind returns the kind of the query error, or the error name of the
+// response body like ProvisionWarehouseTimeout.
+func (e APIError) ErrorKind() string {
+	if e.QueryErr != nil {
+		return e.QueryErr.Kind
 	}
-	message = fmt.Sprintf("%d %s", e.StatusCode, message)
+	return e.RespBody.Error
+}
+
+func (e APIError) Is(target error) bool {
+	return isServerError(e, target)
+}
+
+func (e APIError) HTTPStatus() int {
+	return e.StatusCode
+}
+
+func (e APIError) ErrorDetail() string {
+	if e.QueryErr != nil && e.QueryErr.Detail != "" {
+		return e.QueryErr.Detail
+	}
+	return e.RespText
+}
+
+func (e APIError) Error() string {
+	message := fmt.Sprintf("%d %s", e.StatusCode, e.ErrorMessage())
 	if e.Hint != "" {
 		message = strings.Trim(message, ".")
 		message += ". " + e.Hint
 	}
+	if e.RequestID != "" {
+		message += fmt.Sprintf(" (request id: %s)", e.RequestID)
+	}
 	return message
 }
 
 func NewAPIError(hint string, status int, respBuf []byte) error {
 	respBody := APIErrorResponseBody{}
 	_ = json.Unmarshal(respBuf, &respBody)
+	var queryErr struct {
+		Error *QueryError `json:"error"`
+	}
+	if json.Unmarshal(respBuf, &queryErr) != nil {
+		queryErr.Error = nil
+	}
 	return APIError{
 		RespBody:   respBody,
 		RespText:   string(respBuf),
 		StatusCode: status,
 		Hint:       hint,
+		QueryErr:   queryErr.Error,
+	}
+}
+
+// requestIDHeaders are the headers which may carry the server side id of the
+// request, by the server or the gateways in front of it.
+var requestIDHeaders = []string{DatabendRequestIDHeader, "X-Request-Id", "X-Trace-Id"}
+
+func requestIDOf(header http.Header) string {
+	for _, name := range requestIDHeaders {
+		if id := header.Get(name); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// newResponseError is the APIError of the failed response.
+func newResponseError(hint string, resp *http.Response, respBuf []byte) APIError {
+	apiErr := NewAPIError(hint, resp.StatusCode, respBuf).(APIError)
+	apiErr.RequestID = requestIDOf(resp.Header)
+	return apiErr
+}
+
+// IsProvisionWarehouseTimeout reports whether the warehouse failed to resume in time.
+func IsProvisionWarehouseTimeout(err error) bool {
+	var serverErr ServerError
+	if errors.As(err, &serverErr) && serverErr.ErrorKind() == ProvisionWarehouseTimeout {
+		return true
 	}
+	return err != nil && strings.Contains(err.Error(), ProvisionWarehouseTimeout)
+}
+
+// connectError is the error of a request which failed to connect the server, so
+// the request has not been sent. It is also an ErrDoRequest.
+type connectError struct {
+	err error
+}
+
+func (e connectError) Error() string {
+	return e.err.Error()
+}
+
+func (e connectError) Unwrap() error {
+	return e.err
+}
+
+func (e connectError) Is(target error) bool {
+	return target == ErrDoRequest
+}
+
+// isConnectErr tells whether err is a failure to connect the server.
+func isConnectErr(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
 }
 
 func IsNotFound(err error) bool {
@@ -74,6 +257,13 @@ func IsProxyErr(err error) bool {
 	return errors.As(err, &apiErr) && apiErr.StatusCode == 520
 }
 
+// IsRetryLater reports whether the server asked to retry the request later (429/503).
+func IsRetryLater(err error) bool {
+	var apiErr APIError
+	return errors.As(err, &apiErr) &&
+		(apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode == http.StatusServiceUnavailable)
+}
+
 func IsAuthFailed(err error) bool {
 	var apiErr APIError
 	return errors.As(err, &apiErr) && apiErr.StatusCode == 401
@@ -86,3 +276,23 @@ func RespBody(err error) APIErrorResponseBody {
 	}
 	return apiErr.RespBody
 }
+
+// parseRetryAfter parses a Retry-After header in either delay-seconds or HTTP-date form.
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
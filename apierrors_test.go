@@ -0,0 +1,141 @@
+package godatabend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerError(t *testing.T) {
+	err := NewAPIError("please check your arguments.", 400, []byte(`{"error":{"code":1025,"message":"Unknown table 't'","detail":"at line 1"}}`))
+	var serverErr ServerError
+	require.True(t, errors.As(err, &serverErr))
+	assert.Equal(t, 1025, serverErr.ErrorCode())
+	assert.Equal(t, "Unknown table 't'", serverErr.ErrorMessage())
+	assert.Equal(t, 400, serverErr.HTTPStatus())
+	assert.Equal(t, "at line 1", serverErr.ErrorDetail())
+	assert.Equal(t, "400 Unknown table 't'. please check your arguments.", err.Error())
+
+	err = NewAPIError("please retry again later.", 503, []byte(`{"error":"ProvisionWarehouseTimeout","message":"timeout"}`))
+	require.True(t, errors.As(err, &serverErr))
+	assert.Equal(t, ProvisionWarehouseTimeout, serverErr.ErrorKind())
+	assert.Equal(t, "timeout", serverErr.ErrorMessage())
+	assert.True(t, IsProvisionWarehouseTimeout(err))
+}
+
+func TestQueryErrorAs(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"id":"q1","state":"Failed","error":{"code":1005,"message":"syntax error","kind":"SyntaxException"}}`))
+	}))
+	defer ts.Close()
+
+	cfg := NewConfig()
+	cfg.Host = ts.Listener.Addr().String()
+	cfg.SSLMode = SSL_MODE_DISABLE
+	cfg.User = "root"
+	dc, err := buildDatabendConn(context.Background(), *cfg)
+	require.NoError(t, err)
+	defer dc.Close()
+
+	_, err = dc.QueryContext(context.Background(), "SELEC 1", nil)
+	var serverErr ServerError
+	require.True(t, errors.As(err, &serverErr))
+	assert.Equal(t, 1005, serverErr.ErrorCode())
+	assert.Equal(t, "SyntaxException", serverErr.ErrorKind())
+	assert.Equal(t, http.StatusOK, serverErr.HTTPStatus())
+}
+
+func TestServerErrorSentinels(t *testing.T) {
+	err := fmt.Errorf("query has error: %w", &QueryError{Code: 1025, Message: "Unknown table 't'"})
+	assert.True(t, errors.Is(err, ErrUnknownTable))
+	assert.True(t, IsUnknownTable(err))
+	assert.False(t, IsUnknownDatabase(err))
+
+	err = NewAPIError("please check your arguments.", 400, []byte(`{"error":{"code":1005,"message":"syntax error"}}`))
+	assert.True(t, IsSyntaxError(err))
+	assert.False(t, IsPermissionDenied(err))
+	assert.True(t, IsPermissionDenied(&QueryError{Code: 1063}))
+	assert.True(t, IsWarehouseSuspended(NewAPIError("", 503, []byte(`{"error":"WarehouseSuspended"}`))))
+}
+
+func TestStatementError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"id":"q1","state":"Failed","error":{"code":1025,"message":"Unknown table 'users'"}}`))
+	}))
+	defer ts.Close()
+
+	c := APIClient{cli: &http.Client{}, apiEndpoint: ts.URL, user: "root", errorSQLLength: 30, errorRedactSQL: true}
+	err := c.QuerySync(context.Background(), "SELECT * FROM users WHERE name = 'alice' AND age > 30", nil, make(chan QueryResponse, 1))
+	var stmtErr *StatementError
+	require.True(t, errors.As(err, &stmtErr))
+	assert.Equal(t, "q1", stmtErr.QueryID)
+	assert.Equal(t, "SELECT * FROM users WHERE name...", stmtErr.SQL)
+	assert.NotContains(t, err.Error(), "alice")
+	assert.True(t, IsUnknownTable(err))
+
+	c.errorSQLLength = 0
+	err = c.QuerySync(context.Background(), "SELECT * FROM users", nil, make(chan QueryResponse, 1))
+	assert.Equal(t, "query sync failed: query error: code: 1025, message: Unknown table 'users' [query_id: q1]", err.Error())
+}
+
+func TestErrorRequestID(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/query" {
+			w.Header().Set(DatabendRequestIDHeader, "req-1")
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"code":1005,"message":"syntax error"}}`))
+			return
+		}
+		w.Header().Set("X-Request-Id", "req-2")
+		_, _ = w.Write([]byte(`{"id":"q1","state":"Failed","error":{"code":1025,"message":"Unknown table"}}`))
+	}))
+	defer ts.Close()
+
+	c := APIClient{cli: &http.Client{}, apiEndpoint: ts.URL, user: "root"}
+	err := c.QuerySync(context.Background(), "SELEC 1", nil, make(chan QueryResponse, 1))
+	var apiErr APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "req-1", apiErr.RequestID)
+	assert.Contains(t, err.Error(), "request id: req-1")
+
+	resp := &QueryResponse{ID: "q1", NextURI: "/v1/query/q1/page/1"}
+	ctx, tracker := c.beginQuery(context.Background(), "SELECT * FROM t")
+	tracker.started(resp)
+	p, err := c.QueryPage(ctx, resp.NextURI)
+	require.NoError(t, err)
+	var stmtErr *StatementError
+	require.True(t, errors.As(tracker.wrap(p.Error), &stmtErr))
+	assert.Equal(t, "req-2", stmtErr.RequestID)
+	assert.Contains(t, stmtErr.Error(), "request_id: req-2")
+}
+
+func TestPageError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/query":
+			_, _ = w.Write([]byte(`{"id":"q1","node_id":"n1","next_uri":"/v1/query/q1/page/1"}`))
+		case "/v1/query/q1/page/1":
+			_, _ = w.Write([]byte(`{"id":"q1","node_id":"n1","next_uri":"/v1/query/q1/page/2"}`))
+		default:
+			_, _ = w.Write([]byte(`{"id":"q1","node_id":"n2","error":{"code":1001,"message":"boom"}}`))
+		}
+	}))
+	defer ts.Close()
+
+	c := APIClient{cli: &http.Client{}, apiEndpoint: ts.URL, user: "root"}
+	err := c.QuerySync(context.Background(), "SELECT 1", nil, make(chan QueryResponse, 3))
+	var pageErr *PageError
+	require.True(t, errors.As(err, &pageErr))
+	assert.Equal(t, 2, pageErr.Page)
+	assert.Equal(t, "/v1/query/q1/page/2", pageErr.NextURI)
+	assert.Equal(t, "n2", pageErr.NodeID)
+	assert.Contains(t, err.Error(), "query page 2 (/v1/query/q1/page/2) on node n2 has error: code: 1001")
+	var queryErr *QueryError
+	assert.True(t, errors.As(err, &queryErr))
+}
@@ -0,0 +1,321 @@
+// Package arrow reads the results of the queries of the native Client as the Arrow
+// records, it's kept out of the driver so the users of database/sql do not depend on
+// Arrow.
+package arrow
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/apache/arrow/go/v12/arrow"
+	arrowarray "github.com/apache/arrow/go/v12/arrow/array"
+	"github.com/apache/arrow/go/v12/arrow/decimal128"
+	"github.com/apache/arrow/go/v12/arrow/memory"
+
+	godatabend "github.com/datafuselabs/databend-go"
+)
+
+var reflectTypeBigRat = reflect.TypeOf((*big.Rat)(nil))
+
+// RecordReader reads the results of QueryArrow as the Arrow records, a record per
+// page, so they could be handed to the DataFrame libraries:
+//
+//	reader, err := arrow.QueryArrow(ctx, client, "SELECT * FROM t")
+//	if err != nil {
+//		return err
+//	}
+//	defer reader.Release()
+//	for reader.Next() {
+//		record := reader.Record()
+//		...
+//	}
+//	return reader.Err()
+//
+// The record is released by the next call of Next, Retain it to keep it. The types
+// without an Arrow counterpart, like Tuple, Map and Variant, are the strings of the
+// values.
+type RecordReader struct {
+	refs    int64
+	pages   *godatabend.Pages
+	schema  *arrow.Schema
+	columns []arrowColumn
+	mem     memory.Allocator
+	record  arrow.Record
+	err     error
+}
+
+var _ arrowarray.RecordReader = (*RecordReader)(nil)
+
+// arrowColumn converts the cells of a column, raw columns append the text as is.
+type arrowColumn struct {
+	field  arrow.Field
+	parser godatabend.DataParser
+	raw    bool
+}
+
+// QueryArrow runs the query of the client and returns its results as the Arrow
+// records, the values are parsed like typed_values is set.
+func QueryArrow(ctx context.Context, c *godatabend.Client, query string, args ...interface{}) (*RecordReader, error) {
+	pages, err := c.QueryPages(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return NewRecordReader(pages)
+}
+
+// NewRecordReader reads the pages as the Arrow records, the pages are closed with the
+// reader.
+func NewRecordReader(pages *godatabend.Pages) (*RecordReader, error) {
+	r := &RecordReader{refs: 1, pages: pages, mem: memory.DefaultAllocator}
+	fields := make([]arrow.Field, len(pages.Schema()))
+	for i, f := range pages.Schema() {
+		col, err := newArrowColumn(f)
+		if err != nil {
+			_ = pages.Close()
+			return nil, err
+		}
+		r.columns = append(r.columns, col)
+		fields[i] = col.field
+	}
+	r.schema = arrow.NewSchema(fields, nil)
+	return r, nil
+}
+
+func newArrowColumn(f godatabend.Field) (arrowColumn, error) {
+	col := arrowColumn{field: arrow.Field{Name: f.Name, Nullable: f.Nullable}}
+	dt, ok := arrowType(f.Type)
+	if !ok {
+		col.field.Type = arrow.BinaryTypes.String
+		col.raw = true
+		return col, nil
+	}
+	col.field.Type = dt
+	desc := f.Type
+	if f.Nullable {
+		desc = &godatabend.TypeDesc{Name: "Nullable", Args: []*godatabend.TypeDesc{f.Type}}
+	}
+	parser, err := godatabend.NewDataParser(desc, &godatabend.DataParserOptions{TypedValues: true})
+	if err != nil {
+		return col, fmt.Errorf("failed to create a data parser for %s: %w", f.Name, err)
+	}
+	col.parser = parser
+	return col, nil
+}
+
+// arrowType returns the Arrow type of the values of t.
+func arrowType(t *godatabend.TypeDesc) (arrow.DataType, bool) {
+	switch t.Name {
+	case "Boolean":
+		return arrow.FixedWidthTypes.Boolean, true
+	case "Int8":
+		return arrow.PrimitiveTypes.Int8, true
+	case "Int16":
+		return arrow.PrimitiveTypes.Int16, true
+	case "Int32":
+		return arrow.PrimitiveTypes.Int32, true
+	case "Int64":
+		return arrow.PrimitiveTypes.Int64, true
+	case "UInt8":
+		return arrow.PrimitiveTypes.Uint8, true
+	case "UInt16":
+		return arrow.PrimitiveTypes.Uint16, true
+	case "UInt32":
+		return arrow.PrimitiveTypes.Uint32, true
+	case "UInt64":
+		return arrow.PrimitiveTypes.Uint64, true
+	case "Float32":
+		return arrow.PrimitiveTypes.Float32, true
+	case "Float64":
+		return arrow.PrimitiveTypes.Float64, true
+	case "String":
+		return arrow.BinaryTypes.String, true
+	case "Date":
+		return arrow.FixedWidthTypes.Date32, true
+	case "Timestamp":
+		return &arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: "UTC"}, true
+	case "Decimal":
+		if len(t.Args) != 2 {
+			return nil, false
+		}
+		precision, err1 := strconv.Atoi(t.Args[0].Name)
+		scale, err2 := strconv.Atoi(t.Args[1].Name)
+		if err1 != nil || err2 != nil || precision > 38 {
+			return nil, false
+		}
+		return &arrow.Decimal128Type{Precision: int32(precision), Scale: int32(scale)}, true
+	case "Array":
+		if len(t.Args) != 1 {
+			return nil, false
+		}
+		dt, ok := arrowType(godatabend.NewField("", t.Args[0]).Type)
+		if !ok {
+			return nil, false
+		}
+		return arrow.ListOf(dt), true
+	}
+	return nil, false
+}
+
+// Retain increases the reference count of the reader.
+func (r *RecordReader) Retain() {
+	atomic.AddInt64(&r.refs, 1)
+}
+
+// Release decreases the reference count of the reader, the query ends once it's 0.
+func (r *RecordReader) Release() {
+	if atomic.AddInt64(&r.refs, -1) == 0 {
+		if r.record != nil {
+			r.record.Release()
+			r.record = nil
+		}
+		_ = r.pages.Close()
+	}
+}
+
+// Schema returns the Arrow schema of the records.
+func (r *RecordReader) Schema() *arrow.Schema {
+	return r.schema
+}
+
+// Next converts the next page to the record, it returns false after the last page
+// or an error, which is returned by Err.
+func (r *RecordReader) Next() bool {
+	if r.record != nil {
+		r.record.Release()
+		r.record = nil
+	}
+	if r.err != nil || !r.pages.Next() {
+		if r.err == nil {
+			r.err = r.pages.Err()
+		}
+		return false
+	}
+	record, err := r.convert(r.pages.Page())
+	if err != nil {
+		r.err = err
+		_ = r.pages.Close()
+		return false
+	}
+	r.record = record
+	return true
+}
+
+// Record returns the current record.
+func (r *RecordReader) Record() arrow.Record {
+	return r.record
+}
+
+// Err returns the error met by Next.
+func (r *RecordReader) Err() error {
+	return r.err
+}
+
+// QueryID returns the id of the query assigned by the server.
+func (r *RecordReader) QueryID() string {
+	return r.pages.QueryID()
+}
+
+func (r *RecordReader) convert(page *godatabend.ResultPage) (arrow.Record, error) {
+	b := arrowarray.NewRecordBuilder(r.mem, r.schema)
+	defer b.Release()
+	for j, row := range page.Data {
+		for i, col := range r.columns {
+			if i >= len(row) {
+				return nil, fmt.Errorf("databend: %d cells in a row of %d columns", len(row), len(r.columns))
+			}
+			if page.IsNull(j, i) {
+				b.Field(i).AppendNull()
+				continue
+			}
+			if col.raw {
+				b.Field(i).(*arrowarray.StringBuilder).Append(row[i])
+				continue
+			}
+			v, err := col.parser.Parse(strings.NewReader(row[i]))
+			if err != nil {
+				return nil, fmt.Errorf("databend: failed to parse %s: %w", col.field.Name, err)
+			}
+			if err := appendArrow(b.Field(i), v); err != nil {
+				return nil, fmt.Errorf("databend: failed to convert %s: %w", col.field.Name, err)
+			}
+		}
+	}
+	return b.NewRecord(), nil
+}
+
+// appendArrow appends the value parsed by the typed parsers to the builder.
+func appendArrow(b arrowarray.Builder, v interface{}) error {
+	if v == nil {
+		b.AppendNull()
+		return nil
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.Type() != reflectTypeBigRat {
+		if rv.IsNil() {
+			b.AppendNull()
+			return nil
+		}
+		v = rv.Elem().Interface()
+	}
+	switch b := b.(type) {
+	case *arrowarray.BooleanBuilder:
+		b.Append(v.(bool))
+	case *arrowarray.Int8Builder:
+		b.Append(v.(int8))
+	case *arrowarray.Int16Builder:
+		b.Append(v.(int16))
+	case *arrowarray.Int32Builder:
+		b.Append(v.(int32))
+	case *arrowarray.Int64Builder:
+		b.Append(v.(int64))
+	case *arrowarray.Uint8Builder:
+		b.Append(v.(uint8))
+	case *arrowarray.Uint16Builder:
+		b.Append(v.(uint16))
+	case *arrowarray.Uint32Builder:
+		b.Append(v.(uint32))
+	case *arrowarray.Uint64Builder:
+		b.Append(v.(uint64))
+	case *arrowarray.Float32Builder:
+		b.Append(v.(float32))
+	case *arrowarray.Float64Builder:
+		b.Append(v.(float64))
+	case *arrowarray.StringBuilder:
+		b.Append(v.(string))
+	case *arrowarray.Date32Builder:
+		b.Append(arrow.Date32FromTime(v.(time.Time)))
+	case *arrowarray.TimestampBuilder:
+		b.Append(arrow.Timestamp(v.(time.Time).UnixMicro()))
+	case *arrowarray.Decimal128Builder:
+		n, err := decimalToArrow(v.(*big.Rat), b.Type().(*arrow.Decimal128Type).Scale)
+		if err != nil {
+			return err
+		}
+		b.Append(n)
+	case *arrowarray.ListBuilder:
+		rv := reflect.ValueOf(v)
+		b.Append(true)
+		for i := 0; i < rv.Len(); i++ {
+			if err := appendArrow(b.ValueBuilder(), rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unexpected builder %T", b)
+	}
+	return nil
+}
+
+// decimalToArrow scales the decimal to the integer of Decimal128.
+func decimalToArrow(v *big.Rat, scale int32) (decimal128.Num, error) {
+	scaled := new(big.Rat).Mul(v, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)))
+	if !scaled.IsInt() {
+		return decimal128.Num{}, fmt.Errorf("decimal %s has more digits than the scale %d", v.FloatString(int(scale)+1), scale)
+	}
+	return decimal128.FromBigInt(scaled.Num()), nil
+}
@@ -0,0 +1,73 @@
+package arrow
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow/go/v12/arrow"
+	arrowarray "github.com/apache/arrow/go/v12/arrow/array"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	godatabend "github.com/datafuselabs/databend-go"
+)
+
+func TestQueryArrow(t *testing.T) {
+	pages := map[string]string{
+		"/v1/query": `{"id":"q1","state":"Running","schema":[` +
+			`{"name":"i","type":"Int64"},{"name":"s","type":"Nullable(String)"},{"name":"d","type":"Decimal(10, 2)"},` +
+			`{"name":"a","type":"Array(Nullable(Int32))"},{"name":"t","type":"Tuple(Int32, String)"}],` +
+			`"data":[["1","x","1.50","[1,NULL]","(1,'a')"],["2",null,"-2.00","[]","(2,'b')"]],"next_uri":"/v1/query/q1/page/1"}`,
+		"/v1/query/q1/page/1": `{"id":"q1","state":"Succeeded","data":[["3","NULL","0.01","[3]","(3,'c')"]]}`,
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(pages[r.URL.Path]))
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	cfg, err := godatabend.ParseDSN("databend://root:root@" + strings.TrimPrefix(ts.URL, "http://") + "/default?sslmode=disable")
+	require.NoError(t, err)
+	client, err := godatabend.Open(ctx, cfg)
+	require.NoError(t, err)
+	defer client.Close()
+
+	reader, err := QueryArrow(ctx, client, "SELECT * FROM t")
+	require.NoError(t, err)
+	defer reader.Release()
+
+	schema := reader.Schema()
+	assert.Equal(t, arrow.PrimitiveTypes.Int64, schema.Field(0).Type)
+	assert.True(t, schema.Field(1).Nullable)
+	assert.Equal(t, &arrow.Decimal128Type{Precision: 10, Scale: 2}, schema.Field(2).Type)
+	assert.Equal(t, arrow.ListOf(arrow.PrimitiveTypes.Int32), schema.Field(3).Type)
+	assert.Equal(t, arrow.BinaryTypes.String, schema.Field(4).Type)
+
+	require.True(t, reader.Next())
+	record := reader.Record()
+	assert.Equal(t, int64(2), record.NumRows())
+	assert.Equal(t, []int64{1, 2}, record.Column(0).(*arrowarray.Int64).Int64Values())
+	s := record.Column(1).(*arrowarray.String)
+	assert.Equal(t, "x", s.Value(0))
+	assert.True(t, s.IsNull(1))
+	d := record.Column(2).(*arrowarray.Decimal128)
+	assert.Equal(t, uint64(150), d.Value(0).LowBits())
+	assert.Equal(t, "-200", d.Value(1).BigInt().String())
+	list := record.Column(3).(*arrowarray.List)
+	values := list.ListValues().(*arrowarray.Int32)
+	assert.Equal(t, 2, values.Len())
+	assert.True(t, values.IsNull(1))
+	assert.Equal(t, "(1,'a')", record.Column(4).(*arrowarray.String).Value(0))
+
+	require.True(t, reader.Next())
+	record = reader.Record()
+	assert.Equal(t, int64(1), record.NumRows())
+	// the text NULL is a string, only the null of the response is NULL
+	assert.Equal(t, "NULL", record.Column(1).(*arrowarray.String).Value(0))
+	assert.False(t, record.Column(4).(*arrowarray.String).IsNull(0))
+	assert.False(t, reader.Next())
+	assert.NoError(t, reader.Err())
+}
@@ -0,0 +1,75 @@
+package godatabend
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// LOAD_BALANCE_FAILOVER sends the new queries to the first healthy endpoint.
+	LOAD_BALANCE_FAILOVER = "failover"
+	// LOAD_BALANCE_ROUND_ROBIN rotates the endpoints for the new queries.
+	LOAD_BALANCE_ROUND_ROBIN = "round_robin"
+	// LOAD_BALANCE_LEAST_OUTSTANDING sends the new queries to the endpoint with the
+	// fewest requests in flight.
+	LOAD_BALANCE_LEAST_OUTSTANDING = "least_outstanding"
+)
+
+// loadBalancers are shared by the clients of the same endpoints, so the queries of
+// all the connections in the pool are balanced.
+var loadBalancers sync.Map
+
+// loadBalancer picks the endpoint of the new queries, the following requests of a
+// query are routed to the endpoint which runs it by the pinned URIs.
+type loadBalancer struct {
+	policy      string
+	endpoints   []string
+	next        uint64
+	outstanding []int64
+}
+
+func getLoadBalancer(cfg *Config, endpoints []string) *loadBalancer {
+	if len(endpoints) < 2 || cfg.LoadBalance == "" || cfg.LoadBalance == LOAD_BALANCE_FAILOVER {
+		return nil
+	}
+	key := cfg.LoadBalance + "|" + strings.Join(endpoints, ",")
+	b, _ := loadBalancers.LoadOrStore(key, &loadBalancer{
+		policy:      cfg.LoadBalance,
+		endpoints:   endpoints,
+		outstanding: make([]int64, len(endpoints)),
+	})
+	return b.(*loadBalancer)
+}
+
+// pick returns the index of the endpoint for a new query.
+func (b *loadBalancer) pick() int {
+	if b.policy == LOAD_BALANCE_LEAST_OUTSTANDING {
+		start := int(atomic.AddUint64(&b.next, 1)) % len(b.endpoints)
+		best := start
+		for i := 1; i < len(b.endpoints); i++ {
+			idx := (start + i) % len(b.endpoints)
+			if atomic.LoadInt64(&b.outstanding[idx]) < atomic.LoadInt64(&b.outstanding[best]) {
+				best = idx
+			}
+		}
+		return best
+	}
+	return int(atomic.AddUint64(&b.next, 1)-1) % len(b.endpoints)
+}
+
+// acquire counts a request in flight to the endpoint until the returned func is called.
+func (b *loadBalancer) acquire(endpoint string) func() {
+	if b == nil {
+		return func() {}
+	}
+	for i, e := range b.endpoints {
+		if e == endpoint {
+			atomic.AddInt64(&b.outstanding[i], 1)
+			return func() {
+				atomic.AddInt64(&b.outstanding[i], -1)
+			}
+		}
+	}
+	return func() {}
+}
@@ -0,0 +1,111 @@
+package godatabend
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// Statement is a statement of ExecBatch with its args.
+type Statement struct {
+	SQL  string
+	Args []driver.Value
+}
+
+// BatchOptions are the options of ExecBatch.
+type BatchOptions struct {
+	// ContinueOnError runs the rest of the statements after a statement fails.
+	ContinueOnError bool
+}
+
+// BatchResult is the result of a statement of ExecBatch, Result is nil if the
+// statement fails or is not run.
+type BatchResult struct {
+	Result *QueryResult
+	Err    error
+}
+
+// BatchError is the error of the first failed statement of ExecBatch.
+type BatchError struct {
+	// Index is the index of the statement in the batch.
+	Index int
+	SQL   string
+	// Failed is the number of the failed statements.
+	Failed int
+	Err    error
+}
+
+func (e *BatchError) Error() string {
+	if e.Failed > 1 {
+		return fmt.Sprintf("statement %d of the batch failed: %v (and %d more)", e.Index, e.Err, e.Failed-1)
+	}
+	return fmt.Sprintf("statement %d of the batch failed: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// ExecBatch runs the statements one by one in the session of the client, so the
+// temporary tables, the settings and the transactions are kept between them, like
+// the migrations and the ETL scripts. It stops at the first failed statement
+// unless ContinueOnError is set, and returns the results of all the statements
+// with a *BatchError of the first failed statement. It stops once ctx is done, and
+// returns ctx.Err() if no statement failed, the statements not run have neither
+// the result nor the error.
+func (c *APIClient) ExecBatch(ctx context.Context, stmts []Statement, opts *BatchOptions) ([]BatchResult, error) {
+	if opts == nil {
+		opts = &BatchOptions{}
+	}
+	results := make([]BatchResult, len(stmts))
+	var batchErr *BatchError
+	for i, stmt := range stmts {
+		if batchErr != nil && !opts.ContinueOnError {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			if batchErr == nil {
+				return results, err
+			}
+			break
+		}
+		// each statement is a query of its own id
+		queryCtx := context.WithValue(ctx, ContextKeyQueryID, uuid.NewString())
+		results[i].Result, results[i].Err = c.execStatement(queryCtx, stmt.SQL, stmt.Args)
+		if results[i].Err == nil {
+			continue
+		}
+		if batchErr == nil {
+			batchErr = &BatchError{Index: i, SQL: stmt.SQL, Err: results[i].Err}
+		}
+		batchErr.Failed++
+	}
+	if batchErr != nil {
+		return results, batchErr
+	}
+	return results, nil
+}
+
+// execStatement runs the statement and collects its result from all the pages.
+func (c *APIClient) execStatement(ctx context.Context, query string, args []driver.Value) (*QueryResult, error) {
+	respCh := make(chan QueryResponse)
+	errCh := make(chan error, 1)
+	go func() {
+		err := c.QuerySync(ctx, query, args, respCh)
+		close(respCh)
+		errCh <- err
+	}()
+	result := &QueryResult{}
+	for resp := range respCh {
+		result.queryID = resp.ID
+		result.stats = resp.Stats
+		result.warnings = appendWarnings(result.warnings, resp.Warnings)
+		result.addRows(&resp)
+	}
+	if err := <-errCh; err != nil {
+		return nil, err
+	}
+	return result, nil
+}
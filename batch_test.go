@@ -0,0 +1,104 @@
+package godatabend
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecBatch(t *testing.T) {
+	var sqls []string
+	queryIDs := map[string]bool{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req QueryRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		sqls = append(sqls, req.SQL)
+		queryIDs[r.Header.Get("X-DATABEND-QUERY-ID")] = true
+		if strings.HasPrefix(req.SQL, "BAD") {
+			_, _ = w.Write([]byte(`{"id":"q","state":"Failed","error":{"code":1005,"message":"syntax error"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"q","state":"Succeeded","schema":[{"name":"number of rows inserted","type":"UInt64"}],"data":[["1"]]}`))
+	}))
+	defer ts.Close()
+
+	cfg, err := ParseDSN("databend://root:root@" + strings.TrimPrefix(ts.URL, "http://") + "/default?sslmode=disable")
+	require.NoError(t, err)
+	c := NewAPIClientFromConfig(cfg)
+	stmts := []Statement{
+		{SQL: "INSERT INTO t VALUES (?)", Args: []driver.Value{1}},
+		{SQL: "BAD 1"},
+		{SQL: "INSERT INTO t VALUES (3)"},
+		{SQL: "BAD 2"},
+	}
+
+	results, err := c.ExecBatch(context.Background(), stmts, nil)
+	var batchErr *BatchError
+	require.True(t, errors.As(err, &batchErr))
+	assert.Equal(t, 1, batchErr.Index)
+	assert.Equal(t, 1, batchErr.Failed)
+	require.Len(t, results, 4)
+	affected, _ := results[0].Result.RowsAffected()
+	assert.Equal(t, int64(1), affected)
+	assert.Error(t, results[1].Err)
+	assert.Nil(t, results[2].Result)
+	assert.Equal(t, []string{"INSERT INTO t VALUES (1)", "BAD 1"}, sqls)
+
+	sqls = nil
+	results, err = c.ExecBatch(context.Background(), stmts, &BatchOptions{ContinueOnError: true})
+	require.True(t, errors.As(err, &batchErr))
+	assert.Equal(t, 1, batchErr.Index)
+	assert.Equal(t, 2, batchErr.Failed)
+	assert.NotNil(t, results[2].Result)
+	assert.Error(t, results[3].Err)
+	assert.Len(t, sqls, 4)
+	assert.Len(t, queryIDs, 6)
+}
+
+func TestExecBatchCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var sqls []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req QueryRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		sqls = append(sqls, req.SQL)
+		if req.SQL == "SLOW" {
+			cancel()
+			<-r.Context().Done()
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"q","state":"Succeeded","schema":[{"name":"number of rows inserted","type":"UInt64"}],"data":[["1"]]}`))
+	}))
+	defer ts.Close()
+
+	cfg, err := ParseDSN("databend://root:root@" + strings.TrimPrefix(ts.URL, "http://") + "/default?sslmode=disable")
+	require.NoError(t, err)
+	c := NewAPIClientFromConfig(cfg)
+	stmts := []Statement{{SQL: "INSERT INTO t VALUES (1)"}, {SQL: "SLOW"}, {SQL: "INSERT INTO t VALUES (3)"}, {SQL: "INSERT INTO t VALUES (4)"}}
+
+	// the statements after the cancel are not run nor failed
+	results, err := c.ExecBatch(ctx, stmts, &BatchOptions{ContinueOnError: true})
+	var batchErr *BatchError
+	require.True(t, errors.As(err, &batchErr))
+	assert.Equal(t, 1, batchErr.Index)
+	assert.Equal(t, 1, batchErr.Failed)
+	assert.NotNil(t, results[0].Result)
+	assert.Error(t, results[1].Err)
+	assert.Equal(t, BatchResult{}, results[2])
+	assert.Equal(t, BatchResult{}, results[3])
+	assert.Equal(t, []string{"INSERT INTO t VALUES (1)", "SLOW"}, sqls)
+
+	sqls = nil
+	results, err = c.ExecBatch(ctx, stmts, nil)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, make([]BatchResult, 4), results)
+	assert.Empty(t, sqls)
+}
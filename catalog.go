@@ -0,0 +1,266 @@
+package godatabend
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DatabaseInfo describes a database listed by ListDatabases.
+type DatabaseInfo struct {
+	Name string
+}
+
+// TableInfo describes a table listed by ListTables or DescribeTable.
+type TableInfo struct {
+	Database string
+	Name     string
+	Engine   string
+	Comment  string
+	// Rows is the number of rows kept by the engine, 0 for the views.
+	Rows uint64
+	// Columns is only filled by DescribeTable.
+	Columns []ColumnInfo
+}
+
+// ColumnInfo is a column of a table, its type is parsed like the results.
+type ColumnInfo struct {
+	Field
+	// Default is the default expression of the column, empty if there is none.
+	Default string
+	Comment string
+}
+
+// sqlTypeNames maps the type names shown by SHOW and DESCRIBE to the type names of
+// the results.
+var sqlTypeNames = map[string]string{
+	"BOOLEAN":           "Boolean",
+	"TINYINT":           "Int8",
+	"SMALLINT":          "Int16",
+	"INT":               "Int32",
+	"BIGINT":            "Int64",
+	"TINYINT UNSIGNED":  "UInt8",
+	"SMALLINT UNSIGNED": "UInt16",
+	"INT UNSIGNED":      "UInt32",
+	"BIGINT UNSIGNED":   "UInt64",
+	"FLOAT":             "Float32",
+	"DOUBLE":            "Float64",
+	"VARCHAR":           "String",
+	"STRING":            "String",
+	"BINARY":            "Binary",
+	"DATE":              "Date",
+	"TIMESTAMP":         "Timestamp",
+	"DECIMAL":           "Decimal",
+	"ARRAY":             "Array",
+	"MAP":               "Map",
+	"TUPLE":             "Tuple",
+	"VARIANT":           "Variant",
+	"BITMAP":            "Bitmap",
+	"GEOMETRY":          "Geometry",
+	"NULLABLE":          "Nullable",
+	"NOTHING":           "Nothing",
+}
+
+// ListDatabases lists the databases of the current catalog.
+func (c *APIClient) ListDatabases(ctx context.Context) ([]DatabaseInfo, error) {
+	resp, err := c.QuerySingle(ctx, "SHOW DATABASES", nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list databases")
+	}
+	databases := make([]DatabaseInfo, 0, len(resp.Data))
+	for _, row := range resp.Data {
+		if len(row) < 1 {
+			return nil, errors.Errorf("list databases invalid response: %+v", row)
+		}
+		databases = append(databases, DatabaseInfo{Name: row[0]})
+	}
+	return databases, nil
+}
+
+// ListTables lists the tables and the views of the database, or of the current
+// database if it's empty.
+func (c *APIClient) ListTables(ctx context.Context, database string) ([]TableInfo, error) {
+	return c.listTables(ctx, database, "")
+}
+
+// DescribeTable returns the table of the database, or of the current database if it's
+// empty, with its columns.
+func (c *APIClient) DescribeTable(ctx context.Context, database, table string) (*TableInfo, error) {
+	tables, err := c.listTables(ctx, database, table)
+	if err != nil {
+		return nil, err
+	}
+	var info *TableInfo
+	for i := range tables {
+		if tables[i].Name == table {
+			info = &tables[i]
+			break
+		}
+	}
+	if info == nil {
+		return nil, errors.Wrapf(ErrUnknownTable, "failed to describe table %s", table)
+	}
+	sql := "SHOW FULL COLUMNS FROM " + quoteIdent(table)
+	if database != "" {
+		sql += " FROM " + quoteIdent(database)
+	}
+	resp, err := c.QuerySingle(ctx, sql, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe table %s", table)
+	}
+	cols := newResultColumns(resp.Schema)
+	for _, row := range resp.Data {
+		name, typ := cols.get(row, "field"), cols.get(row, "type")
+		if name == "" || typ == "" {
+			return nil, errors.Errorf("describe table invalid response: %+v", row)
+		}
+		desc, err := ParseSQLType(typ)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse the type '%s' of %s", typ, name)
+		}
+		col := ColumnInfo{
+			Field:   NewField(name, desc),
+			Default: cols.get(row, "default"),
+			Comment: cols.get(row, "comment"),
+		}
+		if strings.EqualFold(cols.get(row, "null"), "YES") {
+			col.Nullable = true
+		}
+		if col.Default == "NULL" {
+			col.Default = ""
+		}
+		info.Columns = append(info.Columns, col)
+	}
+	return info, nil
+}
+
+// listTables lists the tables by SHOW TABLE STATUS, only the table if it's set.
+func (c *APIClient) listTables(ctx context.Context, database, table string) ([]TableInfo, error) {
+	sql := "SHOW TABLE STATUS"
+	if database != "" {
+		sql += " FROM " + quoteIdent(database)
+	}
+	if table != "" {
+		sql += " LIKE " + quote(escape(escapeLike(table)))
+	}
+	resp, err := c.QuerySingle(ctx, sql, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list tables")
+	}
+	cols := newResultColumns(resp.Schema)
+	tables := make([]TableInfo, 0, len(resp.Data))
+	for _, row := range resp.Data {
+		info := TableInfo{
+			Database: database,
+			Name:     cols.get(row, "name"),
+			Engine:   cols.get(row, "engine"),
+			Comment:  cols.get(row, "comment"),
+		}
+		if info.Name == "" {
+			return nil, errors.Errorf("list tables invalid response: %+v", row)
+		}
+		if rows := cols.get(row, "rows"); rows != "" && rows != "NULL" {
+			if info.Rows, err = strconv.ParseUint(rows, 10, 64); err != nil {
+				return nil, errors.Wrapf(err, "invalid rows of table %s", info.Name)
+			}
+		}
+		tables = append(tables, info)
+	}
+	return tables, nil
+}
+
+// resultColumns indexes the columns of the results by their lower case names, as the
+// columns of SHOW differ between the versions of the server.
+type resultColumns map[string]int
+
+func newResultColumns(schema []DataField) resultColumns {
+	cols := make(resultColumns, len(schema))
+	for i, field := range schema {
+		cols[strings.ToLower(field.Name)] = i
+	}
+	return cols
+}
+
+// get returns the cell of the column, empty if there is no such column.
+func (cols resultColumns) get(row []string, name string) string {
+	if i, ok := cols[name]; ok && i < len(row) {
+		return row[i]
+	}
+	return ""
+}
+
+// ParseSQLType parses the type shown by SHOW, DESCRIBE and the system tables, like
+// DECIMAL(10, 2) or ARRAY(INT NULL), to the type description of the results.
+func ParseSQLType(s string) (*TypeDesc, error) {
+	s = strings.TrimSpace(s)
+	upper := strings.ToUpper(s)
+	if strings.HasSuffix(upper, " NOT NULL") {
+		return ParseSQLType(s[:len(s)-len(" NOT NULL")])
+	}
+	if strings.HasSuffix(upper, " NULL") {
+		inner, err := ParseSQLType(s[:len(s)-len(" NULL")])
+		if err != nil {
+			return nil, err
+		}
+		return &TypeDesc{Name: "Nullable", Args: []*TypeDesc{inner}}, nil
+	}
+	name, args := s, ""
+	if i := strings.IndexByte(s, '('); i >= 0 {
+		if !strings.HasSuffix(s, ")") {
+			return nil, fmt.Errorf("unfinished type description '%s'", s)
+		}
+		name, args = strings.TrimSpace(s[:i]), s[i+1:len(s)-1]
+	}
+	if name == "" {
+		return nil, fmt.Errorf("missing type name in '%s'", s)
+	}
+	if mapped, ok := sqlTypeNames[strings.ToUpper(name)]; ok {
+		name = mapped
+	} else if i := strings.IndexByte(name, ' '); i >= 0 {
+		// the field of a named tuple, like a INT
+		return ParseSQLType(s[i+1:])
+	}
+	desc := &TypeDesc{Name: name}
+	for _, arg := range splitTypeArgs(args) {
+		argDesc, err := ParseSQLType(arg)
+		if err != nil {
+			return nil, err
+		}
+		desc.Args = append(desc.Args, argDesc)
+	}
+	return desc, nil
+}
+
+// splitTypeArgs splits the args of a type at the top level commas.
+func splitTypeArgs(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	var args []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				args = append(args, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(args, s[start:])
+}
+
+func quoteIdent(s string) string {
+	return "`" + strings.ReplaceAll(s, "`", "``") + "`"
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
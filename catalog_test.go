@@ -0,0 +1,84 @@
+package godatabend
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSQLType(t *testing.T) {
+	cases := map[string]string{
+		"BIGINT UNSIGNED":         "UInt64",
+		"VARCHAR NULL":            "Nullable(String)",
+		"DECIMAL(10, 2) NOT NULL": "Decimal(10, 2)",
+		"ARRAY(INT NULL)":         "Array(Nullable(Int32))",
+		"MAP(VARCHAR, TUPLE(a INT, b DECIMAL(3, 1)))": "Map(String, Tuple(Int32, Decimal(3, 1)))",
+		"Nullable(Int32)": "Nullable(Int32)",
+	}
+	for s, expected := range cases {
+		desc, err := ParseSQLType(s)
+		if assert.NoError(t, err, s) {
+			assert.Equal(t, expected, desc.String(), s)
+		}
+	}
+	_, err := ParseSQLType("DECIMAL(10, 2")
+	assert.Error(t, err)
+}
+
+func TestCatalog(t *testing.T) {
+	var sqls []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req QueryRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		sqls = append(sqls, req.SQL)
+		switch req.SQL {
+		case "SHOW DATABASES":
+			_, _ = w.Write([]byte(`{"id":"q1","state":"Succeeded","schema":[{"name":"databases_in_default","type":"String"}],"data":[["default"],["system"]]}`))
+		case "SHOW TABLE STATUS FROM `db`", "SHOW TABLE STATUS FROM `db` LIKE 't\\\\_1'":
+			_, _ = w.Write([]byte(`{"id":"q2","state":"Succeeded","schema":[{"name":"Name","type":"String"},{"name":"Engine","type":"String"},` +
+				`{"name":"Rows","type":"Nullable(UInt64)"},{"name":"Comment","type":"String"}],"data":[["t_1","FUSE","3","c1"],["v","VIEW",null,""]]}`))
+		case "SHOW TABLE STATUS FROM `db` LIKE 't\\\\_2'":
+			_, _ = w.Write([]byte(`{"id":"q4","state":"Succeeded","schema":[{"name":"Name","type":"String"}],"data":[]}`))
+		case "SHOW FULL COLUMNS FROM `t_1` FROM `db`":
+			_, _ = w.Write([]byte(`{"id":"q3","state":"Succeeded","schema":[{"name":"field","type":"String"},{"name":"type","type":"String"},` +
+				`{"name":"null","type":"String"},{"name":"default","type":"String"},{"name":"comment","type":"String"}],` +
+				`"data":[["a","BIGINT","NO","NULL","id"],["b","DECIMAL(10, 2)","YES","0.00",""]]}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer ts.Close()
+	c := APIClient{cli: &http.Client{}, apiEndpoint: ts.URL, user: "root"}
+	ctx := context.Background()
+
+	databases, err := c.ListDatabases(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []DatabaseInfo{{Name: "default"}, {Name: "system"}}, databases)
+
+	tables, err := c.ListTables(ctx, "db")
+	require.NoError(t, err)
+	assert.Equal(t, []TableInfo{
+		{Database: "db", Name: "t_1", Engine: "FUSE", Rows: 3, Comment: "c1"},
+		{Database: "db", Name: "v", Engine: "VIEW"},
+	}, tables)
+
+	table, err := c.DescribeTable(ctx, "db", "t_1")
+	require.NoError(t, err)
+	assert.Equal(t, "t_1", table.Name)
+	if assert.Len(t, table.Columns, 2) {
+		assert.Equal(t, "a", table.Columns[0].Name)
+		assert.Equal(t, "Int64", table.Columns[0].TypeName())
+		assert.Equal(t, "", table.Columns[0].Default)
+		assert.Equal(t, "id", table.Columns[0].Comment)
+		assert.Equal(t, "Nullable(Decimal(10, 2))", table.Columns[1].TypeName())
+		assert.Equal(t, "0.00", table.Columns[1].Default)
+	}
+
+	_, err = c.DescribeTable(ctx, "db", "t_2")
+	assert.True(t, IsUnknownTable(err))
+}
@@ -0,0 +1,132 @@
+package godatabend
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrCircuitOpen is returned without sending the request while the circuit breaker
+// of the endpoint is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+const defaultCircuitBreakerCooldown = 30 * time.Second
+
+// maxCircuitBreakers bounds circuitBreakers, the healthy breakers are dropped once
+// it's reached as they keep no state.
+var maxCircuitBreakers = 1024
+
+// circuitBreakers are shared by the clients of the same endpoint, so all the
+// connections in the pool fail fast together.
+var circuitBreakers = struct {
+	sync.Mutex
+	m map[string]*circuitBreaker
+}{m: map[string]*circuitBreaker{}}
+
+// circuitBreaker opens after threshold consecutive transport errors or 5xx
+// responses, and fails the requests fast until the cooldown passes. Then it's half
+// open, a single request is let through as the probe, it closes the breaker if it
+// succeeds, or opens it again if it fails. Another probe is let through if the probe
+// does not finish in the cooldown, like it's canceled by the caller.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu         sync.Mutex
+	failures   int
+	open       bool
+	openUntil  time.Time
+	probeUntil time.Time
+}
+
+// getCircuitBreakers returns the breakers of the endpoints, it's nil if the circuit
+// breaker is disabled.
+func getCircuitBreakers(cfg *Config, endpoints []string) map[string]*circuitBreaker {
+	if cfg.CircuitBreakerThreshold <= 0 {
+		return nil
+	}
+	cooldown := cfg.CircuitBreakerCooldown
+	if cooldown <= 0 {
+		cooldown = defaultCircuitBreakerCooldown
+	}
+	circuitBreakers.Lock()
+	defer circuitBreakers.Unlock()
+	breakers := make(map[string]*circuitBreaker, len(endpoints))
+	for _, endpoint := range endpoints {
+		key := fmt.Sprintf("%s|%d|%s", endpoint, cfg.CircuitBreakerThreshold, cooldown)
+		b, ok := circuitBreakers.m[key]
+		if !ok {
+			if len(circuitBreakers.m) >= maxCircuitBreakers {
+				pruneCircuitBreakers()
+			}
+			b = &circuitBreaker{threshold: cfg.CircuitBreakerThreshold, cooldown: cooldown}
+			circuitBreakers.m[key] = b
+		}
+		breakers[endpoint] = b
+	}
+	return breakers
+}
+
+// pruneCircuitBreakers drops the healthy breakers, the clients holding them keep
+// using them. It must be called with circuitBreakers locked.
+func pruneCircuitBreakers() {
+	for key, b := range circuitBreakers.m {
+		if b.healthy() {
+			delete(circuitBreakers.m, key)
+		}
+	}
+}
+
+// breakerOf returns the breaker of the endpoint of the url, nil if the url is not
+// of an endpoint, like a presigned url.
+func (c *APIClient) breakerOf(url string) *circuitBreaker {
+	if c.breakers == nil {
+		return nil
+	}
+	return c.breakers[c.endpointOf(url)]
+}
+
+func (b *circuitBreaker) healthy() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !b.open && b.failures == 0
+}
+
+func (b *circuitBreaker) allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.open {
+		return nil
+	}
+	now := time.Now()
+	if now.Before(b.openUntil) || now.Before(b.probeUntil) {
+		return ErrCircuitOpen
+	}
+	// half open, let this request through as the probe
+	b.probeUntil = now.Add(b.cooldown)
+	return nil
+}
+
+func (b *circuitBreaker) record(ok bool) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ok {
+		b.failures = 0
+		b.open = false
+		return
+	}
+	b.failures++
+	if b.open || b.failures >= b.threshold {
+		b.open = true
+		b.openUntil = time.Now().Add(b.cooldown)
+		b.probeUntil = time.Time{}
+	}
+}
@@ -0,0 +1,95 @@
+package godatabend
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	failing := true
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if failing {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte("{}"))
+	}))
+	defer ts.Close()
+
+	cfg, err := ParseDSN("databend://root:root@" + strings.TrimPrefix(ts.URL, "http://") + "/default?sslmode=disable&circuit_breaker_threshold=2&circuit_breaker_cooldown=100ms")
+	require.NoError(t, err)
+	cfg1, err := ParseDSN(cfg.FormatDSN())
+	require.NoError(t, err)
+	assert.Equal(t, cfg, cfg1)
+
+	c, err := NewAPIClient(cfg)
+	require.NoError(t, err)
+	c2, err := NewAPIClient(cfg)
+	require.NoError(t, err)
+	assert.Same(t, c.breakers[ts.URL], c2.breakers[ts.URL])
+
+	for i := 0; i < 2; i++ {
+		_, err = c.DoQuery(context.Background(), "SELECT 1", nil)
+		assert.Error(t, err)
+	}
+	_, err = c2.DoQuery(context.Background(), "SELECT 1", nil)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, 2, calls)
+
+	time.Sleep(150 * time.Millisecond)
+	failing = false
+	_, err = c.DoQuery(context.Background(), "SELECT 1", nil)
+	assert.NoError(t, err)
+	_, err = c2.DoQuery(context.Background(), "SELECT 1", nil)
+	assert.NoError(t, err)
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	b := &circuitBreaker{threshold: 2, cooldown: 50 * time.Millisecond}
+	b.record(false)
+	assert.NoError(t, b.allow())
+	b.record(false)
+	assert.ErrorIs(t, b.allow(), ErrCircuitOpen)
+
+	// a single probe after the cooldown
+	time.Sleep(60 * time.Millisecond)
+	assert.NoError(t, b.allow())
+	assert.ErrorIs(t, b.allow(), ErrCircuitOpen)
+	// the failed probe opens it again
+	b.record(false)
+	assert.ErrorIs(t, b.allow(), ErrCircuitOpen)
+
+	time.Sleep(60 * time.Millisecond)
+	assert.NoError(t, b.allow())
+	// the probe is lost, another one is let through after the cooldown
+	time.Sleep(60 * time.Millisecond)
+	assert.NoError(t, b.allow())
+	b.record(true)
+	assert.NoError(t, b.allow())
+	assert.NoError(t, b.allow())
+}
+
+func TestCircuitBreakersPruned(t *testing.T) {
+	defer func(n int) { maxCircuitBreakers = n }(maxCircuitBreakers)
+	maxCircuitBreakers = 2
+	cfg := &Config{CircuitBreakerThreshold: 1}
+	unhealthy := getCircuitBreakers(cfg, []string{"http://prune-a"})["http://prune-a"]
+	unhealthy.record(false)
+	for i := 0; i < 5; i++ {
+		getCircuitBreakers(cfg, []string{fmt.Sprintf("http://prune-%d", i)})
+	}
+	circuitBreakers.Lock()
+	defer circuitBreakers.Unlock()
+	assert.LessOrEqual(t, len(circuitBreakers.m), 2)
+	assert.Contains(t, circuitBreakers.m, "http://prune-a|1|"+defaultCircuitBreakerCooldown.String())
+}
@@ -0,0 +1,248 @@
+package godatabend
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// Client is the native client of a single session, it runs the queries like a
+// connection of database/sql without the pool, so the analytics workloads get the
+// query ids, the stats and the schema of the results directly:
+//
+//	client, err := godatabend.Open(ctx, cfg)
+//	if err != nil {
+//		return err
+//	}
+//	defer client.Close()
+//	rows, err := client.Query(ctx, "SELECT number, number * 2 FROM numbers(?)", 10)
+//	if err != nil {
+//		return err
+//	}
+//	defer rows.Close()
+//	for rows.Next() {
+//		var a, b uint64
+//		if err := rows.Scan(&a, &b); err != nil {
+//			return err
+//		}
+//	}
+//	return rows.Err()
+//
+// A Client is not safe for concurrent use, and only one Rows could be open at a time
+// like a connection, open a Client per goroutine instead.
+type Client struct {
+	dc *DatabendConn
+}
+
+// Open validates the config and opens a Client, ctx is only used to open it, like
+// resolving the secrets.
+func Open(ctx context.Context, cfg *Config) (*Client, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	dc, err := buildDatabendConn(ctx, *cfg)
+	if err != nil {
+		return nil, err
+	}
+	dc.ctx = context.Background()
+	dc.typedValues = cfg.TypedValues
+	return &Client{dc: dc}, nil
+}
+
+// APIClient returns the underlying APIClient for the stage uploads and the other
+// requests of the REST API.
+func (c *Client) APIClient() *APIClient {
+	return c.dc.rest
+}
+
+// Exec runs the statement and returns its result with the stats of the query, the
+// args are interpolated like the args of database/sql.
+func (c *Client) Exec(ctx context.Context, query string, args ...interface{}) (*QueryResult, error) {
+	nvs, err := c.namedValues(args)
+	if err != nil {
+		return nil, err
+	}
+	result, err := c.dc.ExecContext(ctx, query, nvs)
+	if err != nil {
+		return nil, err
+	}
+	if r, ok := result.(*QueryResult); ok {
+		return r, nil
+	}
+	return &QueryResult{}, nil
+}
+
+// Query runs the query and returns its rows, which must be closed before the next
+// query of the Client.
+func (c *Client) Query(ctx context.Context, query string, args ...interface{}) (*Rows, error) {
+	nvs, err := c.namedValues(args)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := c.dc.QueryContext(ctx, query, nvs)
+	if err != nil {
+		return nil, err
+	}
+	return &Rows{rows: rows.(*nextRows)}, nil
+}
+
+// Ping checks the server is reachable.
+func (c *Client) Ping(ctx context.Context) error {
+	return c.dc.Ping(ctx)
+}
+
+// Close closes the session on the server.
+func (c *Client) Close() error {
+	return c.dc.Close()
+}
+
+// namedValues converts the args like database/sql does before they reach the driver.
+func (c *Client) namedValues(args []interface{}) ([]driver.NamedValue, error) {
+	nvs := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		nvs[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+		if named, ok := arg.(sql.NamedArg); ok {
+			nvs[i].Name, nvs[i].Value = named.Name, named.Value
+		}
+		err := c.dc.CheckNamedValue(&nvs[i])
+		if err == driver.ErrSkip {
+			if nvs[i].Value, err = driver.DefaultParameterConverter.ConvertValue(nvs[i].Value); err != nil {
+				return nil, fmt.Errorf("databend: arg $%d: %w", i+1, err)
+			}
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return nvs, nil
+}
+
+// Rows is the iterator of the rows of Client.Query, the values are parsed by the
+// types of the columns like the rows of database/sql.
+type Rows struct {
+	rows   *nextRows
+	values []driver.Value
+	err    error
+	closed bool
+}
+
+// Next prepares the next row for Values and Scan, it returns false after the last
+// row or an error, which is returned by Err.
+func (r *Rows) Next() bool {
+	if r.closed {
+		return false
+	}
+	if r.values == nil {
+		r.values = make([]driver.Value, len(r.rows.columns))
+	}
+	if err := r.rows.Next(r.values); err != nil {
+		if err != io.EOF {
+			r.err = err
+		}
+		_ = r.Close()
+		return false
+	}
+	return true
+}
+
+// Err returns the error met by Next.
+func (r *Rows) Err() error {
+	return r.err
+}
+
+// Columns returns the names of the columns.
+func (r *Rows) Columns() []string {
+	return r.rows.columns
+}
+
+// Schema returns the columns with their types parsed.
+func (r *Rows) Schema() Schema {
+	return r.rows.schema
+}
+
+// QueryID returns the id of the query assigned by the server.
+func (r *Rows) QueryID() string {
+	return r.rows.QueryID()
+}
+
+// Warnings returns the warnings of the query received so far.
+func (r *Rows) Warnings() []string {
+	return r.rows.Warnings()
+}
+
+// Values returns the values of the current row, they are valid until the next call
+// of Next.
+func (r *Rows) Values() []interface{} {
+	values := make([]interface{}, len(r.values))
+	for i, v := range r.values {
+		values[i] = v
+	}
+	return values
+}
+
+// Scan copies the values of the current row into dest, which are the pointers to
+// the values assignable from the types of the columns, or the sql.Scanner.
+func (r *Rows) Scan(dest ...interface{}) error {
+	if len(dest) != len(r.values) {
+		return fmt.Errorf("databend: expected %d destination arguments in Scan, not %d", len(r.values), len(dest))
+	}
+	for i, d := range dest {
+		if err := scanValue(d, r.values[i]); err != nil {
+			return fmt.Errorf("databend: can not scan column %d %s: %w", i, r.rows.columns[i], err)
+		}
+	}
+	return nil
+}
+
+// Close ends the query, the rest of the rows are discarded.
+func (r *Rows) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	return r.rows.Close()
+}
+
+func scanValue(dest interface{}, value driver.Value) error {
+	if s, ok := dest.(sql.Scanner); ok {
+		return s.Scan(value)
+	}
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Ptr || dv.IsNil() {
+		return fmt.Errorf("destination %T is not a pointer", dest)
+	}
+	dv = dv.Elem()
+	if value == nil {
+		dv.Set(reflect.Zero(dv.Type()))
+		return nil
+	}
+	sv := reflect.ValueOf(value)
+	switch {
+	case sv.Type().AssignableTo(dv.Type()):
+		dv.Set(sv)
+	case sv.Kind() == reflect.String && dv.Kind() == reflect.String:
+		dv.Set(sv.Convert(dv.Type()))
+	case isNumberKind(sv.Kind()) && isNumberKind(dv.Kind()):
+		v := sv.Convert(dv.Type())
+		// like database/sql, the values are not truncated
+		if v.Convert(sv.Type()).Interface() != value {
+			return fmt.Errorf("%v overflows %s", value, dv.Type())
+		}
+		dv.Set(v)
+	default:
+		return fmt.Errorf("%T is not assignable to %s", value, dv.Type())
+	}
+	return nil
+}
+
+func isNumberKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
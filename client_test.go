@@ -0,0 +1,137 @@
+package godatabend
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient(t *testing.T) {
+	var sqls []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req QueryRequest
+		if json.NewDecoder(r.Body).Decode(&req) == nil {
+			sqls = append(sqls, req.SQL)
+		}
+		if strings.HasPrefix(req.SQL, "SELECT") {
+			_, _ = w.Write([]byte(`{"id":"q1","state":"Succeeded","schema":[{"name":"a","type":"Int64"},{"name":"s","type":"Nullable(String)"}],"data":[["1","x"],["300",null]]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"q2","state":"Succeeded","schema":[{"name":"number of rows inserted","type":"UInt64"}],"data":[["2"]]}`))
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	cfg, err := ParseDSN("databend://root:root@" + strings.TrimPrefix(ts.URL, "http://") + "/default?sslmode=disable")
+	require.NoError(t, err)
+	client, err := Open(ctx, cfg)
+	require.NoError(t, err)
+	defer client.Close()
+
+	result, err := client.Exec(ctx, "INSERT INTO t VALUES (?, ?)", 1, "x")
+	require.NoError(t, err)
+	affected, _ := result.RowsAffected()
+	assert.Equal(t, int64(2), affected)
+	assert.Equal(t, "q2", result.QueryID())
+
+	rows, err := client.Query(ctx, "SELECT a, s FROM t WHERE a > ?", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "s"}, rows.Columns())
+	assert.Equal(t, "q1", rows.QueryID())
+	require.True(t, rows.Next())
+	var a int32
+	var s string
+	require.NoError(t, rows.Scan(&a, &s))
+	assert.Equal(t, int32(1), a)
+	assert.Equal(t, "x", s)
+	require.True(t, rows.Next())
+	var small int8
+	assert.Error(t, rows.Scan(&small, &s))
+	assert.Equal(t, []interface{}{int64(300), ""}, rows.Values())
+	assert.False(t, rows.Next())
+	assert.NoError(t, rows.Err())
+	require.NoError(t, rows.Close())
+
+	assert.Equal(t, []string{"INSERT INTO t VALUES (1, 'x')", "SELECT a, s FROM t WHERE a > 0"}, sqls)
+
+	_, err = client.Exec(ctx, "INSERT INTO t VALUES (?)", make(chan int))
+	assert.Error(t, err)
+}
+
+func TestClientTypedValues(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req QueryRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.SQL == "SELECT n, s, d FROM t" {
+			_, _ = w.Write([]byte(`{"id":"q2","state":"Succeeded","schema":[` +
+				`{"name":"n","type":"Nullable(Int64)"},{"name":"s","type":"Nullable(String)"},{"name":"d","type":"Decimal(20, 2)"}],` +
+				`"data":[[null,"NULL","12345678901234567.89"],["7","x","-0.50"]]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"q1","state":"Succeeded","schema":[` +
+			`{"name":"n","type":"Nullable(Int64)"},{"name":"s","type":"Nullable(String)"},` +
+			`{"name":"d","type":"Decimal(20, 2)"},{"name":"a","type":"Array(Nullable(Int32))"}],` +
+			`"data":[[null,null,"12345678901234567.89","[1,NULL,3]"],["7","NULL","-0.50","[]"],["8","","0.00","[]"]]}`))
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	cfg, err := ParseDSN("databend://root:root@" + strings.TrimPrefix(ts.URL, "http://") + "/default?sslmode=disable&typed_values=true")
+	require.NoError(t, err)
+	client, err := Open(ctx, cfg)
+	require.NoError(t, err)
+	defer client.Close()
+
+	rows, err := client.Query(ctx, "SELECT * FROM t")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	require.True(t, rows.Next())
+	values := rows.Values()
+	assert.Nil(t, values[0])
+	assert.Nil(t, values[1])
+	assert.Equal(t, "1234567890123456789/100", values[2].(*big.Rat).String())
+	three := int32(3)
+	one := int32(1)
+	assert.Equal(t, []*int32{&one, nil, &three}, values[3])
+
+	require.True(t, rows.Next())
+	var n int64
+	var s string
+	var d *big.Rat
+	var a []*int32
+	require.NoError(t, rows.Scan(&n, &s, &d, &a))
+	assert.Equal(t, int64(7), n)
+	// the NULL is told by the null of the response, not by the text
+	assert.Equal(t, "NULL", s)
+	assert.Equal(t, "-1/2", d.String())
+	assert.Empty(t, a)
+	require.True(t, rows.Next())
+	assert.Equal(t, "", rows.Values()[1])
+	assert.False(t, rows.Next())
+	assert.NoError(t, rows.Err())
+
+	// the rows of database/sql keep the driver values
+	db, err := sql.Open("databend", "databend://root:root@"+strings.TrimPrefix(ts.URL, "http://")+"/default?sslmode=disable&typed_values=true")
+	require.NoError(t, err)
+	defer db.Close()
+	sqlRows, err := db.QueryContext(ctx, "SELECT n, s, d FROM t")
+	require.NoError(t, err)
+	defer sqlRows.Close()
+	var ns, ss, ds string
+	require.True(t, sqlRows.Next(), sqlRows.Err())
+	require.NoError(t, sqlRows.Scan(&ns, &ss, &ds))
+	assert.Equal(t, "12345678901234567.89", ds)
+	require.True(t, sqlRows.Next())
+	require.NoError(t, sqlRows.Scan(&ns, &ss, &ds))
+	assert.Equal(t, []string{"7", "x", "-0.50"}, []string{ns, ss, ds})
+	assert.NoError(t, sqlRows.Err())
+}
@@ -81,6 +81,11 @@ func (b *httpBatch) AppendToFile(v []driver.Value) error {
 
 	lineData := make([]string, 0, len(v))
 	for i := range v {
+		if b, ok := v[i].([]byte); ok {
+			// the encoded arrays, maps and tuples
+			lineData = append(lineData, string(b))
+			continue
+		}
 		lineData = append(lineData, fmt.Sprintf("%v", v[i]))
 	}
 	writer := csv.NewWriter(csvFile)
@@ -107,9 +112,6 @@ func (b *httpBatch) UploadToStage(ctx context.Context) (*StageLocation, error) {
 	}
 	defer f.Close()
 	input := bufio.NewReader(f)
-	stage := &StageLocation{
-		Name: "~",
-		Path: fmt.Sprintf("batch/%d-%s", time.Now().Unix(), filepath.Base(b.batchFile)),
-	}
+	stage := NewUserStageLocation(fmt.Sprintf("batch/%d-%s", time.Now().Unix(), filepath.Base(b.batchFile)))
 	return stage, b.conn.rest.UploadToStage(ctx, stage, input, size)
 }
@@ -0,0 +1,60 @@
+package godatabend
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIClientConcurrentQueries(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/v1/query/") {
+			id := strings.Split(r.URL.Path, "/")[3]
+			_, _ = fmt.Fprintf(w, `{"id":"%s","state":"Succeeded","data":[["2"]],"session":{"settings":{"max_threads":"4"}}}`, id)
+			return
+		}
+		id := r.Header.Get(DatabendQueryIDHeader)
+		_, _ = fmt.Fprintf(w, `{"id":"%s","state":"Running","schema":[{"name":"a","type":"Int64"}],"data":[["1"]],`+
+			`"next_uri":"/v1/query/%s/page/1","session":{"settings":{"max_threads":"4"}},"stats":{"write_progress":{"rows":1}}}`, id, id)
+	}))
+	defer ts.Close()
+
+	cfg, err := ParseDSN("databend://root:root@" + strings.TrimPrefix(ts.URL, "http://") + "," +
+		strings.TrimPrefix(ts.URL, "http://") + "/default?sslmode=disable&load_balance=round_robin")
+	require.NoError(t, err)
+	c := NewAPIClientFromConfig(cfg)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			queryID := fmt.Sprintf("q%d", i)
+			var rows []driver.Value
+			err := c.QueryStream(ctx, "SELECT a FROM t", nil, func(row []driver.Value) error {
+				rows = append(rows, row[0])
+				return nil
+			}, WithQueryID(queryID))
+			assert.NoError(t, err)
+			assert.Equal(t, []driver.Value{int64(1), int64(2)}, rows)
+
+			resp, err := c.QuerySingle(ctx, "SELECT a FROM t", nil, WithQueryID(queryID+"s"))
+			assert.NoError(t, err)
+			if assert.NotNil(t, resp) {
+				assert.Equal(t, queryID+"s", resp.ID)
+			}
+			_ = c.LastQueryID()
+			_ = c.SetSetting(ctx, "max_threads", "8")
+		}(i)
+	}
+	wg.Wait()
+}
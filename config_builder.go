@@ -0,0 +1,283 @@
+package godatabend
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// ConfigBuilder assembles a Config in code, each option is validated when it's set
+// and the first invalid one fails Build.
+//
+//	cfg, err := godatabend.NewConfigBuilder().
+//		Host("tenant--warehouse.gw.databend.com:443").
+//		User("root").Password("secret").
+//		Warehouse("wh").
+//		RetryPolicy(godatabend.Query, godatabend.RetryPolicy{Attempts: 3, Delay: time.Second}).
+//		Build()
+type ConfigBuilder struct {
+	cfg *Config
+	err error
+}
+
+// NewConfigBuilder starts with the defaults of NewConfig.
+func NewConfigBuilder() *ConfigBuilder {
+	return &ConfigBuilder{cfg: NewConfig()}
+}
+
+// set applies the option unless a previous one failed.
+func (b *ConfigBuilder) set(option string, fn func(cfg *Config) error) *ConfigBuilder {
+	if b.err != nil {
+		return b
+	}
+	if err := fn(b.cfg); err != nil {
+		b.err = fmt.Errorf("databend: invalid %s: %w", option, err)
+	}
+	return b
+}
+
+// Host sets the address of the gateway, or a comma separated list of them.
+func (b *ConfigBuilder) Host(host string) *ConfigBuilder {
+	return b.set("host", func(cfg *Config) error {
+		for _, h := range strings.Split(host, ",") {
+			h = strings.TrimSpace(h)
+			if h == "" {
+				return fmt.Errorf("empty host in '%s'", host)
+			}
+			if strings.Contains(h, ":") {
+				if _, _, err := net.SplitHostPort(h); err != nil {
+					return err
+				}
+			}
+		}
+		cfg.Host = host
+		return nil
+	})
+}
+
+func (b *ConfigBuilder) User(user string) *ConfigBuilder {
+	return b.set("user", func(cfg *Config) error {
+		if user == "" {
+			return fmt.Errorf("empty user")
+		}
+		cfg.User = user
+		return nil
+	})
+}
+
+func (b *ConfigBuilder) Password(password string) *ConfigBuilder {
+	return b.set("password", func(cfg *Config) error {
+		cfg.Password = password
+		return nil
+	})
+}
+
+func (b *ConfigBuilder) Database(database string) *ConfigBuilder {
+	return b.set("database", func(cfg *Config) error {
+		cfg.Database = database
+		return nil
+	})
+}
+
+func (b *ConfigBuilder) Tenant(tenant string) *ConfigBuilder {
+	return b.set("tenant", func(cfg *Config) error {
+		cfg.Tenant = tenant
+		return nil
+	})
+}
+
+func (b *ConfigBuilder) Warehouse(warehouse string) *ConfigBuilder {
+	return b.set("warehouse", func(cfg *Config) error {
+		cfg.Warehouse = warehouse
+		return nil
+	})
+}
+
+func (b *ConfigBuilder) Role(role string) *ConfigBuilder {
+	return b.set("role", func(cfg *Config) error {
+		cfg.Role = role
+		return nil
+	})
+}
+
+func (b *ConfigBuilder) AccessToken(token string) *ConfigBuilder {
+	return b.set("access token", func(cfg *Config) error {
+		if token == "" {
+			return fmt.Errorf("empty access token")
+		}
+		cfg.AccessToken = token
+		return nil
+	})
+}
+
+func (b *ConfigBuilder) AccessTokenFile(path string) *ConfigBuilder {
+	return b.set("access token file", func(cfg *Config) error {
+		if path == "" {
+			return fmt.Errorf("empty path")
+		}
+		cfg.AccessTokenFile = path
+		return nil
+	})
+}
+
+func (b *ConfigBuilder) AccessTokenLoader(loader AccessTokenLoader) *ConfigBuilder {
+	return b.set("access token loader", func(cfg *Config) error {
+		if loader == nil {
+			return fmt.Errorf("nil loader")
+		}
+		cfg.AccessTokenLoader = loader
+		return nil
+	})
+}
+
+// SSLMode sets one of the SSL_MODE_* modes.
+func (b *ConfigBuilder) SSLMode(mode string) *ConfigBuilder {
+	return b.set("sslmode", func(cfg *Config) error {
+		switch mode {
+		case SSL_MODE_DISABLE, SSL_MODE_ENABLE, SSL_MODE_VERIFY_FULL, SSL_MODE_VERIFY_CA, SSL_MODE_SKIP_VERIFY:
+			cfg.SSLMode = mode
+			return nil
+		}
+		return fmt.Errorf("unknown mode '%s'", mode)
+	})
+}
+
+func (b *ConfigBuilder) TLSConfig(tlsConfig *tls.Config) *ConfigBuilder {
+	return b.set("tls config", func(cfg *Config) error {
+		cfg.TLSClientConfig = tlsConfig
+		return nil
+	})
+}
+
+// LoadBalance sets one of the LOAD_BALANCE_* policies.
+func (b *ConfigBuilder) LoadBalance(policy string) *ConfigBuilder {
+	return b.set("load balance", func(cfg *Config) error {
+		switch policy {
+		case LOAD_BALANCE_FAILOVER, LOAD_BALANCE_ROUND_ROBIN, LOAD_BALANCE_LEAST_OUTSTANDING:
+			cfg.LoadBalance = policy
+			return nil
+		}
+		return fmt.Errorf("unknown policy '%s'", policy)
+	})
+}
+
+func (b *ConfigBuilder) Timeout(timeout time.Duration) *ConfigBuilder {
+	return b.set("timeout", func(cfg *Config) error {
+		if timeout < 0 {
+			return fmt.Errorf("negative timeout %s", timeout)
+		}
+		cfg.Timeout = timeout
+		return nil
+	})
+}
+
+func (b *ConfigBuilder) PageTimeout(timeout time.Duration) *ConfigBuilder {
+	return b.set("page timeout", func(cfg *Config) error {
+		if timeout < 0 {
+			return fmt.Errorf("negative timeout %s", timeout)
+		}
+		cfg.PageTimeout = timeout
+		return nil
+	})
+}
+
+// Pagination sets the long-polling wait of the page requests and the rows per page.
+func (b *ConfigBuilder) Pagination(waitTimeSecs, maxRowsInBuffer, maxRowsPerPage int64) *ConfigBuilder {
+	return b.set("pagination", func(cfg *Config) error {
+		if waitTimeSecs < 0 || maxRowsInBuffer < 0 || maxRowsPerPage < 0 {
+			return fmt.Errorf("negative pagination params")
+		}
+		cfg.WaitTimeSecs = waitTimeSecs
+		cfg.MaxRowsInBuffer = maxRowsInBuffer
+		cfg.MaxRowsPerPage = maxRowsPerPage
+		return nil
+	})
+}
+
+// RetryPolicy sets the retries of the Query or Page requests.
+func (b *ConfigBuilder) RetryPolicy(t RequestType, policy RetryPolicy) *ConfigBuilder {
+	return b.set("retry policy", func(cfg *Config) error {
+		switch policy.DelayType {
+		case "", RETRY_DELAY_FIXED, RETRY_DELAY_BACKOFF:
+		default:
+			return fmt.Errorf("unknown delay type '%s'", policy.DelayType)
+		}
+		if policy.Delay < 0 || policy.MaxDelay < 0 || policy.MaxJitter < 0 {
+			return fmt.Errorf("negative delay")
+		}
+		switch t {
+		case Query:
+			cfg.QueryRetryPolicy = policy
+		case Page:
+			cfg.PageRetryPolicy = policy
+		default:
+			return fmt.Errorf("unknown request type %d", t)
+		}
+		return nil
+	})
+}
+
+func (b *ConfigBuilder) DisableRetry() *ConfigBuilder {
+	return b.set("retry", func(cfg *Config) error {
+		cfg.DisableRetry = true
+		return nil
+	})
+}
+
+func (b *ConfigBuilder) Location(loc *time.Location) *ConfigBuilder {
+	return b.set("location", func(cfg *Config) error {
+		if loc == nil {
+			return fmt.Errorf("nil location")
+		}
+		cfg.Location = loc
+		return nil
+	})
+}
+
+// Setting sets a session setting like max_threads.
+func (b *ConfigBuilder) Setting(key, value string) *ConfigBuilder {
+	return b.set("setting", func(cfg *Config) error {
+		if key == "" {
+			return fmt.Errorf("empty key")
+		}
+		v, err := normalizeSetting(key, value)
+		if err != nil {
+			return fmt.Errorf("%s '%s': %w", key, value, err)
+		}
+		cfg.Params[key] = v
+		return nil
+	})
+}
+
+func (b *ConfigBuilder) Logger(logger Logger) *ConfigBuilder {
+	return b.set("logger", func(cfg *Config) error {
+		cfg.Logger = logger
+		return nil
+	})
+}
+
+// Apply changes the options without a setter of the builder.
+func (b *ConfigBuilder) Apply(fn func(cfg *Config)) *ConfigBuilder {
+	return b.set("config", func(cfg *Config) error {
+		fn(cfg)
+		return nil
+	})
+}
+
+// Build returns the validated config, or the error of the first invalid option.
+func (b *ConfigBuilder) Build() (*Config, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if err := b.cfg.Validate(); err != nil {
+		return nil, err
+	}
+	cfg := *b.cfg
+	cfg.Params = make(map[string]string, len(b.cfg.Params))
+	for k, v := range b.cfg.Params {
+		cfg.Params[k] = v
+	}
+	return &cfg, nil
+}
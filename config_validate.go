@@ -0,0 +1,138 @@
+package godatabend
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var settingNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Validate checks the config for the conflicting and invalid options, it's called
+// when a connection is opened, so the mistakes fail early with all the problems.
+func (cfg *Config) Validate() error {
+	var problems []string
+	addf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if strings.TrimSpace(cfg.Host) == "" {
+		addf("host is empty")
+	}
+	for _, h := range strings.Split(cfg.Host, ",") {
+		if strings.TrimSpace(h) == "" && cfg.Host != "" {
+			addf("empty host in '%s'", cfg.Host)
+			break
+		}
+	}
+
+	hasToken := cfg.AccessToken != "" || cfg.AccessTokenFile != "" || cfg.AccessTokenCommand != "" ||
+		cfg.AccessTokenSecret != "" || cfg.PrivateKeyFile != ""
+	hasPassword := cfg.Password != "" || cfg.PasswordSecret != ""
+	switch {
+	case cfg.User == "" && !hasToken && cfg.AccessTokenLoader == nil:
+		addf("no user or access token")
+	case hasPassword && cfg.User == "":
+		addf("password without user")
+	case hasPassword && (hasToken || cfg.AccessTokenLoader != nil):
+		addf("both password and access token are set, the access token would be ignored")
+	}
+	if cfg.AccessTokenLoader != nil && hasToken {
+		addf("AccessTokenLoader replaces the access token, file, command, secret and private key")
+	}
+	if cfg.Password != "" && cfg.PasswordSecret != "" {
+		addf("both password and password_secret are set")
+	}
+	if cfg.AccessToken != "" && cfg.AccessTokenSecret != "" {
+		addf("both access_token and access_token_secret are set")
+	}
+	if cfg.PrivateKeyFile != "" && cfg.User == "" {
+		addf("private_key_file requires the user the JWT is issued for")
+	}
+	if cfg.SessionToken && !hasPassword {
+		addf("session_token requires the user and password")
+	}
+
+	if strings.ContainsAny(cfg.ApplicationName, "\r\n") {
+		addf("invalid application_name '%s'", cfg.ApplicationName)
+	}
+
+	switch cfg.SSLMode {
+	case "", SSL_MODE_DISABLE, SSL_MODE_ENABLE, SSL_MODE_VERIFY_FULL, SSL_MODE_VERIFY_CA, SSL_MODE_SKIP_VERIFY:
+	default:
+		addf("invalid sslmode '%s'", cfg.SSLMode)
+	}
+	if cfg.SSLMode == SSL_MODE_DISABLE && (cfg.TLSCAFile != "" || cfg.TLSClientCertFile != "" || cfg.TLSClientConfig != nil) {
+		addf("the TLS options are set with sslmode=disable")
+	}
+	if (cfg.TLSClientCertFile == "") != (cfg.TLSClientKeyFile == "") {
+		addf("tls_cert_file and tls_key_file must be set together")
+	}
+	switch cfg.LoadBalance {
+	case "", LOAD_BALANCE_FAILOVER, LOAD_BALANCE_ROUND_ROBIN, LOAD_BALANCE_LEAST_OUTSTANDING:
+	default:
+		addf("invalid load_balance '%s'", cfg.LoadBalance)
+	}
+
+	durations := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"timeout", cfg.Timeout},
+		{"page_timeout", cfg.PageTimeout},
+		{"dial_timeout", cfg.DialTimeout},
+		{"response_header_timeout", cfg.ResponseHeaderTimeout},
+		{"ping_timeout", cfg.PingTimeout},
+		{"session_keep_alive_interval", cfg.SessionKeepAliveInterval},
+		{"idle_conn_timeout", cfg.IdleConnTimeout},
+		{"tls_handshake_timeout", cfg.TLSHandshakeTimeout},
+		{"upload_timeout", cfg.UploadTimeout},
+		{"circuit_breaker_cooldown", cfg.CircuitBreakerCooldown},
+		{"slow_query_threshold", cfg.SlowQueryThreshold},
+	}
+	for _, d := range durations {
+		if d.d < 0 {
+			addf("negative %s", d.name)
+		}
+	}
+	if cfg.WaitTimeSecs < 0 || cfg.MaxRowsInBuffer < 0 || cfg.MaxRowsPerPage < 0 || cfg.MaxResultRows < 0 {
+		addf("negative pagination params")
+	}
+	if cfg.PageTimeout > 0 && cfg.PageTimeout <= time.Duration(cfg.WaitTimeSecs)*time.Second {
+		addf("page_timeout %s is not longer than wait_time_secs %d", cfg.PageTimeout, cfg.WaitTimeSecs)
+	}
+	for _, t := range []RequestType{Query, Page} {
+		p := cfg.QueryRetryPolicy
+		if t == Page {
+			p = cfg.PageRetryPolicy
+		}
+		switch p.DelayType {
+		case "", RETRY_DELAY_FIXED, RETRY_DELAY_BACKOFF:
+		default:
+			addf("invalid %s_retry_delay_type '%s'", t, p.DelayType)
+		}
+		if p.Delay < 0 || p.MaxDelay < 0 || p.MaxJitter < 0 {
+			addf("negative %s retry delays", t)
+		}
+	}
+
+	var names []string
+	for k := range cfg.Params {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		if !settingNamePattern.MatchString(k) {
+			addf("invalid setting name '%s'", k)
+		} else if _, err := normalizeSetting(k, cfg.Params[k]); err != nil {
+			addf("invalid setting %s '%s': %v", k, cfg.Params[k], err)
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("databend: invalid config: %s", strings.Join(problems, "; "))
+}
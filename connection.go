@@ -1,19 +1,15 @@
 package godatabend
 
 import (
-	"bytes"
 	"context"
 	"database/sql/driver"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"os"
-	"strings"
 	"sync/atomic"
 	"time"
 
-	"github.com/avast/retry-go"
 	"github.com/google/uuid"
 )
 
@@ -33,12 +29,29 @@ type DatabendConn struct {
 	logger *log.Logger
 	rest   *APIClient
 	commit func() error
+	// stmtCache caches the parsed statements of the prepares
+	stmtCache *stmtCache
+	// readOnlyTxn rejects the writes of the read-only transaction
+	readOnlyTxn bool
+	// typedValues parses the rows like Config.TypedValues, it's only set for the
+	// Client, as the typed values are not the driver.Value of database/sql.
+	typedValues bool
+
+	keepAliveDone chan struct{}
 }
 
 func (dc *DatabendConn) exec(ctx context.Context, query string, args ...driver.Value) (driver.Result, error) {
+	if dc.rest == nil {
+		return nil, driver.ErrBadConn
+	}
+	if err := dc.checkReadOnly(query); err != nil {
+		return nil, err
+	}
 	respCh := make(chan QueryResponse)
 	errCh := make(chan error)
 	ctx = checkQueryID(ctx)
+	started := false
+	result := &QueryResult{}
 
 	go func() {
 		err := dc.rest.QuerySync(ctx, query, args, respCh)
@@ -49,57 +62,86 @@ func (dc *DatabendConn) exec(ctx context.Context, query string, args ...driver.V
 		select {
 		case err := <-errCh:
 			if err != nil {
+				if !started {
+					return emptyResult, badConn(err)
+				}
 				return emptyResult, err
 			} else {
-				return emptyResult, nil
+				return result, nil
 			}
 		case resp := <-respCh:
-			b, err := json.Marshal(resp.Data)
-			if err != nil {
-				return emptyResult, err
-			}
-			_, _ = io.Copy(io.Discard, bytes.NewReader(b))
+			started = true
+			result.queryID = resp.ID
+			result.stats = resp.Stats
+			result.warnings = appendWarnings(result.warnings, resp.Warnings)
+			result.addRows(&resp)
 		}
 	}
 }
 
 func (dc *DatabendConn) query(ctx context.Context, query string, args ...driver.Value) (driver.Rows, error) {
-	var r0 *QueryResponse
+	if dc.rest == nil {
+		return nil, driver.ErrBadConn
+	}
+	if err := dc.checkReadOnly(query); err != nil {
+		return nil, err
+	}
 	ctx = checkQueryID(ctx)
-	err := retry.Do(
-		func() error {
-			r, err := dc.rest.DoQuery(ctx, query, args)
-			if err != nil {
-				return err
-			}
-			r0 = r
-			return nil
-		},
-		// other err no need to retry
-		retry.RetryIf(func(err error) bool {
-			if err != nil && (IsProxyErr(err) || strings.Contains(err.Error(), ProvisionWarehouseTimeout)) {
-				return true
-			}
-			return false
-		}),
-		retry.Delay(2*time.Second),
-		retry.Attempts(5),
-		retry.DelayType(retry.FixedDelay),
-	)
+	// the rerun keeps the deduplicate label of the write
+	ctx = withWriteDeduplication(ctx, query)
+	rows, queryID, err := dc.startQuery(ctx, query, args)
+	if queryID != "" && isQueryLost(err) {
+		// no rows have been returned yet
+		if !canRerun(ctx, query) {
+			return nil, &QueryLostError{QueryID: queryID, Err: err}
+		}
+		dc.log("rerun query after its node is lost", queryID)
+		rows, _, err = dc.startQuery(ctx, query, args)
+	}
+	return rows, err
+}
+
+// startQuery starts the query and waits for its first rows, it returns the id of
+// the query once it's started.
+func (dc *DatabendConn) startQuery(ctx context.Context, query string, args []driver.Value) (driver.Rows, string, error) {
+	var r0 *QueryResponse
+	start := time.Now()
+	ctx, tracker := dc.rest.beginQuery(ctx, query)
+	err := dc.rest.DoRetry(ctx, func() error {
+		r, err := dc.rest.DoQuery(ctx, query, args)
+		if err != nil {
+			return err
+		}
+		r0 = r
+		return nil
+	}, Query)
 	if err != nil {
-		return nil, fmt.Errorf("query failed: %w", err)
+		dc.rest.failQuery(queryIDOf(ctx), start, err)
+		tracker.end(nil, err)
+		if err = badConn(err); err == driver.ErrBadConn {
+			return nil, "", err
+		}
+		return nil, "", fmt.Errorf("query failed: %w", tracker.wrap(err))
 	}
+	tracker.started(r0)
 	if r0.Error != nil {
-		return nil, fmt.Errorf("query has error: %+v", r0.Error)
+		tracker.end(r0, r0.Error)
+		return nil, "", fmt.Errorf("query has error: %w", tracker.wrap(r0.Error))
 	}
-	return newNextRows(ctx, dc, r0)
+	tooLarge := tracker.checkRows(r0)
+	rows, err := newNextRows(ctx, dc, r0)
+	if err != nil {
+		tracker.end(r0, err)
+		return nil, r0.ID, err
+	}
+	rows.tracker = tracker
+	rows.err = tooLarge
+	return rows, r0.ID, nil
 }
 
-//func (dc *DatabendConn) Begin() (driver.Tx, error) {
-//	return dc.BeginTx(dc.ctx, driver.TxOptions{})
-//}
-
-func (dc *DatabendConn) Begin() (driver.Tx, error) { return dc, nil }
+func (dc *DatabendConn) Begin() (driver.Tx, error) {
+	return dc.BeginTx(dc.ctx, driver.TxOptions{})
+}
 
 func (dc *DatabendConn) cleanup() {
 	// must flush log buffer while the process is running.
@@ -108,11 +150,10 @@ func (dc *DatabendConn) cleanup() {
 }
 
 func (dc *DatabendConn) Ping(ctx context.Context) error {
-	_, err := dc.exec(ctx, "SELECT 1")
-	if err != nil {
-		return err
+	if dc.rest == nil {
+		return driver.ErrBadConn
 	}
-	return nil
+	return badConn(dc.rest.Ping(ctx))
 }
 
 func (dc *DatabendConn) Prepare(query string) (driver.Stmt, error) {
@@ -124,15 +165,27 @@ func (dc *DatabendConn) prepare(ctx context.Context, query string) (*databendStm
 	if dc.rest == nil {
 		return nil, driver.ErrBadConn
 	}
+	parsed := dc.stmtCache.get(query)
+	if err := dc.checkReadOnly(parsed.query); err != nil {
+		return nil, err
+	}
+	if !parsed.batch {
+		return &databendStmt{
+			dc:     dc,
+			query:  query,
+			parsed: parsed,
+		}, nil
+	}
 	batch, err := dc.prepareBatch(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 	dc.commit = batch.BatchInsert
 	stmt := &databendStmt{
-		dc:    dc,
-		query: query,
-		batch: batch,
+		dc:     dc,
+		query:  query,
+		parsed: parsed,
+		batch:  batch,
 	}
 	return stmt, nil
 }
@@ -143,14 +196,25 @@ func (dc *DatabendConn) PrepareContext(ctx context.Context, query string) (drive
 }
 
 func buildDatabendConn(ctx context.Context, config Config) (*DatabendConn, error) {
+	if err := config.resolveSecrets(ctx); err != nil {
+		return nil, err
+	}
+	rest, err := NewAPIClient(&config)
+	if err != nil {
+		return nil, err
+	}
 	dc := &DatabendConn{
-		ctx:  ctx,
-		cfg:  &config,
-		rest: NewAPIClientFromConfig(&config),
+		ctx:       ctx,
+		cfg:       &config,
+		rest:      rest,
+		stmtCache: newStmtCache(config.StmtCacheSize),
 	}
 	if config.Debug {
 		dc.logger = log.New(os.Stderr, "databend: ", log.LstdFlags)
 	}
+	if config.SessionKeepAliveInterval > 0 {
+		dc.startKeepAlive(config.SessionKeepAliveInterval)
+	}
 	return dc, nil
 }
 
@@ -172,25 +236,55 @@ func (dc *DatabendConn) Close() error {
 		if cancel != nil {
 			cancel()
 		}
+		if dc.keepAliveDone != nil {
+			close(dc.keepAliveDone)
+		}
+		if rest := dc.rest; rest != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), logoutTimeout)
+			if err := rest.Logout(ctx); err != nil {
+				logger.Warnf("logout session failed: %v", err)
+			}
+			cancel()
+		}
 		dc.cleanup()
 	}
 	return nil
 }
 
+var (
+	_ driver.ExecerContext  = (*DatabendConn)(nil)
+	_ driver.QueryerContext = (*DatabendConn)(nil)
+)
+
+// ExecContext runs the statement without a prepare, the statements without args
+// are sent as is, and the placeholders of the others are parsed once by the
+// statement cache.
 func (dc *DatabendConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
-	values := make([]driver.Value, len(args))
-	for i, arg := range args {
-		values[i] = arg.Value
+	query, err := dc.interpolate(query, args)
+	if err != nil {
+		return nil, err
 	}
-	return dc.exec(ctx, query, values...)
+	return dc.exec(ctx, query)
 }
 
+// QueryContext runs the query without a prepare like ExecContext.
 func (dc *DatabendConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	query, err := dc.interpolate(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return dc.query(ctx, query)
+}
+
+func (dc *DatabendConn) interpolate(query string, args []driver.NamedValue) (string, error) {
+	if len(args) == 0 {
+		return query, nil
+	}
 	values := make([]driver.Value, len(args))
 	for i, arg := range args {
 		values[i] = arg.Value
 	}
-	return dc.query(ctx, query, values...)
+	return dc.stmtCache.get(query).interpolate(values)
 }
 
 // Commit applies prepared statement if it exists
@@ -211,6 +305,58 @@ func (dc *DatabendConn) Rollback() error {
 	return nil
 }
 
+// IsValid implements driver.Validator, so database/sql drops the closed connections.
+func (dc *DatabendConn) IsValid() bool {
+	return atomic.LoadInt32(&dc.closed) == 0 && dc.rest != nil
+}
+
+// ResetSession implements driver.SessionResetter, it rolls back the transaction left
+// by the previous user of the connection and restores the database, the roles, the
+// warehouse and the settings of the config.
+func (dc *DatabendConn) ResetSession(ctx context.Context) error {
+	if !dc.IsValid() {
+		return driver.ErrBadConn
+	}
+	dc.commit = nil
+	dc.readOnlyTxn = false
+	if dc.rest.inTxn() {
+		if _, err := dc.exec(ctx, "ROLLBACK"); err != nil {
+			dc.log("rollback on reset session failed", err)
+			return driver.ErrBadConn
+		}
+	}
+	dc.rest.resetSession()
+	return nil
+}
+
+// LastQueryID returns the id of the last query started on the connection, it could
+// be reached by sql.Conn.Raw.
+func (dc *DatabendConn) LastQueryID() string {
+	if dc.rest == nil {
+		return ""
+	}
+	return dc.rest.LastQueryID()
+}
+
+// LastQueryProfile returns the final statistics of the last query finished on the
+// connection, it could be reached by sql.Conn.Raw.
+func (dc *DatabendConn) LastQueryProfile() *QueryProfile {
+	if dc.rest == nil {
+		return nil
+	}
+	return dc.rest.LastQueryProfile()
+}
+
+// badConn returns driver.ErrBadConn for the errors which say the query has not
+// reached the server, so database/sql retries it on a fresh connection.
+func badConn(err error) error {
+	var connErr connectError
+	if errors.As(err, &connErr) {
+		return driver.ErrBadConn
+	}
+	return err
+}
+
 // checkQueryID checks if query_id exists in context, if not, generate a new one
 func checkQueryID(ctx context.Context) context.Context {
 	if _, ok := ctx.Value(ContextKeyQueryID).(string); ok {
@@ -220,3 +366,8 @@ func checkQueryID(ctx context.Context) context.Context {
 	ctx = context.WithValue(ctx, ContextKeyQueryID, queryId)
 	return ctx
 }
+
+func queryIDOf(ctx context.Context) string {
+	queryID, _ := ctx.Value(ContextKeyQueryID).(string)
+	return queryID
+}
@@ -0,0 +1,199 @@
+package godatabend
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadConn(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.Close()
+
+	cfg, err := ParseDSN("databend://root:root@" + strings.TrimPrefix(ts.URL, "http://") + "/default?sslmode=disable")
+	require.NoError(t, err)
+	dc, err := buildDatabendConn(context.Background(), *cfg)
+	require.NoError(t, err)
+	assert.True(t, dc.IsValid())
+
+	_, err = dc.exec(context.Background(), "INSERT INTO t VALUES (1)")
+	assert.Equal(t, driver.ErrBadConn, err)
+	_, err = dc.query(context.Background(), "SELECT 1")
+	assert.Equal(t, driver.ErrBadConn, err)
+
+	assert.NoError(t, dc.Close())
+	assert.False(t, dc.IsValid())
+	_, err = dc.query(context.Background(), "SELECT 1")
+	assert.Equal(t, driver.ErrBadConn, err)
+}
+
+func TestPing(t *testing.T) {
+	var paths []string
+	slow := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slow {
+			time.Sleep(200 * time.Millisecond)
+		}
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		_, _ = w.Write([]byte(`{"id":"q1","final_uri":"/v1/query/q1/final"}`))
+	}))
+	defer ts.Close()
+
+	cfg, err := ParseDSN("databend://root:root@" + strings.TrimPrefix(ts.URL, "http://") + "/default?sslmode=disable&ping_timeout=100ms")
+	require.NoError(t, err)
+	assert.Equal(t, 100*time.Millisecond, cfg.PingTimeout)
+	dc, err := buildDatabendConn(context.Background(), *cfg)
+	require.NoError(t, err)
+
+	assert.NoError(t, dc.Ping(context.Background()))
+	assert.Equal(t, []string{"POST /v1/query", "GET /v1/query/q1/final"}, paths)
+
+	slow = true
+	assert.Error(t, dc.Ping(context.Background()))
+}
+
+func TestExecResult(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"id":"q1","state":"Succeeded","stats":{"scan_progress":{"rows":5,"bytes":50},"write_progress":{"rows":3,"bytes":30}}}`))
+	}))
+	defer ts.Close()
+
+	dsn := "databend://root:root@" + strings.TrimPrefix(ts.URL, "http://") + "/default?sslmode=disable"
+	cfg, err := ParseDSN(dsn)
+	require.NoError(t, err)
+	dc, err := buildDatabendConn(context.Background(), *cfg)
+	require.NoError(t, err)
+	defer dc.Close()
+
+	res, err := dc.ExecContext(context.Background(), "INSERT INTO t SELECT * FROM s", nil)
+	require.NoError(t, err)
+	result, ok := res.(*QueryResult)
+	require.True(t, ok)
+	assert.Equal(t, uint64(3), result.RowsWritten())
+	assert.Equal(t, uint64(30), result.BytesWritten())
+	assert.Equal(t, uint64(5), result.RowsRead())
+	assert.Equal(t, "q1", result.QueryID())
+	assert.Equal(t, "q1", dc.LastQueryID())
+
+	rows, err := dc.QueryContext(context.Background(), "SELECT 1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "q1", rows.(interface{ QueryID() string }).QueryID())
+	require.NoError(t, rows.Close())
+
+	db, err := sql.Open("databend", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+	sqlRes, err := db.Exec("INSERT INTO t SELECT * FROM s")
+	require.NoError(t, err)
+	affected, err := sqlRes.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), affected)
+}
+
+func TestExecRowsAffected(t *testing.T) {
+	var body string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+	defer ts.Close()
+
+	db, err := sql.Open("databend", "databend://root:root@"+strings.TrimPrefix(ts.URL, "http://")+"/default?sslmode=disable")
+	require.NoError(t, err)
+	defer db.Close()
+
+	for _, c := range []struct {
+		body     string
+		affected int64
+	}{
+		// the write progress of UPDATE includes the rewritten rows
+		{`{"id":"q1","state":"Succeeded","schema":[{"name":"number of rows updated","type":"UInt64"}],"data":[["2"]],"stats":{"write_progress":{"rows":100}}}`, 2},
+		{`{"id":"q1","state":"Succeeded","schema":[{"name":"number of rows inserted","type":"UInt64"},{"name":"number of rows deleted","type":"UInt64"}],"data":[["3","4"]]}`, 7},
+		{`{"id":"q1","state":"Succeeded","schema":[{"name":"File","type":"String"},{"name":"Rows_loaded","type":"Int32"}],"data":[["a.csv","10"],["b.csv","5"]]}`, 15},
+		{`{"id":"q1","state":"Succeeded","stats":{"write_progress":{"rows":3}}}`, 3},
+	} {
+		body = c.body
+		res, err := db.Exec("UPDATE t SET a = 1")
+		require.NoError(t, err)
+		affected, err := res.RowsAffected()
+		require.NoError(t, err)
+		assert.Equal(t, c.affected, affected)
+	}
+}
+
+func TestExecWithoutPrepare(t *testing.T) {
+	var sqls []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req QueryRequest
+		if r.Method == http.MethodPost && json.NewDecoder(r.Body).Decode(&req) == nil {
+			sqls = append(sqls, req.SQL)
+		}
+		_, _ = w.Write([]byte(`{"id":"q1","state":"Succeeded"}`))
+	}))
+	defer ts.Close()
+
+	db, err := sql.Open("databend", "databend://root:root@"+strings.TrimPrefix(ts.URL, "http://")+"/default?sslmode=disable")
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	// the query without args is sent as is, the ? of the JSON operator is kept
+	_, err = db.Exec("SELECT v ? 'a' FROM t")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO t VALUES (?, ?)", nil, 1)
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO t VALUES (?, ?)", "a", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"SELECT v ? 'a' FROM t",
+		"INSERT INTO t VALUES (NULL, 1)",
+		"INSERT INTO t VALUES ('a', 2)",
+	}, sqls)
+
+	conn, err := db.Conn(context.Background())
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.Raw(func(c interface{}) error {
+		assert.Equal(t, 1, c.(*DatabendConn).stmtCache.len())
+		return nil
+	}))
+}
+
+func TestRawConn(t *testing.T) {
+	var settings map[string]string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req QueryRequest
+		if json.NewDecoder(r.Body).Decode(&req) == nil && req.Session != nil {
+			settings = req.Session.Settings
+		}
+		_, _ = w.Write([]byte(`{"id":"q1","state":"Succeeded"}`))
+	}))
+	defer ts.Close()
+
+	db, err := sql.Open("databend", "databend://root:root@"+strings.TrimPrefix(ts.URL, "http://")+"/default?sslmode=disable")
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(RawConn)
+		require.True(t, ok)
+		assert.NotNil(t, c.Client())
+		assert.Error(t, c.SetSetting(ctx, "enable_cbo", "maybe"))
+		return c.SetSetting(ctx, "enable_cbo", "true")
+	}))
+	_, err = conn.ExecContext(ctx, "SELECT 1")
+	require.NoError(t, err)
+	assert.Equal(t, "1", settings["enable_cbo"])
+}
@@ -0,0 +1,80 @@
+package godatabend
+
+import (
+	"context"
+	"database/sql/driver"
+)
+
+// Connector opens the connections with a Config, so sql.OpenDB works without a DSN:
+//
+//	connector, err := godatabend.NewConnector(cfg)
+//	if err != nil {
+//		return err
+//	}
+//	db := sql.OpenDB(connector)
+//
+// The access token loader is shared by the connections of the pool, so the token
+// is loaded and rotated once rather than per connection.
+type Connector struct {
+	cfg    Config
+	driver DatabendDriver
+}
+
+var _ driver.Connector = (*Connector)(nil)
+var _ driver.DriverContext = DatabendDriver{}
+
+// NewConnector validates the config and creates a Connector, later changes of cfg
+// do not affect it.
+func NewConnector(cfg *Config) (*Connector, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	c := &Connector{cfg: *cfg}
+	c.cfg.Params = make(map[string]string, len(cfg.Params))
+	for k, v := range cfg.Params {
+		c.cfg.Params[k] = v
+	}
+	// the token of a secret is resolved per connection, so it's not shared
+	if c.cfg.AccessTokenLoader == nil && c.cfg.AccessTokenSecret == "" {
+		if loader := initAccessTokenLoader(&c.cfg); loader != nil {
+			c.cfg.AccessTokenLoader = loader
+			c.cfg.AccessToken = ""
+			c.cfg.AccessTokenFile = ""
+			c.cfg.AccessTokenCommand = ""
+			c.cfg.PrivateKeyFile = ""
+		}
+	}
+	return c, nil
+}
+
+// Connect opens a connection, ctx is only used to open it, like resolving the secrets.
+func (c *Connector) Connect(ctx context.Context) (driver.Conn, error) {
+	if err := c.cfg.Validate(); err != nil {
+		return nil, err
+	}
+	dc, err := buildDatabendConn(ctx, c.cfg)
+	if err != nil {
+		return nil, err
+	}
+	dc.ctx = context.Background()
+	return dc, nil
+}
+
+// Driver returns the DatabendDriver.
+func (c *Connector) Driver() driver.Driver {
+	return c.driver
+}
+
+// OpenConnector parses the DSN once for all the connections of the pool.
+func (d DatabendDriver) OpenConnector(dsn string) (driver.Connector, error) {
+	cfg, err := ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	c, err := NewConnector(cfg)
+	if err != nil {
+		return nil, err
+	}
+	c.driver = d
+	return c, nil
+}
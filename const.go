@@ -1,10 +1,13 @@
 package godatabend
 
 const (
-	DatabendTenantHeader    = "X-DATABEND-TENANT"
-	DatabendWarehouseHeader = "X-DATABEND-WAREHOUSE"
-	DatabendQueryIDHeader   = "X-DATABEND-QUERY-ID"
-	Authorization           = "Authorization"
-	WarehouseRoute          = "X-DATABEND-ROUTE"
-	UserAgent               = "User-Agent"
+	DatabendTenantHeader           = "X-DATABEND-TENANT"
+	DatabendWarehouseHeader        = "X-DATABEND-WAREHOUSE"
+	DatabendQueryIDHeader          = "X-DATABEND-QUERY-ID"
+	DatabendDeduplicateLabelHeader = "X-DATABEND-DEDUPLICATE-LABEL"
+	DatabendStickyNodeHeader       = "X-DATABEND-STICKY-NODE"
+	DatabendRequestIDHeader        = "X-DATABEND-REQUEST-ID"
+	Authorization                  = "Authorization"
+	WarehouseRoute                 = "X-DATABEND-ROUTE"
+	UserAgent                      = "User-Agent"
 )
@@ -0,0 +1,296 @@
+package godatabend
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ConnectionOptions are the credentials and settings to access an external
+// object storage, they are rendered as the CONNECTION clause of COPY INTO.
+type ConnectionOptions struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	EndpointURL     string
+	Region          string
+	RoleARN         string
+	ExternalID      string
+
+	// Extra holds the other storage specific options, like account_name for azblob.
+	Extra map[string]string
+}
+
+func (o *ConnectionOptions) toMap() map[string]string {
+	m := map[string]string{}
+	for k, v := range o.Extra {
+		m[k] = v
+	}
+	for k, v := range map[string]string{
+		"access_key_id":     o.AccessKeyID,
+		"secret_access_key": o.SecretAccessKey,
+		"session_token":     o.SessionToken,
+		"endpoint_url":      o.EndpointURL,
+		"region":            o.Region,
+		"role_arn":          o.RoleARN,
+		"external_id":       o.ExternalID,
+	} {
+		if v != "" {
+			m[k] = v
+		}
+	}
+	return m
+}
+
+// CopyOptions are the options to control how the files are loaded.
+// https://docs.databend.com/sql/sql-commands/dml/dml-copy-into-table#copy-options
+type CopyOptions struct {
+	// OnError is one of continue, abort and abort_N, the server default is abort.
+	OnError string
+	// SizeLimit is the maximum rows of data to be loaded, 0 means no limit.
+	SizeLimit int64
+	// MaxFiles is the maximum number of files to be loaded, 0 means no limit.
+	MaxFiles int64
+	// Purge removes the files after they are loaded successfully.
+	Purge bool
+	// Force loads the files which have been loaded before.
+	Force bool
+	// ReturnFailedOnly only returns the files failed to load in the result.
+	ReturnFailedOnly    bool
+	DisableVariantCheck bool
+
+	// Extra holds the other copy options which are not listed above.
+	Extra map[string]string
+}
+
+// WithCopyOptions returns a context which makes the stage attached inserts, like
+// the batch inserts of a transaction, load with the given copy options.
+func WithCopyOptions(ctx context.Context, opts *CopyOptions) context.Context {
+	return context.WithValue(ctx, ContextKeyCopyOptions, opts)
+}
+
+func defaultCopyOptions() *CopyOptions {
+	return &CopyOptions{
+		Purge: true,
+	}
+}
+
+// clone copies the options, so the defaults of the client are not changed by the callers.
+func (o *CopyOptions) clone() *CopyOptions {
+	c := *o
+	if o.Extra != nil {
+		c.Extra = make(map[string]string, len(o.Extra))
+		for k, v := range o.Extra {
+			c.Extra[k] = v
+		}
+	}
+	return &c
+}
+
+func (o *CopyOptions) addParam(key, value string) (err error) {
+	switch key {
+	case "purge":
+		o.Purge, err = strconv.ParseBool(value)
+	case "force":
+		o.Force, err = strconv.ParseBool(value)
+	case "on_error":
+		o.OnError = value
+	case "max_files":
+		o.MaxFiles, err = strconv.ParseInt(value, 10, 64)
+	case "size_limit":
+		o.SizeLimit, err = strconv.ParseInt(value, 10, 64)
+	}
+	return
+}
+
+func (o *CopyOptions) formatParams(query url.Values, prefix string) {
+	if o == nil {
+		return
+	}
+	query.Set(prefix+"purge", strconv.FormatBool(o.Purge))
+	if o.Force {
+		query.Set(prefix+"force", "true")
+	}
+	if o.OnError != "" {
+		query.Set(prefix+"on_error", o.OnError)
+	}
+	if o.MaxFiles > 0 {
+		query.Set(prefix+"max_files", strconv.FormatInt(o.MaxFiles, 10))
+	}
+	if o.SizeLimit > 0 {
+		query.Set(prefix+"size_limit", strconv.FormatInt(o.SizeLimit, 10))
+	}
+}
+
+func (o *CopyOptions) toMap() map[string]string {
+	if o == nil {
+		return nil
+	}
+	m := map[string]string{}
+	for k, v := range o.Extra {
+		m[k] = v
+	}
+	if o.OnError != "" {
+		m["on_error"] = o.OnError
+	}
+	if o.SizeLimit > 0 {
+		m["size_limit"] = strconv.FormatInt(o.SizeLimit, 10)
+	}
+	if o.MaxFiles > 0 {
+		m["max_files"] = strconv.FormatInt(o.MaxFiles, 10)
+	}
+	if o.Purge {
+		m[PURGE] = "true"
+	}
+	if o.Force {
+		m["force"] = "true"
+	}
+	if o.ReturnFailedOnly {
+		m["return_failed_only"] = "true"
+	}
+	if o.DisableVariantCheck {
+		m["disable_variant_check"] = "true"
+	}
+	return m
+}
+
+// CopyIntoOptions are the options of CopyIntoTableFromURL.
+type CopyIntoOptions struct {
+	Connection ConnectionOptions
+	// Files and Pattern select the files to load under the location, all the files are
+	// loaded if both are empty.
+	Files   []string
+	Pattern string
+	// FileFormatOptions defaults to the csv format options.
+	FileFormatOptions map[string]string
+	CopyOptions       *CopyOptions
+}
+
+// CopyResult is the load result of a single file.
+type CopyResult struct {
+	File           string
+	RowsLoaded     int64
+	ErrorsSeen     int64
+	FirstError     string
+	FirstErrorLine int64
+}
+
+// CopyIntoTableFromURL loads the files from an external location like s3://bucket/path/
+// into the table.
+func (c *APIClient) CopyIntoTableFromURL(ctx context.Context, table, location string, opts *CopyIntoOptions) ([]CopyResult, error) {
+	if opts == nil {
+		opts = &CopyIntoOptions{}
+	}
+	fileFormatOptions := opts.FileFormatOptions
+	if fileFormatOptions == nil {
+		fileFormatOptions = c.NewFileFormatOptions(c.fileFormat)
+	}
+	copyOptions := opts.CopyOptions
+	if copyOptions == nil {
+		copyOptions = c.copyOptions
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "COPY INTO %s FROM %s", table, quote(escape(location)))
+	if conn := opts.Connection.toMap(); len(conn) > 0 {
+		fmt.Fprintf(&sb, " CONNECTION = (%s)", formatSQLOptions(conn))
+	}
+	if len(opts.Files) > 0 {
+		files := make([]string, len(opts.Files))
+		for i, f := range opts.Files {
+			files[i] = quote(escape(f))
+		}
+		fmt.Fprintf(&sb, " FILES = (%s)", strings.Join(files, ", "))
+	}
+	if opts.Pattern != "" {
+		fmt.Fprintf(&sb, " PATTERN = %s", quote(escape(opts.Pattern)))
+	}
+	fmt.Fprintf(&sb, " FILE_FORMAT = (%s)", formatSQLOptions(fileFormatOptions))
+	if copyOptions := copyOptions.toMap(); len(copyOptions) > 0 {
+		fmt.Fprintf(&sb, " %s", formatSQLOptions(copyOptions))
+	}
+
+	resp, err := c.QuerySingle(ctx, sb.String(), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to copy into table")
+	}
+	return parseCopyResults(resp)
+}
+
+// identOptions are the options whose values are keywords rather than strings.
+var identOptions = map[string]bool{
+	"type":        true,
+	"compression": true,
+	"on_error":    true,
+}
+
+// formatSQLOptions renders options as `KEY = value` pairs in a stable order,
+// the values are quoted unless they are keywords, numbers or booleans.
+func formatSQLOptions(options map[string]string) string {
+	keys := make([]string, 0, len(options))
+	for k := range options {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		v := options[k]
+		if !identOptions[strings.ToLower(k)] && !isSQLLiteral(v) {
+			v = quote(escape(v))
+		}
+		parts = append(parts, fmt.Sprintf("%s = %s", strings.ToUpper(k), v))
+	}
+	return strings.Join(parts, " ")
+}
+
+func isSQLLiteral(v string) bool {
+	if _, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return true
+	}
+	return strings.EqualFold(v, "true") || strings.EqualFold(v, "false")
+}
+
+func parseCopyResults(resp *QueryResponse) ([]CopyResult, error) {
+	index := map[string]int{}
+	for i, field := range resp.Schema {
+		index[strings.ToLower(field.Name)] = i
+	}
+	get := func(row []string, name string) string {
+		if i, ok := index[name]; ok && i < len(row) && row[i] != "NULL" {
+			return row[i]
+		}
+		return ""
+	}
+	getInt := func(row []string, name string) (int64, error) {
+		v := get(row, name)
+		if v == "" {
+			return 0, nil
+		}
+		return strconv.ParseInt(v, 10, 64)
+	}
+
+	results := make([]CopyResult, 0, len(resp.Data))
+	for _, row := range resp.Data {
+		var err error
+		result := CopyResult{
+			File:       get(row, "file"),
+			FirstError: get(row, "first_error"),
+		}
+		if result.RowsLoaded, err = getInt(row, "rows_loaded"); err != nil {
+			return nil, errors.Wrap(err, "invalid rows_loaded in copy result")
+		}
+		if result.ErrorsSeen, err = getInt(row, "errors_seen"); err != nil {
+			return nil, errors.Wrap(err, "invalid errors_seen in copy result")
+		}
+		if result.FirstErrorLine, err = getInt(row, "first_error_line"); err != nil {
+			return nil, errors.Wrap(err, "invalid first_error_line in copy result")
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql/driver"
 	"fmt"
 	"io"
+	"math/big"
 	"reflect"
 	"strconv"
 	"strings"
@@ -26,6 +27,7 @@ var (
 	reflectTypeUInt64      = reflect.TypeOf(uint64(0))
 	reflectTypeFloat32     = reflect.TypeOf(float32(0))
 	reflectTypeFloat64     = reflect.TypeOf(float64(0))
+	reflectTypeBigRat      = reflect.TypeOf((*big.Rat)(nil))
 )
 
 func readNumber(s io.RuneScanner) (string, error) {
@@ -225,6 +227,84 @@ func (p *nullableParser) Parse(s io.RuneScanner) (driver.Value, error) {
 	return p.DataParser.Parse(dB)
 }
 
+// parseCell parses a cell of the results, null tells whether it's a NULL of the
+// response, which is nil with the typed values.
+func parseCell(p DataParser, cell string, null bool) (driver.Value, error) {
+	if _, ok := p.(*typedNullableParser); ok && null {
+		return nil, nil
+	}
+	return p.Parse(strings.NewReader(cell))
+}
+
+// typedNullableParser parses the NULL as nil and the other values with arg. The
+// nested values are the pointers, like []*int32 for Array(Nullable(Int32)), so the
+// NULL elements are kept.
+type typedNullableParser struct {
+	arg     DataParser
+	unquote bool
+}
+
+func (p *typedNullableParser) Parse(s io.RuneScanner) (driver.Value, error) {
+	if !p.unquote {
+		// the NULL of a column is known by the null of the response, see parseCell
+		return p.arg.Parse(s)
+	}
+	// the nested strings are quoted, so only NULL and NaN start with N
+	var v driver.Value
+	var err error
+	if r := read(s); r == 'N' {
+		rest, _ := readNumber(s)
+		if rest == "ULL" {
+			return reflect.Zero(p.Type()).Interface(), nil
+		}
+		v, err = p.arg.Parse(strings.NewReader("N" + rest))
+	} else {
+		_ = s.UnreadRune()
+		v, err = p.arg.Parse(s)
+	}
+	if err != nil || p.Type() == p.arg.Type() {
+		return v, err
+	}
+	ptr := reflect.New(p.arg.Type())
+	ptr.Elem().Set(reflect.ValueOf(v))
+	return ptr.Interface(), nil
+}
+
+func (p *typedNullableParser) Type() reflect.Type {
+	t := p.arg.Type()
+	if !p.unquote {
+		return t
+	}
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+		return t
+	}
+	return reflect.PtrTo(t)
+}
+
+// decimalParser parses the Decimal as *big.Rat, which keeps all the digits.
+type decimalParser struct {
+	unquote bool
+}
+
+func (p *decimalParser) Parse(s io.RuneScanner) (driver.Value, error) {
+	var repr string
+	if p.unquote {
+		repr, _ = readNumber(s)
+	} else {
+		repr = readRaw(s).String()
+	}
+	v, ok := new(big.Rat).SetString(repr)
+	if !ok {
+		return nil, fmt.Errorf("malformed decimal %q", repr)
+	}
+	return v, nil
+}
+
+func (p *decimalParser) Type() reflect.Type {
+	return reflectTypeBigRat
+}
+
 type stringParser struct {
 	unquote bool
 	length  int
@@ -594,6 +674,9 @@ type DataParserOptions struct {
 	Location *time.Location
 	// UseDBLocation if false: always use Location, ignore DateTime argument.
 	UseDBLocation bool
+	// TypedValues parses Nullable(T) as T with nil for NULL, and Decimal as *big.Rat,
+	// instead of the strings.
+	TypedValues bool
 }
 
 // NewDataParser creates a new DataParser based on the
@@ -607,6 +690,13 @@ func newDataParser(t *TypeDesc, unquote bool, opt *DataParserOptions) (DataParse
 	case "Nothing":
 		return &nothingParser{}, nil
 	case "Nullable":
+		if opt != nil && opt.TypedValues && len(t.Args) == 1 {
+			subParser, err := newDataParser(t.Args[0], unquote, opt)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create parser for Nullable element: %v", err)
+			}
+			return &typedNullableParser{arg: subParser, unquote: unquote}, nil
+		}
 		return &stringParser{unquote: unquote}, nil
 	case "NULL":
 		return &stringParser{unquote: unquote}, nil
@@ -684,7 +774,12 @@ func newDataParser(t *TypeDesc, unquote bool, opt *DataParserOptions) (DataParse
 		return &floatParser{32}, nil
 	case "Float64":
 		return &floatParser{64}, nil
-	case "Decimal", "String", "Enum8", "Enum16", "UUID", "IPv4", "IPv6", "Variant", "VariantObject":
+	case "Decimal":
+		if opt != nil && opt.TypedValues {
+			return &decimalParser{unquote: unquote}, nil
+		}
+		return &stringParser{unquote: unquote}, nil
+	case "String", "Enum8", "Enum16", "UUID", "IPv4", "IPv6", "Variant", "VariantObject":
 		return &stringParser{unquote: unquote}, nil
 	case "FixedString":
 		if len(t.Args) != 1 {
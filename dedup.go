@@ -0,0 +1,39 @@
+package godatabend
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// WithDeduplicateLabel returns a context to send the queries with the deduplicate
+// label, the server skips a write whose label has been committed, so the write
+// could be retried safely.
+func WithDeduplicateLabel(ctx context.Context, label string) context.Context {
+	return context.WithValue(ctx, ContextKeyDeduplicateLabel, label)
+}
+
+// withWriteDeduplication labels the write query with a new label if no label is
+// given, it's called once per statement and the returned context is kept by the
+// retries and the reruns of the statement, so a write whose first attempt has
+// succeeded is deduplicated by the server. The label is never the query id, which
+// may be given by the caller and shared by the different writes.
+func withWriteDeduplication(ctx context.Context, sql string) context.Context {
+	if _, ok := ctx.Value(ContextKeyDeduplicateLabel).(string); ok || !isWriteQuery(sql) {
+		return ctx
+	}
+	return WithDeduplicateLabel(ctx, uuid.NewString())
+}
+
+func isWriteQuery(sql string) bool {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "INSERT", "REPLACE", "COPY":
+		return true
+	}
+	return false
+}
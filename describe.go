@@ -0,0 +1,71 @@
+package godatabend
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DescribeQuery returns the columns of the result of the SELECT query without
+// running it, the query is wrapped with LIMIT 0. The placeholders are replaced with
+// NULL, so the columns of the bare placeholders have the type NULL.
+func (c *APIClient) DescribeQuery(ctx context.Context, query string) ([]DataField, error) {
+	parsed := parseQuery(query)
+	fields := strings.Fields(parsed.query)
+	if len(fields) == 0 {
+		return nil, errors.New("empty query")
+	}
+	switch strings.ToUpper(strings.TrimLeft(fields[0], "(")) {
+	case "SELECT", "WITH", "VALUES":
+	default:
+		return nil, fmt.Errorf("only the queries could be described, not %s", fields[0])
+	}
+	q, err := parsed.interpolate(make([]driver.Value, len(parsed.index)))
+	if err != nil {
+		return nil, err
+	}
+	first, err := c.DoQuery(ctx, fmt.Sprintf("SELECT * FROM (%s) LIMIT 0", q), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to describe query")
+	}
+	resp, err := c.WaitForQuery(ctx, first)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to describe query")
+	}
+	if len(resp.Schema) == 0 {
+		return first.Schema, nil
+	}
+	return resp.Schema, nil
+}
+
+// DescribeQuery is APIClient.DescribeQuery on the connection, it could be reached by
+// sql.Conn.Raw, so the tools know the columns of a prepared query before running it.
+func (dc *DatabendConn) DescribeQuery(ctx context.Context, query string) ([]DataField, error) {
+	if dc.rest == nil {
+		return nil, driver.ErrBadConn
+	}
+	return dc.rest.DescribeQuery(ctx, query)
+}
+
+// ResolveQuerySchema returns the columns of the result of the SELECT query with their
+// types parsed, without running it, like DescribeQuery, so the BI tools and the code
+// generators know the schema up front.
+func (c *APIClient) ResolveQuerySchema(ctx context.Context, query string) (Schema, error) {
+	fields, err := c.DescribeQuery(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSchema(fields)
+}
+
+// ResolveQuerySchema is APIClient.ResolveQuerySchema on the session of the client.
+func (c *Client) ResolveQuerySchema(ctx context.Context, query string) (Schema, error) {
+	fields, err := c.dc.DescribeQuery(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSchema(fields)
+}
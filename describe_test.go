@@ -0,0 +1,39 @@
+package godatabend
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescribeQuery(t *testing.T) {
+	var gotSQL string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req QueryRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		gotSQL = req.SQL
+		_, _ = w.Write([]byte(`{"id":"q1","state":"Succeeded","schema":[{"name":"a","type":"Int32"},{"name":"b","type":"Nullable(String)"}],"data":[]}`))
+	}))
+	defer ts.Close()
+
+	c := APIClient{cli: &http.Client{}, apiEndpoint: ts.URL, user: "root"}
+	fields, err := c.DescribeQuery(context.Background(), "SELECT a, b FROM t WHERE a = ?;")
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM (SELECT a, b FROM t WHERE a = NULL) LIMIT 0", gotSQL)
+	assert.Equal(t, []DataField{{Name: "a", Type: "Int32"}, {Name: "b", Type: "Nullable(String)"}}, fields)
+
+	_, err = c.DescribeQuery(context.Background(), "DELETE FROM t")
+	assert.EqualError(t, err, "only the queries could be described, not DELETE")
+
+	schema, err := c.ResolveQuerySchema(context.Background(), "SELECT a, b FROM t")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, schema.Names())
+	assert.Equal(t, "Int32", schema[0].TypeName())
+	assert.True(t, schema[1].Nullable)
+	assert.Equal(t, "String", schema[1].Type.Name)
+}
@@ -28,6 +28,9 @@ func (d DatabendDriver) OpenWithConfig(
 	config Config) (
 	driver.Conn, error) {
 	logger.Info("OpenWithConfig")
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
 	dc, err := buildDatabendConn(ctx, config)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,106 @@
+package godatabend
+
+import (
+	"net"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// The environment variables read by ConfigFromEnv.
+const (
+	EnvDSN         = "DATABEND_DSN"
+	EnvHost        = "DATABEND_HOST"
+	EnvPort        = "DATABEND_PORT"
+	EnvUser        = "DATABEND_USER"
+	EnvPassword    = "DATABEND_PASSWORD"
+	EnvDatabase    = "DATABEND_DATABASE"
+	EnvTenant      = "DATABEND_TENANT"
+	EnvWarehouse   = "DATABEND_WAREHOUSE"
+	EnvRole        = "DATABEND_ROLE"
+	EnvAccessToken = "DATABEND_ACCESS_TOKEN"
+	EnvSSLMode     = "DATABEND_SSLMODE"
+)
+
+// ConfigFromEnv creates the config from the DSN of DATABEND_DSN, or the profile of
+// DATABEND_PROFILE, or the defaults, then the other DATABEND_* variables override
+// the values of the DSN, like DATABEND_HOST, DATABEND_USER, DATABEND_PASSWORD,
+// DATABEND_WAREHOUSE and DATABEND_ROLE. The empty variables are ignored.
+func ConfigFromEnv() (*Config, error) {
+	cfg := NewConfig()
+	if dsn, ok := lookupEnv(EnvDSN); ok {
+		var err error
+		if cfg, err = ParseDSN(dsn); err != nil {
+			return nil, errors.Wrapf(err, "invalid %s", EnvDSN)
+		}
+	} else if name, ok := lookupEnv(EnvProfile); ok {
+		var err error
+		if cfg, err = ParseDSN("databend://?profile=" + url.QueryEscape(name)); err != nil {
+			return nil, errors.Wrapf(err, "invalid %s", EnvProfile)
+		}
+	}
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// lookupEnv treats the empty variables as unset.
+func lookupEnv(key string) (string, bool) {
+	v := os.Getenv(key)
+	return v, v != ""
+}
+
+// applyEnv overrides the config with the DATABEND_* variables which are set.
+func (cfg *Config) applyEnv() error {
+	for env, field := range map[string]*string{
+		EnvUser:        &cfg.User,
+		EnvPassword:    &cfg.Password,
+		EnvDatabase:    &cfg.Database,
+		EnvTenant:      &cfg.Tenant,
+		EnvWarehouse:   &cfg.Warehouse,
+		EnvRole:        &cfg.Role,
+		EnvAccessToken: &cfg.AccessToken,
+	} {
+		if v, ok := lookupEnv(env); ok {
+			*field = v
+		}
+	}
+	if v, ok := lookupEnv(EnvSSLMode); ok {
+		if err := cfg.AddParams(map[string]string{"sslmode": v}); err != nil {
+			return errors.Wrapf(err, "invalid %s", EnvSSLMode)
+		}
+	}
+	host, hasHost := lookupEnv(EnvHost)
+	port, hasPort := lookupEnv(EnvPort)
+	if !hasHost && !hasPort {
+		return nil
+	}
+	if strings.Contains(host, ",") {
+		// the list of hosts comes with their ports
+		cfg.Host = host
+		return nil
+	}
+	if !hasHost {
+		// only the port of the host in the DSN is overridden
+		h, _, err := net.SplitHostPort(cfg.Host)
+		if err != nil {
+			return errors.Wrapf(err, "invalid host %s", cfg.Host)
+		}
+		host = h
+	}
+	if hasPort {
+		host = net.JoinHostPort(host, port)
+	} else if _, _, err := net.SplitHostPort(host); err != nil {
+		// default to the port of the scheme like ParseDSN
+		port = "443"
+		if cfg.SSLMode == SSL_MODE_DISABLE {
+			port = "80"
+		}
+		host = net.JoinHostPort(host, port)
+	}
+	cfg.Host = host
+	return nil
+}
@@ -2,6 +2,7 @@ package godatabend
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/pkg/errors"
 )
@@ -10,6 +11,9 @@ var (
 	ErrPlaceholderCount = errors.New("databend: wrong placeholder count")
 	ErrNoLastInsertID   = errors.New("no LastInsertId available")
 	ErrNoRowsAffected   = errors.New("no RowsAffected available")
+	// ErrResultTooLarge is returned when the query returns more rows than
+	// Config.MaxResultRows, the query is killed.
+	ErrResultTooLarge = errors.New("databend: the result has too many rows")
 )
 
 // Error contains parsed information about server error
@@ -22,3 +26,74 @@ type Error struct {
 func (e *Error) Error() string {
 	return fmt.Sprintf("Code: %d, Message: %s", e.Code, e.Message)
 }
+
+// StatementError is the error of a query with the id of the query and a snippet of
+// the SQL, so the failing statement could be found on the server.
+type StatementError struct {
+	QueryID string
+	// SQL is the snippet of the statement, see Config.ErrorSQLLength.
+	SQL string
+	// RequestID is the server side id of the failed request, if the server sent it.
+	RequestID string
+	Err       error
+}
+
+func (e *StatementError) Error() string {
+	var attrs []string
+	if e.QueryID != "" {
+		attrs = append(attrs, "query_id: "+e.QueryID)
+	}
+	if e.RequestID != "" {
+		attrs = append(attrs, "request_id: "+e.RequestID)
+	}
+	if e.SQL != "" {
+		attrs = append(attrs, fmt.Sprintf("sql: %q", e.SQL))
+	}
+	if len(attrs) == 0 {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%v [%s]", e.Err, strings.Join(attrs, ", "))
+}
+
+func (e *StatementError) Unwrap() error {
+	return e.Err
+}
+
+// PageError is the error of a page of a query, with the page and the node which
+// responded, to debug the intermittent errors of the clusters.
+type PageError struct {
+	// Page is the sequence number of the page after the query request, starting
+	// from 1, 0 if unknown.
+	Page    int
+	NextURI string
+	NodeID  string
+	Err     error
+}
+
+func (e *PageError) Error() string {
+	msg := fmt.Sprintf("query page %d (%s)", e.Page, e.NextURI)
+	if e.NodeID != "" {
+		msg += " on node " + e.NodeID
+	}
+	return fmt.Sprintf("%s has error: %v", msg, e.Err)
+}
+
+func (e *PageError) Unwrap() error {
+	return e.Err
+}
+
+// sqlSnippet truncates the query to n runes, the literals are replaced with '?' if
+// redact is set.
+func sqlSnippet(query string, n int, redact bool) string {
+	if n <= 0 {
+		return ""
+	}
+	if redact {
+		query = redactSQL(query)
+	}
+	runes := []rune(strings.TrimSpace(query))
+	if len(runes) <= n {
+		return string(runes)
+	}
+	return string(runes[:n]) + "..."
+}
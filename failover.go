@@ -0,0 +1,93 @@
+package godatabend
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// hosts splits the comma separated Config.Host.
+func (cfg *Config) hosts() []string {
+	var hosts []string
+	for _, h := range strings.Split(cfg.Host, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// isFailoverErr tells whether a new query could be sent to the next endpoint after
+// err, that is the endpoint is unreachable or unhealthy, or its breaker is open.
+func isFailoverErr(err error) bool {
+	if errors.Is(err, ErrDoRequest) || errors.Is(err, ErrCircuitOpen) {
+		return true
+	}
+	var apiErr APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode >= 500
+}
+
+// failover switches to the next endpoint for the new queries, unless another query
+// has switched from the failed endpoint already, and returns the new endpoint.
+func (c *APIClient) failover(failed string) string {
+	c.endpointMu.Lock()
+	defer c.endpointMu.Unlock()
+	if len(c.endpoints) < 2 {
+		return c.apiEndpoint
+	}
+	if c.apiEndpoint == failed {
+		c.endpointIdx = (c.endpointIdx + 1) % len(c.endpoints)
+		c.apiEndpoint = c.endpoints[c.endpointIdx]
+		logger.Infof("failover to %s", c.apiEndpoint)
+	}
+	return c.apiEndpoint
+}
+
+// endpoint returns the endpoint of the new queries.
+func (c *APIClient) endpoint() string {
+	c.endpointMu.Lock()
+	defer c.endpointMu.Unlock()
+	return c.apiEndpoint
+}
+
+// pickEndpoint returns the endpoint of a new query, the balancer picks it if
+// balance is true.
+func (c *APIClient) pickEndpoint(balance bool) string {
+	c.endpointMu.Lock()
+	defer c.endpointMu.Unlock()
+	if c.balancer != nil && balance {
+		c.endpointIdx = c.balancer.pick()
+		c.apiEndpoint = c.endpoints[c.endpointIdx]
+	}
+	return c.apiEndpoint
+}
+
+// pinQueryURIs makes the URIs of the query absolute with the endpoint which runs
+// the query, so the page, final and kill requests always go to the same node.
+func (c *APIClient) pinQueryURIs(resp *QueryResponse, endpoint string) {
+	if len(c.endpoints) < 2 {
+		return
+	}
+	for _, uri := range []*string{&resp.NextURI, &resp.FinalURI, &resp.KillURI, &resp.StatsURI} {
+		if *uri != "" && !isAbsoluteURI(*uri) {
+			*uri = endpoint + *uri
+		}
+	}
+}
+
+func isAbsoluteURI(uri string) bool {
+	return strings.HasPrefix(uri, "http://") || strings.HasPrefix(uri, "https://")
+}
+
+// endpointOf returns the endpoint of the absolute uri, or the current endpoint.
+func (c *APIClient) endpointOf(uri string) string {
+	if !isAbsoluteURI(uri) {
+		return c.endpoint()
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return c.endpoint()
+	}
+	return u.Scheme + "://" + u.Host
+}
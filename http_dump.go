@@ -0,0 +1,109 @@
+package godatabend
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// maxDumpBodySize truncates the dumped bodies.
+const maxDumpBodySize = 1 << 20
+
+const redacted = "***"
+
+var (
+	redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Amz-Security-Token"}
+	// the secrets in the json bodies, like the tokens of the session login
+	jsonSecretPattern = regexp.MustCompile(`(?i)("(?:password|[a-z_]*token|[a-z_]*secret[a-z_]*)"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+	// the secrets in the SQL, like the passwords of the users and the credentials of the stages
+	sqlSecretPattern = regexp.MustCompile(`(?i)((?:identified\s+by|password|secret_access_key|access_key_id|session_token|security_token|account_key)\s*=?\s*)'(?:[^'\\]|\\.|'')*'`)
+)
+
+// dumpHTTP logs the request and the response with the secrets redacted, the bodies
+// are only logged if they are JSON, so the uploaded files are not.
+func (c *APIClient) dumpHTTP(cli *http.Client, req *http.Request) (*http.Response, error) {
+	var err error
+	args := []interface{}{"method", req.Method, "url", redactURL(req.URL), "headers", redactHeaders(req.Header)}
+	if isJSON(req.Header) && req.Body != nil {
+		var body []byte
+		body, req.Body, err = readDumpBody(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "body", redactBody(body))
+	}
+	c.log().Info("http request", args...)
+
+	start := time.Now()
+	resp, err := c.intercept(0, cli, req)
+	if err != nil {
+		c.log().Info("http response", "url", redactURL(req.URL), "elapsed", time.Since(start), "error", err)
+		return nil, err
+	}
+	args = []interface{}{"url", redactURL(req.URL), "status", resp.StatusCode, "elapsed", time.Since(start), "headers", redactHeaders(resp.Header)}
+	if isJSON(resp.Header) {
+		var body []byte
+		body, resp.Body, err = readDumpBody(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "body", redactBody(body))
+	}
+	c.log().Info("http response", args...)
+	return resp, nil
+}
+
+// readDumpBody reads the body to dump, and returns another reader of it.
+func readDumpBody(rc io.ReadCloser) ([]byte, io.ReadCloser, error) {
+	defer rc.Close()
+	body, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, nil, err
+	}
+	return body, io.NopCloser(bytes.NewReader(body)), nil
+}
+
+func isJSON(header http.Header) bool {
+	return strings.Contains(header.Get(contentType), "json")
+}
+
+func redactHeaders(header http.Header) http.Header {
+	h := header.Clone()
+	for _, name := range redactedHeaders {
+		if h.Get(name) != "" {
+			h.Set(name, redacted)
+		}
+	}
+	return h
+}
+
+// redactURL hides the password of the url and the signatures of the presigned urls.
+func redactURL(u *url.URL) string {
+	r := *u
+	if _, ok := r.User.Password(); ok {
+		r.User = url.UserPassword(r.User.Username(), redacted)
+	}
+	query := r.Query()
+	for k := range query {
+		lower := strings.ToLower(k)
+		if strings.Contains(lower, "signature") || strings.Contains(lower, "credential") || strings.Contains(lower, "token") {
+			query.Set(k, redacted)
+		}
+	}
+	r.RawQuery = query.Encode()
+	return r.String()
+}
+
+func redactBody(body []byte) string {
+	s := string(body)
+	if len(s) > maxDumpBodySize {
+		s = fmt.Sprintf("%s...(%d bytes truncated)", s[:maxDumpBodySize], len(s)-maxDumpBodySize)
+	}
+	s = jsonSecretPattern.ReplaceAllString(s, `$1"`+redacted+`"`)
+	return sqlSecretPattern.ReplaceAllString(s, `$1'`+redacted+`'`)
+}
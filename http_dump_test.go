@@ -0,0 +1,42 @@
+package godatabend
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugHTTP(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(contentType, jsonContentType)
+		_, _ = w.Write([]byte(`{"id":"q1","state":"Succeeded","session":{"settings":{"a":"b"}}}`))
+	}))
+	defer ts.Close()
+
+	l := &recordLogger{}
+	c := APIClient{cli: &http.Client{}, apiEndpoint: ts.URL, user: "root", password: "pass", structLogger: l, debugHTTP: true}
+	_, err := c.DoQuery(context.Background(), "CREATE USER u IDENTIFIED BY 'pa''ss'", nil)
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"DEBUG query start", "INFO http request", "INFO http response", "INFO query end"}, l.msgs)
+	request := fmt.Sprint(l.args[1]...)
+	assert.Contains(t, request, `IDENTIFIED BY '***'"`)
+	assert.Contains(t, request, `Authorization:[***]`)
+	assert.NotContains(t, request, "pass")
+	assert.Contains(t, fmt.Sprint(l.args[2]...), `"id":"q1"`)
+}
+
+func TestRedact(t *testing.T) {
+	u, _ := url.Parse("https://s3.amazonaws.com/b/f?X-Amz-Signature=abc&X-Amz-Credential=def&x=1")
+	assert.Equal(t, "https://s3.amazonaws.com/b/f?X-Amz-Credential=%2A%2A%2A&X-Amz-Signature=%2A%2A%2A&x=1", redactURL(u))
+	assert.Equal(t, `{"session_token":"***","refresh_token":"***","a":"b"}`,
+		redactBody([]byte(`{"session_token":"t1","refresh_token":"t\"2","a":"b"}`)))
+	assert.Equal(t, `CREATE STAGE s URL='s3://b' CONNECTION=(access_key_id='***' secret_access_key = '***')`,
+		redactBody([]byte(`CREATE STAGE s URL='s3://b' CONNECTION=(access_key_id='k' secret_access_key = 's')`)))
+}
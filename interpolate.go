@@ -52,7 +52,7 @@ func interpolateParams2(query string, params []driver.Value, index []int) (strin
 	if len(params) == 0 {
 		return query, nil
 	}
-	if reflect.TypeOf(params[0]).Kind() == reflect.Slice {
+	if params[0] != nil && reflect.TypeOf(params[0]).Kind() == reflect.Slice {
 		if reflect.ValueOf(params[0]).Len() == 0 {
 			return query, nil
 		}
@@ -0,0 +1,183 @@
+package godatabend
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const defaultJWTLifetime = 10 * time.Minute
+
+// KeyPairAccessTokenLoader signs short-lived JWTs with a private key and uses them
+// as the access token, so that no long-lived password is needed. RSA (RS256),
+// ECDSA P-256 (ES256) and Ed25519 (EdDSA) keys are supported.
+type KeyPairAccessTokenLoader struct {
+	// PrivateKey is used to sign the token, it's loaded from PrivateKeyFile if nil.
+	PrivateKey     crypto.Signer
+	PrivateKeyFile string
+	// KeyID is set as the kid header of the token.
+	KeyID string
+	// User is set as the iss and sub claims of the token.
+	User     string
+	Audience string
+	// Claims are the extra claims added to the token.
+	Claims map[string]interface{}
+	// Lifetime of the signed token, default to 10 minutes.
+	Lifetime time.Duration
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+func NewKeyPairAccessTokenLoader(user string, privateKey crypto.Signer) *KeyPairAccessTokenLoader {
+	return &KeyPairAccessTokenLoader{
+		User:       user,
+		PrivateKey: privateKey,
+	}
+}
+
+func NewKeyPairFileAccessTokenLoader(user, privateKeyFile string) *KeyPairAccessTokenLoader {
+	return &KeyPairAccessTokenLoader{
+		User:           user,
+		PrivateKeyFile: privateKeyFile,
+	}
+}
+
+func (l *KeyPairAccessTokenLoader) LoadAccessToken(ctx context.Context, forceRotate bool) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	// refresh the token when less than 1/5 of its lifetime is left
+	lifetime := l.lifetime()
+	if !forceRotate && l.token != "" && time.Now().Add(lifetime/5).Before(l.expiry) {
+		return l.token, nil
+	}
+	if l.PrivateKey == nil {
+		key, err := LoadPrivateKeyFile(l.PrivateKeyFile)
+		if err != nil {
+			return "", err
+		}
+		l.PrivateKey = key
+	}
+	now := time.Now()
+	claims := map[string]interface{}{}
+	for k, v := range l.Claims {
+		claims[k] = v
+	}
+	claims["iss"] = l.User
+	claims["sub"] = l.User
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(lifetime).Unix()
+	if l.Audience != "" {
+		claims["aud"] = l.Audience
+	}
+	token, err := signJWT(l.PrivateKey, l.KeyID, claims)
+	if err != nil {
+		return "", err
+	}
+	l.token = token
+	l.expiry = now.Add(lifetime)
+	return l.token, nil
+}
+
+func (l *KeyPairAccessTokenLoader) lifetime() time.Duration {
+	if l.Lifetime > 0 {
+		return l.Lifetime
+	}
+	return defaultJWTLifetime
+}
+
+// LoadPrivateKeyFile loads a PEM encoded PKCS#8, PKCS#1 or SEC 1 private key.
+func LoadPrivateKeyFile(path string) (crypto.Signer, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read private key file")
+	}
+	block, _ := pem.Decode(buf)
+	if block == nil {
+		return nil, errors.New("no PEM data found in private key file")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, errors.Errorf("unsupported private key type %T", key)
+		}
+		return signer, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("failed to parse private key, only PKCS#8, PKCS#1 and SEC 1 keys are supported")
+}
+
+func signJWT(key crypto.Signer, keyID string, claims map[string]interface{}) (string, error) {
+	var alg string
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		alg = "RS256"
+	case *ecdsa.PrivateKey:
+		if k.Curve.Params().BitSize != 256 {
+			return "", errors.New("only P-256 ECDSA keys are supported")
+		}
+		alg = "ES256"
+	case ed25519.PrivateKey:
+		alg = "EdDSA"
+	default:
+		return "", errors.Errorf("unsupported private key type %T", key)
+	}
+	header := map[string]string{
+		"alg": alg,
+		"typ": "JWT",
+	}
+	if keyID != "" {
+		header["kid"] = keyID
+	}
+	headerBuf, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsBuf, err := json.Marshal(claims)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal jwt claims")
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(headerBuf) + "." + base64.RawURLEncoding.EncodeToString(claimsBuf)
+
+	var sig []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signingInput))
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signingInput))
+		var r, s *big.Int
+		if r, s, err = ecdsa.Sign(rand.Reader, k, digest[:]); err == nil {
+			// JWS uses the fixed size R || S form rather than ASN.1
+			sig = make([]byte, 64)
+			r.FillBytes(sig[:32])
+			s.FillBytes(sig[32:])
+		}
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(k, []byte(signingInput))
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "failed to sign jwt")
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
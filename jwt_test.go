@@ -0,0 +1,93 @@
+package godatabend
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyPairAccessTokenLoader(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	for _, key := range []crypto.Signer{rsaKey, ecKey, edKey} {
+		l := NewKeyPairAccessTokenLoader("u1", key)
+		l.KeyID = "k1"
+		l.Claims = map[string]interface{}{"tenant": "t1"}
+		token, err := l.LoadAccessToken(context.Background(), false)
+		require.NoError(t, err)
+
+		parts := strings.Split(token, ".")
+		require.Len(t, parts, 3)
+		header := map[string]string{}
+		decodeJWTPart(t, parts[0], &header)
+		assert.Equal(t, "k1", header["kid"])
+		claims := map[string]interface{}{}
+		decodeJWTPart(t, parts[1], &claims)
+		assert.Equal(t, "u1", claims["sub"])
+		assert.Equal(t, "t1", claims["tenant"])
+
+		sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+		require.NoError(t, err)
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		switch k := key.(type) {
+		case *rsa.PrivateKey:
+			assert.Equal(t, "RS256", header["alg"])
+			assert.NoError(t, rsa.VerifyPKCS1v15(&k.PublicKey, crypto.SHA256, digest[:], sig))
+		case *ecdsa.PrivateKey:
+			assert.Equal(t, "ES256", header["alg"])
+			r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+			assert.True(t, ecdsa.Verify(&k.PublicKey, digest[:], r, s))
+		case ed25519.PrivateKey:
+			assert.Equal(t, "EdDSA", header["alg"])
+			assert.True(t, ed25519.Verify(k.Public().(ed25519.PublicKey), []byte(parts[0]+"."+parts[1]), sig))
+		}
+
+		cached, err := l.LoadAccessToken(context.Background(), false)
+		require.NoError(t, err)
+		assert.Equal(t, token, cached)
+	}
+}
+
+func TestLoadPrivateKeyFile(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "key.pem")
+	buf := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	require.NoError(t, os.WriteFile(path, buf, 0600))
+
+	cfg, err := ParseDSN("databend://u1@localhost:8000/default?private_key_file=" + path + "&private_key_id=k1")
+	require.NoError(t, err)
+	c := NewAPIClientFromConfig(cfg)
+	assert.Equal(t, AuthMethodAccessToken, c.authMethod())
+	headers, err := c.makeHeaders(context.Background())
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(headers.Get(Authorization), "Bearer "))
+}
+
+func decodeJWTPart(t *testing.T, part string, v interface{}) {
+	buf, err := base64.RawURLEncoding.DecodeString(part)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(buf, v))
+}
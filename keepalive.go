@@ -0,0 +1,82 @@
+package godatabend
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	sessionHeartbeatPath = "/v1/session/heartbeat"
+	sessionLogoutPath    = "/v1/session/logout"
+
+	logoutTimeout = 5 * time.Second
+)
+
+// SessionHeartbeat refreshes the session on the server, so its states like the
+// temporary tables and the transaction do not expire while the connection is idle.
+func (c *APIClient) SessionHeartbeat(ctx context.Context) error {
+	request := struct {
+		Session *SessionState `json:"session"`
+	}{c.getSessionState()}
+	return c.doRequest(c.withStickyNode(ctx), "POST", sessionHeartbeatPath, request, nil)
+}
+
+// Logout closes the session on the server, so its temporary tables and other
+// resources are released at once instead of after the session expires. The session
+// tokens are dropped afterwards, the later queries log in again.
+func (c *APIClient) Logout(ctx context.Context) error {
+	if c.sessionToken != nil {
+		defer c.sessionToken.drop()
+	}
+	if !c.hasServerSession() {
+		return nil
+	}
+	request := struct {
+		Session *SessionState `json:"session"`
+	}{c.getSessionState()}
+	return c.doRequest(c.withStickyNode(ctx), "POST", sessionLogoutPath, request, nil)
+}
+
+// hasServerSession tells whether the server keeps anything for this session.
+func (c *APIClient) hasServerSession() bool {
+	if c.sessionToken != nil && c.sessionToken.loggedIn() {
+		return true
+	}
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	return c.needKeepAlive || c.isSticky()
+}
+
+// needsKeepAlive tells whether the server asked to keep the session alive.
+func (c *APIClient) needsKeepAlive() bool {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	return c.needKeepAlive
+}
+
+// startKeepAlive sends the heartbeats every interval while the session needs to be
+// kept alive, until the connection is closed.
+func (dc *DatabendConn) startKeepAlive(interval time.Duration) {
+	rest := dc.rest
+	done := make(chan struct{})
+	dc.keepAliveDone = done
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if !rest.needsKeepAlive() {
+					continue
+				}
+				ctx, cancel := context.WithTimeout(context.Background(), interval)
+				if err := rest.SessionHeartbeat(ctx); err != nil {
+					logger.Warnf("session heartbeat failed: %v", err)
+				}
+				cancel()
+			}
+		}
+	}()
+}
@@ -311,3 +311,45 @@ func context2Fields(ctx context.Context) *rlog.Fields {
 	}
 	return &fields
 }
+
+// Logger is the leveled structured logger set by Config.Logger, args are the
+// alternating keys and values of the attributes like slog. *slog.Logger implements it.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// dbLoggerAdapter writes the structured logs to the DBLogger of SetLogger, it's
+// used if Config.Logger is not set.
+type dbLoggerAdapter struct{}
+
+func (dbLoggerAdapter) Debug(msg string, args ...interface{}) {
+	logger.WithFields(args2Fields(args)).Debug(msg)
+}
+
+func (dbLoggerAdapter) Info(msg string, args ...interface{}) {
+	logger.WithFields(args2Fields(args)).Info(msg)
+}
+
+func (dbLoggerAdapter) Warn(msg string, args ...interface{}) {
+	logger.WithFields(args2Fields(args)).Warn(msg)
+}
+
+func (dbLoggerAdapter) Error(msg string, args ...interface{}) {
+	logger.WithFields(args2Fields(args)).Error(msg)
+}
+
+func args2Fields(args []interface{}) rlog.Fields {
+	fields := rlog.Fields{}
+	for i := 0; i < len(args); i += 2 {
+		key := fmt.Sprint(args[i])
+		if i+1 < len(args) {
+			fields[key] = args[i+1]
+		} else {
+			fields["!BADKEY"] = args[i]
+		}
+	}
+	return fields
+}
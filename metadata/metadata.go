@@ -0,0 +1,282 @@
+// Package metadata reads the tables, the columns, the views, the grants and the
+// processes of Databend from information_schema and the system tables as the structs.
+//
+// The columns of the system tables are looked up by their names, so the helpers keep
+// working when the server adds or reorders them, the fields of the missing columns
+// are left empty.
+package metadata
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	godatabend "github.com/datafuselabs/databend-go"
+)
+
+// Querier runs the queries of the helpers, it's implemented by *godatabend.APIClient.
+type Querier interface {
+	QuerySingle(ctx context.Context, query string, args []driver.Value, opts ...godatabend.QueryOption) (*godatabend.QueryResponse, error)
+}
+
+var _ Querier = (*godatabend.APIClient)(nil)
+
+// Table is a table or a view of information_schema.tables.
+type Table struct {
+	Catalog  string
+	Database string
+	Name     string
+	// Type is BASE TABLE, VIEW or SYSTEM VIEW.
+	Type    string
+	Engine  string
+	Comment string
+	Rows    uint64
+}
+
+// Column is a column of information_schema.columns, its type is parsed like the
+// results.
+type Column struct {
+	godatabend.Field
+	Database string
+	Table    string
+	// Position is the position of the column in the table, starting from 1.
+	Position int
+	// Default is the default expression of the column, empty if there is none.
+	Default string
+	Comment string
+}
+
+// View is a view of information_schema.views.
+type View struct {
+	Database   string
+	Name       string
+	Definition string
+}
+
+// Grant is a grant of SHOW GRANTS, the older servers only give the Statement.
+type Grant struct {
+	Privileges string
+	Object     string
+	// GrantTo is USER or ROLE, Name is the name of the user or the role.
+	GrantTo   string
+	Name      string
+	Statement string
+}
+
+// Process is a session of system.processes with the query it's running.
+type Process struct {
+	ID       string
+	Type     string
+	User     string
+	Host     string
+	Database string
+	Command  string
+	// SQL is the query being run, empty if the session is idle.
+	SQL         string
+	Status      string
+	MemoryUsage int64
+	Elapsed     time.Duration
+}
+
+// Tables lists the tables and the views of the database, or of all the databases if
+// it's empty.
+func Tables(ctx context.Context, q Querier, database string) ([]Table, error) {
+	query, args := withFilter("SELECT * FROM information_schema.tables", "table_schema", database)
+	rows, err := selectRows(ctx, q, query, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	tables := make([]Table, 0, len(rows.data))
+	for _, row := range rows.data {
+		t := Table{
+			Catalog:  rows.get(row, "table_catalog"),
+			Database: rows.get(row, "table_schema"),
+			Name:     rows.get(row, "table_name"),
+			Type:     rows.get(row, "table_type"),
+			Engine:   rows.get(row, "engine"),
+			Comment:  rows.get(row, "table_comment"),
+		}
+		if t.Rows, err = rows.uint(row, "table_rows"); err != nil {
+			return nil, fmt.Errorf("invalid rows of table %s: %w", t.Name, err)
+		}
+		tables = append(tables, t)
+	}
+	return tables, nil
+}
+
+// Columns lists the columns of the table in the database, table and database could be
+// empty to list the columns of all the tables.
+func Columns(ctx context.Context, q Querier, database, table string) ([]Column, error) {
+	query, args := withFilter("SELECT * FROM information_schema.columns", "table_schema", database)
+	if table != "" {
+		query, args = withFilter(query, "table_name", table, args...)
+	}
+	rows, err := selectRows(ctx, q, query, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list columns: %w", err)
+	}
+	columns := make([]Column, 0, len(rows.data))
+	for _, row := range rows.data {
+		name, typ := rows.get(row, "column_name"), rows.get(row, "data_type")
+		desc, err := godatabend.ParseSQLType(typ)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the type '%s' of %s: %w", typ, name, err)
+		}
+		col := Column{
+			Database: rows.get(row, "table_schema"),
+			Table:    rows.get(row, "table_name"),
+			Default:  rows.get(row, "column_default"),
+			Comment:  rows.get(row, "column_comment"),
+		}
+		col.Field = godatabend.NewField(name, desc)
+		if strings.EqualFold(rows.get(row, "is_nullable"), "YES") {
+			col.Nullable = true
+		}
+		position, err := rows.uint(row, "ordinal_position")
+		if err != nil {
+			return nil, fmt.Errorf("invalid position of column %s: %w", name, err)
+		}
+		col.Position = int(position)
+		columns = append(columns, col)
+	}
+	return columns, nil
+}
+
+// Views lists the views of the database, or of all the databases if it's empty.
+func Views(ctx context.Context, q Querier, database string) ([]View, error) {
+	query, args := withFilter("SELECT * FROM information_schema.views", "table_schema", database)
+	rows, err := selectRows(ctx, q, query, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list views: %w", err)
+	}
+	views := make([]View, 0, len(rows.data))
+	for _, row := range rows.data {
+		views = append(views, View{
+			Database:   rows.get(row, "table_schema"),
+			Name:       rows.get(row, "table_name"),
+			Definition: rows.get(row, "view_definition"),
+		})
+	}
+	return views, nil
+}
+
+// UserGrants lists the grants of the user, or of the current user if it's empty.
+func UserGrants(ctx context.Context, q Querier, user string) ([]Grant, error) {
+	if user == "" {
+		return grants(ctx, q, "SHOW GRANTS", nil)
+	}
+	return grants(ctx, q, "SHOW GRANTS FOR ?", []driver.Value{user})
+}
+
+// RoleGrants lists the grants of the role.
+func RoleGrants(ctx context.Context, q Querier, role string) ([]Grant, error) {
+	return grants(ctx, q, "SHOW GRANTS FOR ROLE ?", []driver.Value{role})
+}
+
+func grants(ctx context.Context, q Querier, query string, args []driver.Value) ([]Grant, error) {
+	rows, err := selectRows(ctx, q, query, args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list grants: %w", err)
+	}
+	grants := make([]Grant, 0, len(rows.data))
+	for _, row := range rows.data {
+		grants = append(grants, Grant{
+			Privileges: rows.get(row, "privileges"),
+			Object:     rows.get(row, "object_name"),
+			GrantTo:    rows.get(row, "grant_to"),
+			Name:       rows.get(row, "name"),
+			Statement:  rows.get(row, "grants"),
+		})
+	}
+	return grants, nil
+}
+
+// Processes lists the sessions of system.processes with the queries they are running.
+func Processes(ctx context.Context, q Querier) ([]Process, error) {
+	rows, err := selectRows(ctx, q, "SELECT * FROM system.processes", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list processes: %w", err)
+	}
+	processes := make([]Process, 0, len(rows.data))
+	for _, row := range rows.data {
+		p := Process{
+			ID:       rows.get(row, "id"),
+			Type:     rows.get(row, "type"),
+			User:     rows.get(row, "user"),
+			Host:     rows.get(row, "host"),
+			Database: rows.get(row, "database"),
+			Command:  rows.get(row, "command"),
+			SQL:      rows.get(row, "extra_info"),
+			Status:   rows.get(row, "status"),
+		}
+		memory, err := rows.int(row, "memory_usage")
+		if err != nil {
+			return nil, fmt.Errorf("invalid memory usage of process %s: %w", p.ID, err)
+		}
+		p.MemoryUsage = memory
+		elapsed, err := rows.uint(row, "time")
+		if err != nil {
+			return nil, fmt.Errorf("invalid time of process %s: %w", p.ID, err)
+		}
+		p.Elapsed = time.Duration(elapsed) * time.Second
+		processes = append(processes, p)
+	}
+	return processes, nil
+}
+
+// withFilter adds the condition of the column to the query if the value is set.
+func withFilter(query, column, value string, args ...driver.Value) (string, []driver.Value) {
+	if value == "" {
+		return query, args
+	}
+	if len(args) == 0 {
+		query += " WHERE "
+	} else {
+		query += " AND "
+	}
+	return query + column + " = ?", append(args, value)
+}
+
+// resultRows are the rows of the results with the columns indexed by their lower case
+// names.
+type resultRows struct {
+	columns map[string]int
+	data    [][]string
+}
+
+func selectRows(ctx context.Context, q Querier, query string, args []driver.Value) (*resultRows, error) {
+	resp, err := q.QuerySingle(ctx, query, args)
+	if err != nil {
+		return nil, err
+	}
+	rows := &resultRows{columns: make(map[string]int, len(resp.Schema)), data: resp.Data}
+	for i, field := range resp.Schema {
+		rows.columns[strings.ToLower(field.Name)] = i
+	}
+	return rows, nil
+}
+
+// get returns the cell of the column, empty if there is no such column or it's NULL.
+func (r *resultRows) get(row []string, name string) string {
+	if i, ok := r.columns[name]; ok && i < len(row) && row[i] != "NULL" {
+		return row[i]
+	}
+	return ""
+}
+
+func (r *resultRows) uint(row []string, name string) (uint64, error) {
+	if s := r.get(row, name); s != "" {
+		return strconv.ParseUint(s, 10, 64)
+	}
+	return 0, nil
+}
+
+func (r *resultRows) int(row []string, name string) (int64, error) {
+	if s := r.get(row, name); s != "" {
+		return strconv.ParseInt(s, 10, 64)
+	}
+	return 0, nil
+}
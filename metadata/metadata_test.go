@@ -0,0 +1,95 @@
+package metadata
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	godatabend "github.com/datafuselabs/databend-go"
+)
+
+type fakeQuerier struct {
+	queries []string
+	args    [][]driver.Value
+	resp    *godatabend.QueryResponse
+}
+
+func (f *fakeQuerier) QuerySingle(ctx context.Context, query string, args []driver.Value, opts ...godatabend.QueryOption) (*godatabend.QueryResponse, error) {
+	f.queries = append(f.queries, query)
+	f.args = append(f.args, args)
+	return f.resp, nil
+}
+
+func schema(names ...string) []godatabend.DataField {
+	fields := make([]godatabend.DataField, len(names))
+	for i, name := range names {
+		fields[i] = godatabend.DataField{Name: name, Type: "String"}
+	}
+	return fields
+}
+
+func TestTables(t *testing.T) {
+	q := &fakeQuerier{resp: &godatabend.QueryResponse{
+		Schema: schema("table_catalog", "table_schema", "table_name", "table_type", "engine", "table_rows", "new_column"),
+		Data:   [][]string{{"default", "db", "t", "BASE TABLE", "FUSE", "3", "x"}, {"default", "db", "v", "VIEW", "VIEW", "NULL", "y"}},
+	}}
+	tables, err := Tables(context.Background(), q, "db")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"SELECT * FROM information_schema.tables WHERE table_schema = ?"}, q.queries)
+	assert.Equal(t, []driver.Value{"db"}, q.args[0])
+	assert.Equal(t, []Table{
+		{Catalog: "default", Database: "db", Name: "t", Type: "BASE TABLE", Engine: "FUSE", Rows: 3},
+		{Catalog: "default", Database: "db", Name: "v", Type: "VIEW", Engine: "VIEW"},
+	}, tables)
+}
+
+func TestColumns(t *testing.T) {
+	q := &fakeQuerier{resp: &godatabend.QueryResponse{
+		Schema: schema("table_schema", "table_name", "column_name", "ordinal_position", "column_default", "is_nullable", "data_type", "column_comment"),
+		Data: [][]string{
+			{"db", "t", "a", "1", "NULL", "NO", "BIGINT UNSIGNED", "id"},
+			{"db", "t", "b", "2", "'x'", "YES", "VARCHAR", ""},
+		},
+	}}
+	columns, err := Columns(context.Background(), q, "db", "t")
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM information_schema.columns WHERE table_schema = ? AND table_name = ?", q.queries[0])
+	assert.Equal(t, []driver.Value{"db", "t"}, q.args[0])
+	if assert.Len(t, columns, 2) {
+		assert.Equal(t, "a", columns[0].Name)
+		assert.Equal(t, "UInt64", columns[0].TypeName())
+		assert.Equal(t, 1, columns[0].Position)
+		assert.Equal(t, "", columns[0].Default)
+		assert.Equal(t, "id", columns[0].Comment)
+		assert.Equal(t, "Nullable(String)", columns[1].TypeName())
+		assert.Equal(t, "'x'", columns[1].Default)
+	}
+}
+
+func TestGrantsAndProcesses(t *testing.T) {
+	q := &fakeQuerier{resp: &godatabend.QueryResponse{
+		Schema: schema("grants"),
+		Data:   [][]string{{"GRANT SELECT ON 'default'.'db'.* TO 'u'@'%'"}},
+	}}
+	grants, err := UserGrants(context.Background(), q, "u")
+	require.NoError(t, err)
+	assert.Equal(t, "SHOW GRANTS FOR ?", q.queries[0])
+	assert.Equal(t, []Grant{{Statement: "GRANT SELECT ON 'default'.'db'.* TO 'u'@'%'"}}, grants)
+
+	_, err = RoleGrants(context.Background(), q, "r")
+	require.NoError(t, err)
+	assert.Equal(t, "SHOW GRANTS FOR ROLE ?", q.queries[1])
+
+	q.resp = &godatabend.QueryResponse{
+		Schema: schema("id", "type", "user", "command", "extra_info", "memory_usage", "time", "status"),
+		Data:   [][]string{{"s1", "HTTPQuery", "root", "Query", "SELECT 1", "1024", "5", "Running"}},
+	}
+	processes, err := Processes(context.Background(), q)
+	require.NoError(t, err)
+	assert.Equal(t, []Process{{ID: "s1", Type: "HTTPQuery", User: "root", Command: "Query", SQL: "SELECT 1",
+		Status: "Running", MemoryUsage: 1024, Elapsed: 5 * time.Second}}, processes)
+}
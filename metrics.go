@@ -0,0 +1,88 @@
+package godatabend
+
+import (
+	"time"
+)
+
+// Metrics receives the measurements of the driver set by Config.Metrics, so they can
+// be exported to Prometheus or OpenMetrics. Its methods are called synchronously on
+// the request path, they must be cheap and safe for concurrent use.
+type Metrics interface {
+	// QueryFinished is called when the last page of a query is fetched or the query
+	// fails, latency is the time since the query request is sent.
+	QueryFinished(queryID string, latency time.Duration, stats QueryStats, err error)
+	// PageFetched is called for the response of each query or page request with the
+	// rows and bytes in it.
+	PageFetched(queryID string, latency time.Duration, rows int, bytes int)
+	// RequestRetried is called before a failed request of the request type is retried.
+	RequestRetried(t RequestType, err error)
+	// StageUploaded is called when an upload to the stage is done, bytes is the size
+	// of the uploaded data.
+	StageUploaded(bytes int64, latency time.Duration, err error)
+}
+
+// inflightMetrics is implemented by the Metrics counting the queries in flight.
+type inflightMetrics interface {
+	addInflight(delta int64)
+}
+
+func (c *APIClient) addInflight(delta int64) {
+	if m, ok := c.metrics.(inflightMetrics); ok {
+		m.addInflight(delta)
+	}
+}
+
+// trackQuery remembers when the query is sent, until it's finished.
+func (c *APIClient) trackQuery(resp *QueryResponse, start time.Time, latency time.Duration) {
+	if c.metrics == nil {
+		return
+	}
+	c.metrics.PageFetched(resp.ID, latency, len(resp.Data), resp.size)
+	if resp.NextURI == "" {
+		c.metrics.QueryFinished(resp.ID, latency, resp.Stats, nil)
+		return
+	}
+	if _, loaded := c.queryStarts.LoadOrStore(resp.ID, start); !loaded {
+		c.addInflight(1)
+	}
+}
+
+func (c *APIClient) trackPage(resp *QueryResponse, latency time.Duration) {
+	if c.metrics == nil {
+		return
+	}
+	c.metrics.PageFetched(resp.ID, latency, len(resp.Data), resp.size)
+	if resp.NextURI != "" && resp.Error == nil {
+		return
+	}
+	if start, ok := c.queryStarts.LoadAndDelete(resp.ID); ok {
+		c.addInflight(-1)
+		var err error
+		if resp.Error != nil {
+			err = resp.Error
+		}
+		c.metrics.QueryFinished(resp.ID, time.Since(start.(time.Time)), resp.Stats, err)
+	}
+}
+
+// failQuery reports the query which fails before it's started or finished, start
+// is zero if the query has been started.
+func (c *APIClient) failQuery(queryID string, start time.Time, err error) {
+	if c.metrics == nil {
+		return
+	}
+	if s, ok := c.queryStarts.LoadAndDelete(queryID); ok {
+		c.addInflight(-1)
+		start = s.(time.Time)
+	} else if start.IsZero() {
+		return
+	}
+	c.metrics.QueryFinished(queryID, time.Since(start), QueryStats{}, err)
+}
+
+// forgetQuery drops the query which is abandoned before its last page.
+func (c *APIClient) forgetQuery(queryID string) {
+	if _, ok := c.queryStarts.LoadAndDelete(queryID); ok {
+		c.addInflight(-1)
+	}
+}
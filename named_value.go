@@ -0,0 +1,122 @@
+package godatabend
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math/big"
+	"reflect"
+	"time"
+)
+
+var _ driver.NamedValueChecker = (*DatabendConn)(nil)
+
+// CheckNamedValue implements driver.NamedValueChecker, so the args of the types
+// database/sql does not know, like slices, maps, structs and big numbers, reach the
+// driver. They are encoded to the SQL literals, which are interpolated as is.
+func (dc *DatabendConn) CheckNamedValue(nv *driver.NamedValue) error {
+	v, err := checkValue(nv.Value)
+	if e, ok := err.(*unsupportedTypeError); ok {
+		arg := fmt.Sprintf("$%d", nv.Ordinal)
+		if nv.Name != "" {
+			arg = ":" + nv.Name
+		}
+		elem := ""
+		if e.typ != reflect.TypeOf(nv.Value) {
+			elem = fmt.Sprintf(" (of %s)", e.typ)
+		}
+		return fmt.Errorf("databend: unsupported type %T%s of arg %s, the args could be the basic types, "+
+			"time.Time, *big.Int, *big.Float, slices, arrays, maps, structs of them or a driver.Valuer", nv.Value, elem, arg)
+	}
+	if err != nil {
+		return err
+	}
+	nv.Value = v
+	return nil
+}
+
+func checkValue(value interface{}) (driver.Value, error) {
+	switch v := value.(type) {
+	case nil, bool, string, []byte, time.Time,
+		int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64,
+		float32, float64:
+		return v, nil
+	case driver.Valuer:
+		// the default converter calls Value
+		return nil, driver.ErrSkip
+	case *big.Int:
+		if v == nil {
+			return nil, nil
+		}
+		return []byte(v.String()), nil
+	case *big.Float:
+		if v == nil {
+			return nil, nil
+		}
+		return []byte(v.Text('f', -1)), nil
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return checkValue(rv.Elem().Interface())
+	case reflect.Bool, reflect.String, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		// the named types of the basic kinds are converted by the default converter
+		return nil, driver.ErrSkip
+	}
+	if t := unsupportedType(rv.Type(), map[reflect.Type]bool{}); t != nil {
+		return nil, &unsupportedTypeError{typ: t}
+	}
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		return textEncode.Encode(array{v: value})
+	case reflect.Map:
+		return textEncode.Encode(tmap{v: value})
+	default:
+		return textEncode.Encode(tuple{v: value})
+	}
+}
+
+type unsupportedTypeError struct {
+	typ reflect.Type
+}
+
+func (e *unsupportedTypeError) Error() string {
+	return fmt.Sprintf("unsupported type %s", e.typ)
+}
+
+// unsupportedType returns the type in t which could not be encoded, like a channel
+// or a func, so they are rejected before the query is sent rather than printed as
+// the addresses.
+func unsupportedType(t reflect.Type, seen map[reflect.Type]bool) reflect.Type {
+	if seen[t] || t == reflect.TypeOf(time.Time{}) || t.Implements(reflect.TypeOf((*driver.Valuer)(nil)).Elem()) {
+		return nil
+	}
+	seen[t] = true
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return unsupportedType(t.Elem(), seen)
+	case reflect.Map:
+		if u := unsupportedType(t.Key(), seen); u != nil {
+			return u
+		}
+		return unsupportedType(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if f := t.Field(i); f.IsExported() || f.Anonymous {
+				if u := unsupportedType(f.Type, seen); u != nil {
+					return u
+				}
+			}
+		}
+		return nil
+	case reflect.Chan, reflect.Func, reflect.Complex64, reflect.Complex128, reflect.Uintptr, reflect.UnsafePointer:
+		return t
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package godatabend
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckNamedValue(t *testing.T) {
+	var gotSQL string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req QueryRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		gotSQL = req.SQL
+		_, _ = w.Write([]byte(`{"id":"q1","state":"Succeeded"}`))
+	}))
+	defer ts.Close()
+
+	db, err := sql.Open("databend", "databend://root:root@"+strings.TrimPrefix(ts.URL, "http://")+"/default?sslmode=disable")
+	require.NoError(t, err)
+	defer db.Close()
+
+	type point struct {
+		X int
+		Y string
+	}
+	id := uuid.MustParse("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	n, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	ts0 := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	var nilPtr *int
+	_, err = db.Exec("INSERT INTO t VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		[]int{1, 2}, map[string]int{"a": 1}, point{1, "p"}, id, n, ts0, nilPtr, uint64(1<<63))
+	require.NoError(t, err)
+	assert.Equal(t, "INSERT INTO t VALUES ([1,2], map('a',1), (1,'p'), '6ba7b810-9dad-11d1-80b4-00c04fd430c8', "+
+		"123456789012345678901234567890, '2024-01-02 03:04:05', NULL, 9223372036854775808)", gotSQL)
+}
+
+func TestCheckNamedValueUnsupported(t *testing.T) {
+	dc := &DatabendConn{}
+	for _, c := range []struct {
+		nv  driver.NamedValue
+		err string
+	}{
+		{driver.NamedValue{Ordinal: 2, Value: make(chan int)}, "unsupported type chan int of arg $2"},
+		{driver.NamedValue{Ordinal: 1, Name: "f", Value: func() {}}, "unsupported type func() of arg :f"},
+		{driver.NamedValue{Ordinal: 1, Value: []complex128{1}}, "unsupported type []complex128 (of complex128) of arg $1"},
+		{driver.NamedValue{Ordinal: 3, Value: struct{ C chan int }{}}, "unsupported type struct { C chan int } (of chan int) of arg $3"},
+	} {
+		err := dc.CheckNamedValue(&c.nv)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), c.err)
+	}
+
+	type level int
+	nv := driver.NamedValue{Ordinal: 1, Value: level(1)}
+	assert.Equal(t, driver.ErrSkip, dc.CheckNamedValue(&nv))
+}
@@ -0,0 +1,41 @@
+package godatabend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// QueryLostError is returned when the node running the query is gone, like it has
+// been restarted, and the query can not be run again safely, since some results
+// have been returned or it's a write without a deduplicate label.
+type QueryLostError struct {
+	QueryID string
+	Err     error
+}
+
+func (e *QueryLostError) Error() string {
+	return fmt.Sprintf("query %s is lost with its node: %v", e.QueryID, e.Err)
+}
+
+func (e *QueryLostError) Unwrap() error {
+	return e.Err
+}
+
+// isQueryLost tells whether a page request of a started query failed since the
+// node running the query is gone.
+func isQueryLost(err error) bool {
+	var connErr connectError
+	return IsNotFound(err) || errors.As(err, &connErr)
+}
+
+// canRerun tells whether the statement could be run again from the start, the
+// session state is kept by the client, so it's safe for the reads, and for the
+// writes deduplicated by the labels of ctx, which are kept by the reruns.
+func canRerun(ctx context.Context, sql string) bool {
+	if !isWriteQuery(sql) {
+		return true
+	}
+	label, _ := ctx.Value(ContextKeyDeduplicateLabel).(string)
+	return label != ""
+}
@@ -0,0 +1,96 @@
+package godatabend
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRerunAfterNodeLost(t *testing.T) {
+	queries, pages := 0, 0
+	var labels []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/query" {
+			queries++
+			labels = append(labels, r.Header.Get(DatabendDeduplicateLabelHeader))
+			_, _ = w.Write([]byte(`{"id":"q1","next_uri":"/v1/query/q1/page/1"}`))
+			return
+		}
+		pages++
+		if pages == 1 {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":"query id q1 not found"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"q1","data":[["1"]]}`))
+	}))
+	defer ts.Close()
+
+	c := APIClient{
+		cli:         &http.Client{},
+		apiEndpoint: ts.URL,
+		user:        "root",
+	}
+	querySync := func(ctx context.Context, sql string) error {
+		respCh := make(chan QueryResponse)
+		go func() {
+			for range respCh {
+			}
+		}()
+		defer close(respCh)
+		return c.QuerySync(ctx, sql, nil, respCh)
+	}
+
+	assert.NoError(t, querySync(context.Background(), "SELECT 1"))
+	assert.Equal(t, 2, queries)
+
+	// the write is run again with the same label
+	queries, pages, labels = 0, 0, nil
+	assert.NoError(t, querySync(context.Background(), "INSERT INTO t VALUES (1)"))
+	assert.Equal(t, 2, queries)
+	require.Len(t, labels, 2)
+	assert.NotEmpty(t, labels[0])
+	assert.Equal(t, labels[0], labels[1])
+
+	// a write without a deduplicate label is not run again
+	queries, pages = 0, 0
+	err := querySync(WithDeduplicateLabel(context.Background(), ""), "INSERT INTO t VALUES (1)")
+	var lostErr *QueryLostError
+	assert.True(t, errors.As(err, &lostErr))
+	assert.Equal(t, "q1", lostErr.QueryID)
+	assert.True(t, IsNotFound(err))
+	assert.Equal(t, 1, queries)
+}
+
+func TestRowsNodeLost(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":"query id q1 not found"}`))
+	}))
+	defer ts.Close()
+
+	cfg, err := ParseDSN("databend://root:root@" + strings.TrimPrefix(ts.URL, "http://") + "/default?sslmode=disable")
+	require.NoError(t, err)
+	dc, err := buildDatabendConn(context.Background(), *cfg)
+	require.NoError(t, err)
+	defer dc.Close()
+
+	// no rows have been returned, the error is kept as is
+	rows := &nextRows{dc: dc, ctx: context.Background(), respData: &QueryResponse{ID: "q1", NextURI: "/v1/query/q1/page/1"}}
+	err = rows.nextPage()
+	assert.True(t, IsNotFound(err))
+	var lostErr *QueryLostError
+	assert.False(t, errors.As(err, &lostErr))
+
+	rows = &nextRows{dc: dc, ctx: context.Background(), respData: &QueryResponse{ID: "q1", NextURI: "/v1/query/q1/page/1"}}
+	rows.delivered = true
+	err = rows.nextPage()
+	assert.True(t, errors.As(err, &lostErr))
+	assert.Equal(t, "q1", lostErr.QueryID)
+}
@@ -0,0 +1,183 @@
+package godatabend
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// otelMeters shares the instruments of each MeterProvider among the clients.
+var otelMeters sync.Map
+
+// otelMetrics implements Metrics with the OpenTelemetry instruments.
+type otelMetrics struct {
+	queryDuration   metric.Float64Histogram
+	requestDuration metric.Float64Histogram
+	rows            metric.Int64Counter
+	bytes           metric.Int64Counter
+	retries         metric.Int64Counter
+	uploadBytes     metric.Int64Counter
+	uploadDuration  metric.Float64Histogram
+	// inflight counts the queries of the clients of the MeterProvider.
+	inflight metric.Int64UpDownCounter
+}
+
+func (cfg *Config) meterProvider() metric.MeterProvider {
+	if cfg.MeterProvider != nil {
+		return cfg.MeterProvider
+	}
+	return otel.GetMeterProvider()
+}
+
+// getOTelMetrics returns the instruments of the MeterProvider of cfg, it's nil if
+// the OpenTelemetry metrics are not enabled.
+func getOTelMetrics(cfg *Config) (*otelMetrics, error) {
+	if !cfg.EnableOTelMetrics {
+		return nil, nil
+	}
+	provider := cfg.meterProvider()
+	if m, ok := otelMeters.Load(provider); ok {
+		return m.(*otelMetrics), nil
+	}
+	m, err := newOTelMetrics(provider.Meter(tracerName, metric.WithInstrumentationVersion(version)))
+	if err != nil {
+		return nil, err
+	}
+	actual, _ := otelMeters.LoadOrStore(provider, m)
+	return actual.(*otelMetrics), nil
+}
+
+func newOTelMetrics(meter metric.Meter) (*otelMetrics, error) {
+	m := &otelMetrics{}
+	var err error
+	if m.queryDuration, err = meter.Float64Histogram("databend.client.query.duration",
+		metric.WithUnit("ms"),
+		metric.WithDescription("duration of the queries from the query request to the last page")); err != nil {
+		return nil, err
+	}
+	if m.requestDuration, err = meter.Float64Histogram("databend.client.request.duration",
+		metric.WithUnit("ms"),
+		metric.WithDescription("duration of the query and page requests")); err != nil {
+		return nil, err
+	}
+	if m.rows, err = meter.Int64Counter("databend.client.rows",
+		metric.WithDescription("rows received in the pages")); err != nil {
+		return nil, err
+	}
+	if m.bytes, err = meter.Int64Counter("databend.client.bytes",
+		metric.WithUnit("By"),
+		metric.WithDescription("bytes received in the pages")); err != nil {
+		return nil, err
+	}
+	if m.retries, err = meter.Int64Counter("databend.client.retries",
+		metric.WithDescription("retried requests")); err != nil {
+		return nil, err
+	}
+	if m.uploadBytes, err = meter.Int64Counter("databend.client.upload.bytes",
+		metric.WithUnit("By"),
+		metric.WithDescription("bytes uploaded to the stages")); err != nil {
+		return nil, err
+	}
+	if m.uploadDuration, err = meter.Float64Histogram("databend.client.upload.duration",
+		metric.WithUnit("ms"),
+		metric.WithDescription("duration of the uploads to the stages")); err != nil {
+		return nil, err
+	}
+	if m.inflight, err = meter.Int64UpDownCounter("databend.client.queries.inflight",
+		metric.WithDescription("queries started but not finished yet")); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func statusAttr(err error) attribute.KeyValue {
+	if err != nil {
+		return attribute.String("status", "error")
+	}
+	return attribute.String("status", "ok")
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func (m *otelMetrics) QueryFinished(queryID string, latency time.Duration, stats QueryStats, err error) {
+	m.queryDuration.Record(context.Background(), milliseconds(latency), metric.WithAttributes(statusAttr(err)))
+}
+
+func (m *otelMetrics) PageFetched(queryID string, latency time.Duration, rows int, bytes int) {
+	ctx := context.Background()
+	m.requestDuration.Record(ctx, milliseconds(latency))
+	m.rows.Add(ctx, int64(rows))
+	m.bytes.Add(ctx, int64(bytes))
+}
+
+func (m *otelMetrics) RequestRetried(t RequestType, err error) {
+	m.retries.Add(context.Background(), 1, metric.WithAttributes(attribute.String("type", t.String())))
+}
+
+func (m *otelMetrics) StageUploaded(bytes int64, latency time.Duration, err error) {
+	ctx := context.Background()
+	if err == nil {
+		m.uploadBytes.Add(ctx, bytes)
+	}
+	m.uploadDuration.Record(ctx, milliseconds(latency), metric.WithAttributes(statusAttr(err)))
+}
+
+func (m *otelMetrics) addInflight(delta int64) {
+	m.inflight.Add(context.Background(), delta)
+}
+
+// multiMetrics reports to all the Metrics in order.
+type multiMetrics []Metrics
+
+func (ms multiMetrics) QueryFinished(queryID string, latency time.Duration, stats QueryStats, err error) {
+	for _, m := range ms {
+		m.QueryFinished(queryID, latency, stats, err)
+	}
+}
+
+func (ms multiMetrics) PageFetched(queryID string, latency time.Duration, rows int, bytes int) {
+	for _, m := range ms {
+		m.PageFetched(queryID, latency, rows, bytes)
+	}
+}
+
+func (ms multiMetrics) RequestRetried(t RequestType, err error) {
+	for _, m := range ms {
+		m.RequestRetried(t, err)
+	}
+}
+
+func (ms multiMetrics) StageUploaded(bytes int64, latency time.Duration, err error) {
+	for _, m := range ms {
+		m.StageUploaded(bytes, latency, err)
+	}
+}
+
+func (ms multiMetrics) addInflight(delta int64) {
+	for _, m := range ms {
+		if m, ok := m.(inflightMetrics); ok {
+			m.addInflight(delta)
+		}
+	}
+}
+
+// newMetrics combines the Metrics of cfg and the OpenTelemetry metrics.
+func newMetrics(cfg *Config) (Metrics, error) {
+	m, err := getOTelMetrics(cfg)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case m == nil:
+		return cfg.Metrics, nil
+	case cfg.Metrics == nil:
+		return m, nil
+	}
+	return multiMetrics{cfg.Metrics, m}, nil
+}
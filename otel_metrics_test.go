@@ -0,0 +1,128 @@
+package godatabend
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+// fakeMeterProvider records the sums of the int64 counters.
+type fakeMeterProvider struct {
+	noop.MeterProvider
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+func (p *fakeMeterProvider) Meter(string, ...metric.MeterOption) metric.Meter {
+	return fakeMeter{p: p}
+}
+
+func (p *fakeMeterProvider) count(name string) int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.counts[name]
+}
+
+type fakeMeter struct {
+	noop.Meter
+	p *fakeMeterProvider
+}
+
+func (m fakeMeter) Int64Counter(name string, _ ...metric.Int64CounterOption) (metric.Int64Counter, error) {
+	return fakeCounter{name: name, p: m.p}, nil
+}
+
+func (m fakeMeter) Int64UpDownCounter(name string, _ ...metric.Int64UpDownCounterOption) (metric.Int64UpDownCounter, error) {
+	return fakeCounter{name: name, p: m.p}, nil
+}
+
+type fakeCounter struct {
+	noop.Int64Counter
+	noop.Int64UpDownCounter
+	name string
+	p    *fakeMeterProvider
+}
+
+func (c fakeCounter) Add(_ context.Context, incr int64, _ ...metric.AddOption) {
+	c.p.mu.Lock()
+	defer c.p.mu.Unlock()
+	c.p.counts[c.name] += incr
+}
+
+func TestOTelMetrics(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"q1","data":[["1"],["2"]]}`))
+	}))
+	defer ts.Close()
+
+	provider := &fakeMeterProvider{counts: map[string]int64{}}
+	m := &recordMetrics{}
+	cfg := NewConfig()
+	cfg.Host = strings.TrimPrefix(ts.URL, "http://")
+	cfg.SSLMode = SSL_MODE_DISABLE
+	cfg.User = "root"
+	cfg.EnableOTelMetrics = true
+	cfg.MeterProvider = provider
+	cfg.Metrics = m
+	cfg.QueryRetryPolicy = RetryPolicy{Delay: time.Millisecond}
+	c, err := NewAPIClient(cfg)
+	require.NoError(t, err)
+
+	err = c.QuerySync(context.Background(), "SELECT 1", nil, make(chan QueryResponse, 1))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), provider.count("databend.client.retries"))
+	assert.Equal(t, int64(2), provider.count("databend.client.rows"))
+	// the Metrics of the config is still called
+	assert.Equal(t, []string{"q1"}, m.queries)
+}
+
+func TestOTelMetricsInflightPerProvider(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/v1/query/") {
+			_, _ = w.Write([]byte(`{"id":"q1","data":[["2"]]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"q1","data":[["1"]],"next_uri":"/v1/query/q1/page/1"}`))
+	}))
+	defer ts.Close()
+
+	newClient := func(provider metric.MeterProvider) *APIClient {
+		cfg := NewConfig()
+		cfg.Host = strings.TrimPrefix(ts.URL, "http://")
+		cfg.SSLMode = SSL_MODE_DISABLE
+		cfg.User = "root"
+		cfg.EnableOTelMetrics = true
+		cfg.MeterProvider = provider
+		c, err := NewAPIClient(cfg)
+		require.NoError(t, err)
+		return c
+	}
+	p1 := &fakeMeterProvider{counts: map[string]int64{}}
+	p2 := &fakeMeterProvider{counts: map[string]int64{}}
+	c1 := newClient(p1)
+	_ = newClient(p2)
+
+	resp, err := c1.DoQuery(context.Background(), "SELECT 1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), p1.count("databend.client.queries.inflight"))
+	assert.Equal(t, int64(0), p2.count("databend.client.queries.inflight"))
+
+	_, err = c1.WaitForQuery(context.Background(), resp)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), p1.count("databend.client.queries.inflight"))
+}
@@ -0,0 +1,104 @@
+package godatabend
+
+import (
+	"context"
+	"io"
+)
+
+// ResultPage is a page of the results as it's received, the cells are the text of
+// the values, which are decoded by the users, like with the parsers of Schema.
+type ResultPage struct {
+	// Index counts the pages with rows from 0.
+	Index int
+	Data  [][]string
+	// Stats is the progress of the query when the page is received.
+	Stats QueryStats
+
+	nulls [][]bool
+}
+
+// IsNull tells whether the cell is NULL, the NULL cells are empty in Data.
+func (p *ResultPage) IsNull(row, col int) bool {
+	return row < len(p.nulls) && col < len(p.nulls[row]) && p.nulls[row][col]
+}
+
+// Pages is the iterator of the pages of Client.QueryPages, so the pages could be
+// decoded, checkpointed or handed to the workers without parsing each row.
+type Pages struct {
+	rows    *nextRows
+	page    *ResultPage
+	index   int
+	started bool
+	err     error
+	closed  bool
+}
+
+// QueryPages runs the query and returns its pages, which must be closed before the
+// next query of the Client.
+func (c *Client) QueryPages(ctx context.Context, query string, args ...interface{}) (*Pages, error) {
+	rows, err := c.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return &Pages{rows: rows.rows}, nil
+}
+
+// Next waits for the next page with rows, it returns false after the last page or
+// an error, which is returned by Err.
+func (p *Pages) Next() bool {
+	if p.closed {
+		return false
+	}
+	if p.started {
+		p.rows.respData.Data, p.rows.respData.nulls = nil, nil
+		if p.rows.respData.NextURI != "" {
+			if err := p.rows.nextPage(); err != nil {
+				p.err = err
+				_ = p.Close()
+				return false
+			}
+		}
+	}
+	p.started = true
+	resp := p.rows.respData
+	if len(resp.Data) == 0 {
+		if err := p.rows.done(); err != io.EOF {
+			p.err = err
+		}
+		_ = p.Close()
+		return false
+	}
+	p.page = &ResultPage{Index: p.index, Data: resp.Data, Stats: resp.Stats, nulls: resp.nulls}
+	p.index++
+	p.rows.delivered = true
+	return true
+}
+
+// Page returns the current page, it's not changed by the next call of Next.
+func (p *Pages) Page() *ResultPage {
+	return p.page
+}
+
+// Err returns the error met by Next.
+func (p *Pages) Err() error {
+	return p.err
+}
+
+// Schema returns the columns with their types parsed.
+func (p *Pages) Schema() Schema {
+	return p.rows.schema
+}
+
+// QueryID returns the id of the query assigned by the server.
+func (p *Pages) QueryID() string {
+	return p.rows.QueryID()
+}
+
+// Close ends the query, the rest of the pages are discarded.
+func (p *Pages) Close() error {
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	return p.rows.Close()
+}
@@ -0,0 +1,50 @@
+package godatabend
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientQueryPages(t *testing.T) {
+	pages := map[string]string{
+		"/v1/query":           `{"id":"q1","state":"Running","schema":[{"name":"a","type":"Int64"}],"data":[["1"],["2"]],"next_uri":"/v1/query/q1/page/1"}`,
+		"/v1/query/q1/page/1": `{"id":"q1","state":"Running","data":[],"next_uri":"/v1/query/q1/page/2"}`,
+		"/v1/query/q1/page/2": `{"id":"q1","state":"Running","data":[["3"]],"stats":{"result_progress":{"rows":3}},"next_uri":"/v1/query/q1/page/3"}`,
+		"/v1/query/q1/page/3": `{"id":"q1","state":"Succeeded","data":[]}`,
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(pages[r.URL.Path]))
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	cfg, err := ParseDSN("databend://root:root@" + strings.TrimPrefix(ts.URL, "http://") + "/default?sslmode=disable")
+	require.NoError(t, err)
+	client, err := Open(ctx, cfg)
+	require.NoError(t, err)
+	defer client.Close()
+
+	it, err := client.QueryPages(ctx, "SELECT a FROM t")
+	require.NoError(t, err)
+	defer it.Close()
+	assert.Equal(t, "q1", it.QueryID())
+	assert.Equal(t, []string{"a"}, it.Schema().Names())
+
+	var got []*ResultPage
+	for it.Next() {
+		got = append(got, it.Page())
+	}
+	require.NoError(t, it.Err())
+	require.Len(t, got, 2)
+	assert.Equal(t, 0, got[0].Index)
+	assert.Equal(t, [][]string{{"1"}, {"2"}}, got[0].Data)
+	assert.Equal(t, 1, got[1].Index)
+	assert.Equal(t, [][]string{{"3"}}, got[1].Data)
+	assert.Equal(t, uint64(3), got[1].Stats.ResultProgress.Rows)
+}
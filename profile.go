@@ -0,0 +1,110 @@
+package godatabend
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+)
+
+// The environment variables of the connection profiles.
+const (
+	EnvProfile     = "DATABEND_PROFILE"
+	EnvProfileFile = "DATABEND_PROFILE_FILE"
+)
+
+// defaultProfileFile is the profile file used without profile_file and
+// DATABEND_PROFILE_FILE, relative to the home directory.
+const defaultProfileFile = ".config/databend/profiles.toml"
+
+// A profile file has a table for each profile, the keys host, port, user, password
+// and database set the address and the credentials, the others are the DSN params,
+// and the nested tables like settings are flattened into them:
+//
+//	[prod]
+//	host = "tenant--warehouse.gw.databend.com"
+//	user = "app"
+//	password = "secret"
+//	warehouse = "wh"
+//
+//	[prod.settings]
+//	max_threads = 4
+type profile map[string]interface{}
+
+// profilePath returns the profile file to read, by the DSN param, the environment
+// variable, or the default one in the home directory.
+func profilePath(file string) (string, error) {
+	if file != "" {
+		return file, nil
+	}
+	if file, ok := lookupEnv(EnvProfileFile); ok {
+		return file, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, defaultProfileFile), nil
+}
+
+// loadProfile reads the profile of the name from the file.
+func loadProfile(file, name string) (profile, error) {
+	path, err := profilePath(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "locate the profile file")
+	}
+	var profiles map[string]profile
+	if _, err = toml.DecodeFile(path, &profiles); err != nil {
+		return nil, errors.Wrapf(err, "read the profile file %s", path)
+	}
+	p, ok := profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("no profile '%s' in %s", name, path)
+	}
+	return p, nil
+}
+
+// applyProfile sets the config with the profile, the DSN overrides it afterwards.
+func (cfg *Config) applyProfile(p profile) error {
+	params := make(map[string]string)
+	var port string
+	for k, v := range p {
+		switch k {
+		case "host":
+			cfg.Host = profileValue(v)
+		case "port":
+			port = profileValue(v)
+		case "user":
+			cfg.User = profileValue(v)
+		case "password":
+			cfg.Password = profileValue(v)
+		case "database":
+			cfg.Database = profileValue(v)
+		default:
+			if table, ok := v.(map[string]interface{}); ok {
+				for k, v := range table {
+					params[k] = profileValue(v)
+				}
+				continue
+			}
+			params[k] = profileValue(v)
+		}
+	}
+	if port != "" {
+		if cfg.Host == "" {
+			return fmt.Errorf("port of the profile without host")
+		}
+		cfg.Host = net.JoinHostPort(cfg.Host, port)
+	}
+	return cfg.AddParams(params)
+}
+
+func profileValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,68 @@
+package godatabend
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDSNProfile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "profiles.toml")
+	require.NoError(t, os.WriteFile(file, []byte(`
+[default]
+host = "localhost"
+port = 8000
+user = "root"
+sslmode = "disable"
+
+[prod]
+host = "tenant--wh.gw.databend.com"
+user = "app"
+password = "secret"
+database = "sales"
+warehouse = "wh"
+wait_time_secs = 10
+
+[prod.settings]
+max_threads = 4
+`), 0600))
+
+	cfg, err := ParseDSN("databend://?profile=prod&profile_file=" + file)
+	require.NoError(t, err)
+	assert.Equal(t, "tenant--wh.gw.databend.com:443", cfg.Host)
+	assert.Equal(t, "app", cfg.User)
+	assert.Equal(t, "secret", cfg.Password)
+	assert.Equal(t, "sales", cfg.Database)
+	assert.Equal(t, "wh", cfg.Warehouse)
+	assert.Equal(t, int64(10), cfg.WaitTimeSecs)
+	assert.Equal(t, "4", cfg.Params["max_threads"])
+	assert.NotContains(t, cfg.Params, "profile")
+
+	// the DSN overrides the profile
+	cfg, err = ParseDSN("databend://root@other:8000/db?profile=prod&profile_file=" + file + "&warehouse=wh2")
+	require.NoError(t, err)
+	assert.Equal(t, "other:8000", cfg.Host)
+	assert.Equal(t, "root", cfg.User)
+	assert.Equal(t, "", cfg.Password)
+	assert.Equal(t, "db", cfg.Database)
+	assert.Equal(t, "wh2", cfg.Warehouse)
+
+	t.Setenv(EnvProfileFile, file)
+	t.Setenv(EnvProfile, "")
+	t.Setenv(EnvDSN, "")
+	cfg, err = ParseDSN("databend://?profile=")
+	require.NoError(t, err)
+	assert.Equal(t, "localhost:8000", cfg.Host)
+	assert.Equal(t, SSL_MODE_DISABLE, cfg.SSLMode)
+
+	t.Setenv(EnvProfile, "prod")
+	cfg, err = ConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, "app", cfg.User)
+
+	_, err = ParseDSN("databend://?profile=dev")
+	assert.EqualError(t, err, "no profile 'dev' in "+file)
+}
@@ -1,13 +1,43 @@
 package godatabend
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
 )
 
 type QueryError struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
 	Kind    string `json:"kind"`
+	Detail  string `json:"detail"`
+}
+
+func (e *QueryError) ErrorCode() int {
+	return e.Code
+}
+
+func (e *QueryError) ErrorMessage() string {
+	return e.Message
+}
+
+func (e *QueryError) ErrorKind() string {
+	return e.Kind
+}
+
+func (e *QueryError) Is(target error) bool {
+	return isServerError(e, target)
+}
+
+// HTTPStatus is always 200, the query errors are returned in the query responses.
+func (e *QueryError) HTTPStatus() int {
+	return http.StatusOK
+}
+
+func (e *QueryError) ErrorDetail() string {
+	return e.Detail
 }
 
 func (e *QueryError) Error() string {
@@ -28,18 +58,107 @@ type DataField struct {
 
 type QueryResponse struct {
 	ID        string        `json:"id"`
+	NodeID    string        `json:"node_id"`
 	SessionID string        `json:"session_id"`
 	Session   *SessionState `json:"session"`
 	Schema    []DataField   `json:"schema"`
 	Data      [][]string    `json:"data"`
 	State     string        `json:"state"`
 	Error     *QueryError   `json:"error"`
+	Warnings  []string      `json:"warnings"`
 	Stats     QueryStats    `json:"stats"`
 	// TODO: Affect rows
 	StatsURI string `json:"stats_uri"`
 	FinalURI string `json:"final_uri"`
 	NextURI  string `json:"next_uri"`
 	KillURI  string `json:"kill_uri"`
+
+	// size is the size of the response body
+	size int
+	// requestID is the server side id of the request from the response headers
+	requestID string
+	// rawStats keeps the stats with the fields unknown to QueryStats
+	rawStats json.RawMessage
+	// nulls marks the NULL cells of Data, which are empty in Data, it's nil if there
+	// is no NULL, and so are the rows without NULL.
+	nulls [][]bool
+}
+
+type plainQueryResponse QueryResponse
+
+func (r *QueryResponse) UnmarshalJSON(data []byte) error {
+	// the cells are decoded as pointers to tell NULL from the empty strings, and the
+	// stats are kept raw with the fields unknown to QueryStats
+	resp := struct {
+		*plainQueryResponse
+		Data  [][]*string     `json:"data"`
+		Stats json.RawMessage `json:"stats"`
+	}{plainQueryResponse: (*plainQueryResponse)(r)}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return err
+	}
+	r.rawStats = resp.Stats
+	if len(resp.Stats) > 0 {
+		if err := json.Unmarshal(resp.Stats, &r.Stats); err != nil {
+			return err
+		}
+	}
+	r.setData(resp.Data)
+	return nil
+}
+
+func (r *QueryResponse) setData(data [][]*string) {
+	r.Data, r.nulls = nil, nil
+	if data == nil {
+		return
+	}
+	r.Data = make([][]string, len(data))
+	for i, row := range data {
+		cells := make([]string, len(row))
+		for j, cell := range row {
+			if cell != nil {
+				cells[j] = *cell
+				continue
+			}
+			if r.nulls == nil {
+				r.nulls = make([][]bool, len(data))
+			}
+			if r.nulls[i] == nil {
+				r.nulls[i] = make([]bool, len(row))
+			}
+			r.nulls[i][j] = true
+		}
+		r.Data[i] = cells
+	}
+}
+
+// rowNulls returns the NULL mask of the row, nil if it has no NULL.
+func (r *QueryResponse) rowNulls(row int) []bool {
+	if row < len(r.nulls) {
+		return r.nulls[row]
+	}
+	return nil
+}
+
+// popRow removes the first row of Data and returns it with its NULL mask.
+func (r *QueryResponse) popRow() ([]string, []bool) {
+	nulls := r.rowNulls(0)
+	row := r.Data[0]
+	r.Data = r.Data[1:]
+	if len(r.nulls) > 0 {
+		r.nulls = r.nulls[1:]
+	}
+	return row, nulls
+}
+
+// truncateData keeps the first n rows of Data.
+func (r *QueryResponse) truncateData(n int) {
+	if n < len(r.Data) {
+		r.Data = r.Data[:n]
+	}
+	if n < len(r.nulls) {
+		r.nulls = r.nulls[:n]
+	}
 }
 
 type QueryStats struct {
@@ -47,6 +166,23 @@ type QueryStats struct {
 	ScanProgress   QueryProgress `json:"scan_progress"`
 	WriteProgress  QueryProgress `json:"write_progress"`
 	ResultProgress QueryProgress `json:"result_progress"`
+	TotalScan      QueryProgress `json:"total_scan"`
+	SpillProgress  SpillProgress `json:"spill_progress"`
+}
+
+type SpillProgress struct {
+	FileNums uint64 `json:"file_nums"`
+	Bytes    uint64 `json:"bytes"`
+}
+
+// QueryProfile is the final statistics of a query, returned by LastQueryProfile.
+type QueryProfile struct {
+	QueryID string
+	State   string
+	Stats   QueryStats
+	// RawStats is the stats in the final response as is, including the fields not
+	// known by QueryStats.
+	RawStats json.RawMessage
 }
 
 // QueryStatsTracker is a function that will be called when query stats are updated,
@@ -89,8 +225,67 @@ type SessionState struct {
 	// KeepServerSessionSecs uint64            `json:"keep_server_session_secs,omitempty"`
 
 	Settings map[string]string `json:"settings,omitempty"`
+
+	// TxnState is the state of the server side transaction of the session.
+	TxnState string `json:"txn_state,omitempty"`
+	// NeedSticky tells the following requests must go to the same node, like the
+	// session is in a transaction or has temporary tables.
+	NeedSticky bool `json:"need_sticky,omitempty"`
+	// NeedKeepAlive tells the session has states on the server which expire if the
+	// session is idle for long.
+	NeedKeepAlive bool `json:"need_keep_alive,omitempty"`
+
+	// raw is the session from the server, its fields unknown to the client are sent
+	// back as they are.
+	raw json.RawMessage
+}
+
+const (
+	TxnStateAutoCommit = "AutoCommit"
+	TxnStateActive     = "Active"
+	TxnStateFail       = "Fail"
+)
+
+type plainSessionState SessionState
+
+func (s *SessionState) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*plainSessionState)(s)); err != nil {
+		return err
+	}
+	s.raw = append(json.RawMessage(nil), data...)
+	return nil
 }
 
+func (s SessionState) MarshalJSON() ([]byte, error) {
+	buf, err := json.Marshal(plainSessionState(s))
+	if err != nil || len(s.raw) == 0 {
+		return buf, err
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(s.raw, &fields); err != nil {
+		return buf, nil
+	}
+	// the known fields are always taken from the struct, which may have been cleared
+	for _, name := range sessionStateFields {
+		delete(fields, name)
+	}
+	if err := json.Unmarshal(buf, &fields); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+var sessionStateFields = func() []string {
+	var names []string
+	t := reflect.TypeOf(SessionState{})
+	for i := 0; i < t.NumField(); i++ {
+		if tag := t.Field(i).Tag.Get("json"); tag != "" {
+			names = append(names, strings.Split(tag, ",")[0])
+		}
+	}
+	return names
+}()
+
 type StageAttachmentConfig struct {
 	Location          string            `json:"location"`
 	FileFormatOptions map[string]string `json:"file_format_options,omitempty"`
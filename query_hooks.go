@@ -0,0 +1,228 @@
+package godatabend
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// QueryEvent is passed to the query lifecycle hooks of Config.
+type QueryEvent struct {
+	QueryID string
+	SQL     string
+	// Start is when the query is sent, Elapsed is the time since then.
+	Start   time.Time
+	Elapsed time.Duration
+	// Stats and Rows are of the last response, Rows is the number of rows in it.
+	Stats QueryStats
+	Rows  int
+	// Err is the error of OnQueryError.
+	Err error
+	// Warnings are the new warnings of the query for OnWarning.
+	Warnings []string
+}
+
+// QueryHook receives the events of the queries, it's called synchronously.
+type QueryHook func(event QueryEvent)
+
+const contextKeyQueryTracker ContextKey = "databend-query-tracker"
+
+// queryTracker follows a logical query from the query request to its last page, it
+// fires the lifecycle hooks and covers the query with a span.
+type queryTracker struct {
+	c       *APIClient
+	sql     string
+	queryID string
+	start   time.Time
+	span    trace.Span
+	once    sync.Once
+
+	// requestID is of the last response, to be attached to the errors
+	requestID string
+	// warnings are all the warnings of the query so far
+	warnings []string
+	// rows is the number of the rows received
+	rows int64
+	// pages is the number of the page requests
+	pages int
+}
+
+// beginQuery starts to track the query, the returned context carries the tracker to
+// the page requests.
+func (c *APIClient) beginQuery(ctx context.Context, query string) (context.Context, *queryTracker) {
+	t := &queryTracker{c: c, sql: query, queryID: queryIDOf(ctx), start: time.Now()}
+	ctx, t.span = c.startQuerySpan(ctx, query)
+	ctx = context.WithValue(ctx, contextKeyQueryTracker, t)
+	return ctx, t
+}
+
+func queryTrackerOf(ctx context.Context) *queryTracker {
+	t, _ := ctx.Value(contextKeyQueryTracker).(*queryTracker)
+	return t
+}
+
+func (t *queryTracker) event(resp *QueryResponse, err error) QueryEvent {
+	e := QueryEvent{
+		QueryID: t.queryID,
+		SQL:     t.sql,
+		Start:   t.start,
+		Elapsed: time.Since(t.start),
+		Err:     err,
+	}
+	if resp != nil {
+		e.Stats = resp.Stats
+		e.Rows = len(resp.Data)
+	}
+	return e
+}
+
+// started is called with the response of the query request.
+func (t *queryTracker) started(resp *QueryResponse) {
+	if resp.ID != "" {
+		t.queryID = resp.ID
+	}
+	setStartedAttributes(t.span, resp)
+	if t.c.onQueryStart != nil {
+		t.c.onQueryStart(t.event(resp, nil))
+	}
+	t.collectWarnings(resp)
+}
+
+// page is called with the response of each page request.
+func (t *queryTracker) page(resp *QueryResponse) {
+	t.requestID = resp.requestID
+	if t.c.onPage != nil {
+		t.c.onPage(t.event(resp, nil))
+	}
+	t.collectWarnings(resp)
+}
+
+// killQueryTimeout bounds killing the query past Config.MaxResultRows.
+const killQueryTimeout = 5 * time.Second
+
+// checkRows counts the rows of the response, the query is killed once it returns
+// more rows than Config.MaxResultRows, and the response keeps the rows up to the
+// limit, which are returned before the error.
+func (t *queryTracker) checkRows(resp *QueryResponse) error {
+	limit := t.c.maxResultRows
+	if limit <= 0 || t.rows+int64(len(resp.Data)) <= limit {
+		t.rows += int64(len(resp.Data))
+		return nil
+	}
+	resp.truncateData(int(limit - t.rows))
+	t.rows = limit
+	if resp.NextURI != "" && resp.KillURI != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), killQueryTimeout)
+		defer cancel()
+		if err := t.c.KillQuery(ctx, resp.KillURI); err != nil {
+			t.c.log().Warn("kill query failed", "query_id", t.queryID, "error", err)
+		}
+	}
+	resp.NextURI = ""
+	return fmt.Errorf("%w: more than %d rows", ErrResultTooLarge, limit)
+}
+
+// collectWarnings keeps the new warnings of the response and passes them to the
+// OnWarning hook.
+func (t *queryTracker) collectWarnings(resp *QueryResponse) {
+	n := len(t.warnings)
+	t.warnings = appendWarnings(t.warnings, resp.Warnings)
+	if len(t.warnings) > n {
+		t.c.log().Warn("query warning", "query_id", t.queryID, "warnings", t.warnings[n:])
+		if t.c.onWarning != nil {
+			e := t.event(resp, nil)
+			e.Warnings = t.warnings[n:]
+			t.c.onWarning(e)
+		}
+	}
+}
+
+// appendWarnings appends the warnings not seen yet, the server may repeat them in
+// the following pages.
+func appendWarnings(warnings []string, more []string) []string {
+	for _, w := range more {
+		seen := false
+		for _, s := range warnings {
+			if s == w {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			warnings = append(warnings, w)
+		}
+	}
+	return warnings
+}
+
+// end is called with the last response and the error of the query, it's safe to
+// call it more than once.
+func (t *queryTracker) end(resp *QueryResponse, err error) {
+	t.once.Do(func() {
+		if resp != nil && resp.NextURI == "" {
+			t.c.setLastQueryProfile(resp)
+		}
+		endQuerySpan(t.span, resp, err)
+		t.logSlowQuery(resp, err)
+		if err != nil {
+			if t.c.onQueryError != nil {
+				t.c.onQueryError(t.event(resp, err))
+			}
+			return
+		}
+		if t.c.onQueryEnd != nil {
+			t.c.onQueryEnd(t.event(resp, nil))
+		}
+	})
+}
+
+// wrap attaches the query id and the SQL snippet to the error of the query.
+func (t *queryTracker) wrap(err error) error {
+	if err == nil || err == driver.ErrBadConn {
+		return err
+	}
+	stmtErr := &StatementError{
+		QueryID: t.queryID,
+		SQL:     sqlSnippet(t.sql, t.c.errorSQLLength, t.c.errorRedactSQL),
+		Err:     err,
+	}
+	// the APIErrors have their own request ids
+	if errors.As(err, new(*QueryError)) {
+		stmtErr.RequestID = t.requestID
+	}
+	return stmtErr
+}
+
+// logSlowQuery logs the query if it takes longer than Config.SlowQueryThreshold.
+func (t *queryTracker) logSlowQuery(resp *QueryResponse, err error) {
+	threshold := t.c.slowQueryThreshold
+	elapsed := time.Since(t.start)
+	if threshold <= 0 || elapsed < threshold {
+		return
+	}
+	var stats QueryStats
+	if resp != nil {
+		stats = resp.Stats
+	}
+	args := []interface{}{
+		"query_id", t.queryID,
+		"sql", logSQL(t.sql),
+		"elapsed", elapsed,
+		"running_time_ms", stats.RunningTimeMS,
+		"scan_rows", stats.ScanProgress.Rows,
+		"scan_bytes", stats.ScanProgress.Bytes,
+		"result_rows", stats.ResultProgress.Rows,
+		"result_bytes", stats.ResultProgress.Bytes,
+		"write_rows", stats.WriteProgress.Rows,
+		"write_bytes", stats.WriteProgress.Bytes,
+	}
+	if err != nil {
+		args = append(args, "error", err)
+	}
+	t.c.log().Warn("slow query", args...)
+}
@@ -0,0 +1,158 @@
+package godatabend
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryHooks(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/query":
+			_, _ = w.Write([]byte(`{"id":"q1","schema":[{"name":"a","type":"Int32"}],"data":[["1"]],"next_uri":"/v1/query/q1/page/1"}`))
+		case strings.HasSuffix(r.URL.Path, "/page/1"):
+			_, _ = w.Write([]byte(`{"id":"q1","data":[["2"]],"next_uri":"/v1/query/q1/page/2"}`))
+		default:
+			_, _ = w.Write([]byte(`{"id":"q1","error":{"code":1001,"message":"boom"}}`))
+		}
+	}))
+	defer ts.Close()
+
+	var events []string
+	hook := func(name string) QueryHook {
+		return func(e QueryEvent) {
+			assert.Equal(t, "q1", e.QueryID)
+			assert.Equal(t, "SELECT a FROM t", e.SQL)
+			events = append(events, name)
+		}
+	}
+	cfg, err := ParseDSN("databend://root:root@" + strings.TrimPrefix(ts.URL, "http://") + "/default?sslmode=disable")
+	require.NoError(t, err)
+	cfg.OnQueryStart = hook("start")
+	cfg.OnPage = hook("page")
+	cfg.OnQueryEnd = hook("end")
+	cfg.OnQueryError = func(e QueryEvent) {
+		assert.Error(t, e.Err)
+		events = append(events, "error")
+	}
+	dc, err := buildDatabendConn(context.Background(), *cfg)
+	require.NoError(t, err)
+	defer dc.Close()
+
+	rows, err := dc.query(context.Background(), "SELECT a FROM t")
+	require.NoError(t, err)
+	dest := make([]driver.Value, 1)
+	require.NoError(t, rows.Next(dest))
+	require.NoError(t, rows.Next(dest))
+	err = rows.Next(dest)
+	assert.Error(t, err)
+	assert.NotEqual(t, io.EOF, err)
+	require.NoError(t, rows.Close())
+	assert.Equal(t, []string{"start", "page", "page", "error"}, events)
+}
+
+func TestSlowQueryLog(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		_, _ = w.Write([]byte(`{"id":"q1","stats":{"running_time_ms":20}}`))
+	}))
+	defer ts.Close()
+
+	l := &recordLogger{}
+	c := APIClient{cli: &http.Client{}, apiEndpoint: ts.URL, user: "root", structLogger: l}
+	c.slowQueryThreshold = time.Hour
+	require.NoError(t, c.QuerySync(context.Background(), "SELECT 1", nil, make(chan QueryResponse, 1)))
+	assert.NotContains(t, l.msgs, "WARN slow query")
+
+	c.slowQueryThreshold = 10 * time.Millisecond
+	require.NoError(t, c.QuerySync(context.Background(), "SELECT 1 WHERE s = 'secret'", nil, make(chan QueryResponse, 1)))
+	require.Equal(t, "WARN slow query", l.msgs[len(l.msgs)-1])
+	assert.Contains(t, l.args[len(l.args)-1], "SELECT ? WHERE s = ?")
+}
+
+func TestQueryWarnings(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/query" {
+			_, _ = w.Write([]byte(`{"id":"q1","schema":[{"name":"a","type":"Int32"}],"data":[["1"]],"warnings":["w1"],"next_uri":"/v1/query/q1/page/1"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"q1","data":[],"warnings":["w1","w2"]}`))
+	}))
+	defer ts.Close()
+
+	var warnings []string
+	cfg, err := ParseDSN("databend://root:root@" + strings.TrimPrefix(ts.URL, "http://") + "/default?sslmode=disable")
+	require.NoError(t, err)
+	cfg.OnWarning = func(e QueryEvent) {
+		warnings = append(warnings, e.Warnings...)
+	}
+	dc, err := buildDatabendConn(context.Background(), *cfg)
+	require.NoError(t, err)
+	defer dc.Close()
+
+	rows, err := dc.query(context.Background(), "SELECT a FROM t")
+	require.NoError(t, err)
+	dest := make([]driver.Value, 1)
+	require.NoError(t, rows.Next(dest))
+	assert.Equal(t, io.EOF, rows.Next(dest))
+	assert.Equal(t, []string{"w1", "w2"}, rows.(*nextRows).Warnings())
+	require.NoError(t, rows.Close())
+	assert.Equal(t, []string{"w1", "w2"}, warnings)
+
+	res, err := dc.ExecContext(context.Background(), "INSERT INTO t VALUES (1)", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"w1", "w2"}, res.(*QueryResult).Warnings())
+}
+
+func TestMaxResultRows(t *testing.T) {
+	var killed bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/query":
+			_, _ = w.Write([]byte(`{"id":"q1","schema":[{"name":"a","type":"Int32"}],"data":[["1"],["2"]],"next_uri":"/v1/query/q1/page/1","kill_uri":"/v1/query/q1/kill"}`))
+		case strings.HasSuffix(r.URL.Path, "/kill"):
+			killed = true
+		default:
+			_, _ = w.Write([]byte(`{"id":"q1","data":[["3"],["4"]],"next_uri":"/v1/query/q1/page/2","kill_uri":"/v1/query/q1/kill"}`))
+		}
+	}))
+	defer ts.Close()
+
+	cfg, err := ParseDSN("databend://root:root@" + strings.TrimPrefix(ts.URL, "http://") + "/default?sslmode=disable&max_result_rows=3")
+	require.NoError(t, err)
+	dc, err := buildDatabendConn(context.Background(), *cfg)
+	require.NoError(t, err)
+	defer dc.Close()
+
+	rows, err := dc.query(context.Background(), "SELECT a FROM t")
+	require.NoError(t, err)
+	// the rows up to the limit are returned before the error
+	dest := make([]driver.Value, 1)
+	var got []driver.Value
+	for err = rows.Next(dest); err == nil; err = rows.Next(dest) {
+		got = append(got, dest[0])
+	}
+	assert.ErrorIs(t, err, ErrResultTooLarge)
+	assert.Equal(t, []driver.Value{int32(1), int32(2), int32(3)}, got)
+	assert.True(t, killed)
+	require.NoError(t, rows.Close())
+
+	killed = false
+	got = nil
+	err = dc.rest.QueryStream(context.Background(), "SELECT a FROM t", nil, func(row []driver.Value) error {
+		got = append(got, row[0])
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrResultTooLarge)
+	assert.Len(t, got, 3)
+	assert.True(t, killed)
+}
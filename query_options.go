@@ -0,0 +1,142 @@
+package godatabend
+
+import (
+	"context"
+)
+
+const contextKeyQueryOptions ContextKey = "databend-query-options"
+
+// QueryOption changes a single query of DoQuery, QuerySingle or QuerySync, rather
+// than the client or the session.
+type QueryOption func(o *queryOptions)
+
+type queryOptions struct {
+	pagination      *PaginationConfig
+	settings        map[string]string
+	stageAttachment *StageAttachmentConfig
+	queryID         string
+}
+
+// WithPagination overrides the pagination of the client for the query, the zero
+// values are the defaults of the server.
+func WithPagination(waitTimeSecs, maxRowsInBuffer, maxRowsPerPage int64) QueryOption {
+	return func(o *queryOptions) {
+		o.pagination = &PaginationConfig{
+			WaitTime:        waitTimeSecs,
+			MaxRowsInBuffer: maxRowsInBuffer,
+			MaxRowsPerPage:  maxRowsPerPage,
+		}
+	}
+}
+
+// WithSettings sets the settings for the query only, the session keeps its own.
+func WithSettings(settings map[string]string) QueryOption {
+	return func(o *queryOptions) {
+		merged := make(map[string]string, len(o.settings)+len(settings))
+		for k, v := range o.settings {
+			merged[k] = v
+		}
+		for k, v := range settings {
+			merged[k] = v
+		}
+		o.settings = merged
+	}
+}
+
+// WithStageAttachment attaches the staged files to the query, like the INSERT of
+// InsertWithStage. The nil copyOptions are the default copy options of the client.
+func WithStageAttachment(stage *StageLocation, fileFormatOptions map[string]string, copyOptions *CopyOptions) QueryOption {
+	return func(o *queryOptions) {
+		attachment := &StageAttachmentConfig{
+			Location:          stage.String(),
+			FileFormatOptions: fileFormatOptions,
+		}
+		if copyOptions != nil {
+			attachment.CopyOptions = copyOptions.toMap()
+		}
+		o.stageAttachment = attachment
+	}
+}
+
+// WithQueryID sets the id of the query instead of a generated one.
+func WithQueryID(queryID string) QueryOption {
+	return func(o *queryOptions) {
+		o.queryID = queryID
+	}
+}
+
+// withQueryOptions keeps the options on ctx for the requests of the query, they
+// are applied over the options of the outer calls.
+func withQueryOptions(ctx context.Context, opts []QueryOption) context.Context {
+	if len(opts) == 0 {
+		return ctx
+	}
+	o := &queryOptions{}
+	if prev := queryOptionsOf(ctx); prev != nil {
+		*o = *prev
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.queryID != "" {
+		ctx = context.WithValue(ctx, ContextKeyQueryID, o.queryID)
+	}
+	return context.WithValue(ctx, contextKeyQueryOptions, o)
+}
+
+func queryOptionsOf(ctx context.Context) *queryOptions {
+	o, _ := ctx.Value(contextKeyQueryOptions).(*queryOptions)
+	return o
+}
+
+// queryRequest builds the request of the query with the options.
+func (c *APIClient) queryRequest(ctx context.Context, sql string) QueryRequest {
+	request := QueryRequest{
+		SQL:        sql,
+		Pagination: c.getPagenationConfig(),
+		Session:    c.getSessionState(),
+	}
+	o := queryOptionsOf(ctx)
+	if o == nil {
+		return request
+	}
+	if o.pagination != nil {
+		request.Pagination = o.pagination
+	}
+	if len(o.settings) > 0 {
+		settings := make(map[string]string, len(request.Session.Settings)+len(o.settings))
+		for k, v := range request.Session.Settings {
+			settings[k] = v
+		}
+		for k, v := range o.settings {
+			settings[k] = v
+		}
+		request.Session.Settings = settings
+	}
+	if o.stageAttachment != nil {
+		attachment := *o.stageAttachment
+		if attachment.CopyOptions == nil {
+			attachment.CopyOptions = c.NewDefaultCopyOptions().toMap()
+		}
+		request.StageAttachment = &attachment
+	}
+	return request
+}
+
+// dropQuerySettings restores the settings of the query in the session returned by
+// the server, so they do not outlive the query.
+func (c *APIClient) dropQuerySettings(ctx context.Context, response *QueryResponse) {
+	o := queryOptionsOf(ctx)
+	if o == nil || len(o.settings) == 0 || response.Session == nil || response.Session.Settings == nil {
+		return
+	}
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	for k := range o.settings {
+		if v, ok := c.sessionSettings[k]; ok {
+			response.Session.Settings[k] = v
+		} else {
+			delete(response.Session.Settings, k)
+		}
+	}
+}
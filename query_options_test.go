@@ -0,0 +1,56 @@
+package godatabend
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryOptions(t *testing.T) {
+	var req QueryRequest
+	var queryID string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = QueryRequest{}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		queryID = r.Header.Get(DatabendQueryIDHeader)
+		// the server returns the settings of the request in the session
+		session, _ := json.Marshal(req.Session)
+		_, _ = w.Write([]byte(`{"id":"q1","state":"Succeeded","session":` + string(session) + `}`))
+	}))
+	defer ts.Close()
+
+	cfg, err := ParseDSN("databend://root:root@" + strings.TrimPrefix(ts.URL, "http://") + "/default?sslmode=disable&max_threads=4&wait_time_secs=5")
+	require.NoError(t, err)
+	c := NewAPIClientFromConfig(cfg)
+	ctx := context.Background()
+
+	stage := &StageLocation{Name: "s", Path: "a.csv"}
+	_, err = c.QuerySingle(ctx, "INSERT INTO t VALUES", nil,
+		WithQueryID("my-query"),
+		WithPagination(1, 0, 100),
+		WithSettings(map[string]string{"max_threads": "8", "timezone": "Asia/Shanghai"}),
+		WithStageAttachment(stage, map[string]string{"type": "csv"}, nil))
+	require.NoError(t, err)
+	assert.Equal(t, "my-query", queryID)
+	assert.Equal(t, &PaginationConfig{WaitTime: 1, MaxRowsPerPage: 100}, req.Pagination)
+	assert.Equal(t, "8", req.Session.Settings["max_threads"])
+	assert.Equal(t, "Asia/Shanghai", req.Session.Settings["timezone"])
+	require.NotNil(t, req.StageAttachment)
+	assert.Equal(t, "@s/a.csv", req.StageAttachment.Location)
+	assert.Equal(t, "true", req.StageAttachment.CopyOptions["purge"])
+
+	// the settings of the query are not kept by the session
+	_, err = c.QuerySingle(ctx, "SELECT 1", nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, "my-query", queryID)
+	assert.Equal(t, int64(5), req.Pagination.WaitTime)
+	assert.Equal(t, "4", req.Session.Settings["max_threads"])
+	assert.NotContains(t, req.Session.Settings, "timezone")
+	assert.Nil(t, req.StageAttachment)
+}
@@ -1,7 +1,10 @@
 package godatabend
 
 import (
+	"context"
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -34,3 +37,38 @@ func Test_SessionState(t *testing.T) {
 	require.NoError(t, err)
 	assert.Nil(t, ss.SecondaryRoles)
 }
+
+func TestSessionStateUnknownFields(t *testing.T) {
+	ss := &SessionState{}
+	err := json.Unmarshal([]byte(`{"database":"db1","txn_state":"Active","internal":"i1"}`), ss)
+	require.NoError(t, err)
+	assert.Equal(t, TxnStateActive, ss.TxnState)
+
+	ss.Database = ""
+	ss.TxnState = TxnStateAutoCommit
+	buf, err := json.Marshal(ss)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"txn_state":"AutoCommit","internal":"i1"}`, string(buf))
+}
+
+func TestLastQueryProfile(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/query" {
+			_, _ = w.Write([]byte(`{"id":"q1","next_uri":"/v1/query/q1/final"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"q1","state":"Succeeded","stats":{"scan_progress":{"rows":10,"bytes":100},"spill_progress":{"file_nums":1,"bytes":64},"cpu_time_ms":5}}`))
+	}))
+	defer ts.Close()
+
+	c := APIClient{cli: &http.Client{}, apiEndpoint: ts.URL, user: "root"}
+	assert.Nil(t, c.LastQueryProfile())
+	require.NoError(t, c.QuerySync(context.Background(), "SELECT 1", nil, make(chan QueryResponse, 2)))
+	profile := c.LastQueryProfile()
+	require.NotNil(t, profile)
+	assert.Equal(t, "q1", profile.QueryID)
+	assert.Equal(t, "Succeeded", profile.State)
+	assert.Equal(t, uint64(10), profile.Stats.ScanProgress.Rows)
+	assert.Equal(t, SpillProgress{FileNums: 1, Bytes: 64}, profile.Stats.SpillProgress)
+	assert.Contains(t, string(profile.RawStats), `"cpu_time_ms":5`)
+}
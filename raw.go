@@ -0,0 +1,74 @@
+package godatabend
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+)
+
+// RawConn is the driver connection passed to sql.Conn.Raw, it's the stable way to
+// reach the native features of a connection pooled by database/sql:
+//
+//	err = conn.Raw(func(driverConn interface{}) error {
+//		c := driverConn.(godatabend.RawConn)
+//		if err := c.SetSetting(ctx, "max_threads", "4"); err != nil {
+//			return err
+//		}
+//		return c.Client().UploadToStage(ctx, stage, input, size)
+//	})
+//
+// The settings set on the connection are reset to the config once it's returned to
+// the pool, while the role and the warehouse are kept by the session.
+type RawConn interface {
+	// Client returns the native client of the connection, it must not be used
+	// after the function passed to Raw returns.
+	Client() *APIClient
+	// LastQueryID returns the id of the last query started on the connection.
+	LastQueryID() string
+	// LastQueryProfile returns the final statistics of the last query.
+	LastQueryProfile() *QueryProfile
+	// DescribeQuery returns the columns of the query without running it.
+	DescribeQuery(ctx context.Context, query string) ([]DataField, error)
+	// SetRole switches the role of the session.
+	SetRole(ctx context.Context, role string) error
+	// UseWarehouse switches the warehouse of the session.
+	UseWarehouse(ctx context.Context, warehouse string) error
+	// SetSetting sets a setting of the session like SET.
+	SetSetting(ctx context.Context, key, value string) error
+}
+
+var _ RawConn = (*DatabendConn)(nil)
+
+// Client returns the native client of the connection.
+func (dc *DatabendConn) Client() *APIClient {
+	return dc.rest
+}
+
+// SetRole switches the role of the session of the connection.
+func (dc *DatabendConn) SetRole(ctx context.Context, role string) error {
+	if dc.rest == nil {
+		return driver.ErrBadConn
+	}
+	return dc.rest.SetRole(ctx, role)
+}
+
+// UseWarehouse switches the warehouse of the session of the connection.
+func (dc *DatabendConn) UseWarehouse(ctx context.Context, warehouse string) error {
+	if dc.rest == nil {
+		return driver.ErrBadConn
+	}
+	return dc.rest.UseWarehouse(ctx, warehouse)
+}
+
+// SetSetting sets a setting of the session of the connection, the known settings
+// are checked like the settings of the DSN.
+func (dc *DatabendConn) SetSetting(ctx context.Context, key, value string) error {
+	if dc.rest == nil {
+		return driver.ErrBadConn
+	}
+	v, err := normalizeSetting(key, value)
+	if err != nil {
+		return fmt.Errorf("invalid setting %s '%s': %w", key, value, err)
+	}
+	return dc.rest.SetSetting(ctx, key, v)
+}
@@ -10,13 +10,16 @@ import (
 	"fmt"
 	"io"
 	"mime/multipart"
-	"net"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/avast/retry-go"
+	"github.com/google/uuid"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type AuthMethod string
@@ -24,14 +27,21 @@ type AuthMethod string
 const (
 	AuthMethodUserPassword AuthMethod = "userPassword"
 	AuthMethodAccessToken  AuthMethod = "accessToken"
+	AuthMethodSessionToken AuthMethod = "sessionToken"
 )
 
 type ContextKey string
 
 const (
-	ContextKeyQueryID ContextKey = "X-Databend-Query-ID"
-	EMPTY_FIELD_AS    string     = "empty_field_as"
-	PURGE             string     = "purge"
+	ContextKeyQueryID          ContextKey = "X-Databend-Query-ID"
+	ContextKeyCopyOptions      ContextKey = "databend-copy-options"
+	ContextKeyCredentials      ContextKey = "databend-credentials"
+	ContextKeyDeduplicateLabel ContextKey = "X-Databend-Deduplicate-Label"
+	ContextKeyWarehouse        ContextKey = "X-Databend-Warehouse"
+	contextKeyStickyNode       ContextKey = "databend-sticky-node"
+	contextKeyEndpoint         ContextKey = "databend-endpoint"
+	EMPTY_FIELD_AS             string     = "empty_field_as"
+	PURGE                      string     = "purge"
 )
 
 type PresignedResponse struct {
@@ -40,13 +50,52 @@ type PresignedResponse struct {
 	URL     string
 }
 
+// UserStageName is the name of the internal stage every user owns, it can be
+// used without creating a named stage first.
+const UserStageName = "~"
+
 type StageLocation struct {
 	Name string
 	Path string
 }
 
+// NewUserStageLocation returns a location in the user stage (@~).
+func NewUserStageLocation(path string) *StageLocation {
+	return &StageLocation{
+		Name: UserStageName,
+		Path: path,
+	}
+}
+
+// NewTempStageLocation returns a unique location in the user stage for the
+// given file name, which is used for the intermediate files of a load.
+func NewTempStageLocation(fileName string) *StageLocation {
+	return NewUserStageLocation(fmt.Sprintf("tmp/%d-%s/%s", time.Now().Unix(), uuid.NewString(), fileName))
+}
+
+// ParseStageLocation parses a location like @stage/path or @~/path.
+func ParseStageLocation(location string) (*StageLocation, error) {
+	if !strings.HasPrefix(location, "@") || len(location) < 2 {
+		return nil, errors.Errorf("invalid stage location %q", location)
+	}
+	parts := strings.SplitN(location[1:], "/", 2)
+	sl := &StageLocation{Name: parts[0]}
+	if len(parts) > 1 {
+		sl.Path = parts[1]
+	}
+	return sl, nil
+}
+
+func (sl *StageLocation) IsUserStage() bool {
+	return sl.Name == UserStageName
+}
+
 func (sl *StageLocation) String() string {
-	return fmt.Sprintf("@%s/%s", sl.Name, sl.Path)
+	path := strings.TrimPrefix(sl.Path, "/")
+	if path == "" {
+		return fmt.Sprintf("@%s", sl.Name)
+	}
+	return fmt.Sprintf("@%s/%s", sl.Name, path)
 }
 
 func (c *APIClient) NewDefaultCSVFormatOptions() map[string]string {
@@ -59,28 +108,89 @@ func (c *APIClient) NewDefaultCSVFormatOptions() map[string]string {
 	}
 }
 
-func (c *APIClient) NewDefaultCopyOptions() map[string]string {
-	return map[string]string{
-		PURGE: "true",
+// String describes the client without the credentials, so it's safe to log.
+func (c *APIClient) String() string {
+	return fmt.Sprintf("APIClient{endpoint: %s, user: %s, database: %s}", c.endpoint(), c.user, c.database)
+}
+
+// NewDefaultCopyOptions returns the copy options of the config, or purges the
+// loaded files by default.
+func (c *APIClient) NewDefaultCopyOptions() *CopyOptions {
+	if c.copyOptions != nil {
+		return c.copyOptions.clone()
 	}
+	return defaultCopyOptions()
 }
 
+// APIClient runs the queries of a session over the HTTP handler, the queries could
+// be run concurrently, each of them keeps its own pages and endpoint.
 type APIClient struct {
-	cli *http.Client
-
-	apiEndpoint     string
+	cli       *http.Client
+	uploadCli *http.Client
+
+	apiEndpoint string
+	endpoints   []string
+	endpointIdx int
+	// endpointMu guards apiEndpoint and endpointIdx switched by the queries
+	endpointMu      sync.Mutex
+	balancer        *loadBalancer
 	host            string
 	tenant          string
 	warehouse       string
+	userAgent       string
 	database        string
 	user            string
 	password        string
 	role            string
 	secondaryRoles  *[]string
 	sessionSettings map[string]string
-
+	// initial is the session of the config, restored when the session is reset
+	initial         initialSession
+	sessionStateRaw json.RawMessage
+	txnState        string
+	needSticky      bool
+	needKeepAlive   bool
+	nodeID          string
+	// sessionMu guards the session state read by the keep-alive heartbeat
+	sessionMu sync.Mutex
+
+	timeout           time.Duration
+	pageTimeout       time.Duration
+	pingTimeout       time.Duration
+	retryPolicies     map[RequestType]RetryPolicy
+	retryDisabled     bool
+	retryIf           func(err error, t RequestType) bool
+	breakers          map[string]*circuitBreaker
 	statsTracker      QueryStatsTracker
+	structLogger      Logger
+	metrics           Metrics
+	tracer            trace.Tracer
+	onQueryStart      QueryHook
+	onPage            QueryHook
+	onQueryEnd        QueryHook
+	onQueryError      QueryHook
+	onWarning         QueryHook
+	traceRedactSQL    bool
+	errorSQLLength    int
+	errorRedactSQL    bool
+	queryStarts       sync.Map
 	accessTokenLoader AccessTokenLoader
+	sessionToken      *sessionTokenLoader
+	onTokenRotated    func(token string)
+	interceptors      []Interceptor
+	tokenMu           sync.Mutex
+	lastToken         string
+
+	// slowQueryThreshold logs the queries slower than it
+	slowQueryThreshold time.Duration
+	// debugHTTP dumps the http requests and responses
+	debugHTTP bool
+	// maxResultRows kills the queries returning more rows than it
+	maxResultRows int64
+
+	profileMu        sync.Mutex
+	lastQueryProfile *QueryProfile
+	lastQueryID      string
 
 	WaitTimeSeconds      int64
 	MaxRowsInBuffer      int64
@@ -88,11 +198,38 @@ type APIClient struct {
 	PresignedURLDisabled bool
 	EmptyFieldAs         string
 
+	// fileFormat and copyOptions are the defaults of the loads
+	fileFormat  string
+	copyOptions *CopyOptions
+
+	// typedValues parses the values of QueryStream like typed_values
+	typedValues bool
+
 	// only used for testing mocks
 	doRequestFunc func(method, path string, req interface{}, resp interface{}) error
 }
 
+// NewAPIClient creates an APIClient from the config, it fails if the http client can
+// not be built, like the client certificate can not be loaded.
+func NewAPIClient(cfg *Config) (*APIClient, error) {
+	cli, err := newHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return newAPIClient(cfg, cli), nil
+}
+
+// NewAPIClientFromConfig is like NewAPIClient, but the error to build the http client
+// is returned by the requests.
 func NewAPIClientFromConfig(cfg *Config) *APIClient {
+	cli, err := newHTTPClient(cfg)
+	if err != nil {
+		cli = &http.Client{Transport: failedRoundTripper{err: err}}
+	}
+	return newAPIClient(cfg, cli)
+}
+
+func newAPIClient(cfg *Config, cli *http.Client) *APIClient {
 	var apiScheme string
 	switch cfg.SSLMode {
 	case SSL_MODE_DISABLE:
@@ -101,58 +238,141 @@ func NewAPIClientFromConfig(cfg *Config) *APIClient {
 		apiScheme = "https"
 	}
 
-	// if role is set in config, we'd prefer to limit it as the only effective role,
-	// so you could limit the privileges by setting a role with limited privileges.
-	// however this can be overridden by executing `SET SECONDARY ROLES ALL` in the
-	// query.
-	// secondaryRoles now have two viable values:
-	// - nil: means enabling ALL the granted roles of the user
-	// - []string{}: means enabling NONE of the granted roles
-	var secondaryRoles *[]string
-	if len(cfg.Role) > 0 {
-		secondaryRoles = &[]string{}
-	}
-
-	return &APIClient{
-		cli: &http.Client{
-			Timeout: cfg.Timeout,
-		},
-		apiEndpoint:       fmt.Sprintf("%s://%s", apiScheme, cfg.Host),
+	c := &APIClient{
+		cli:               cli,
+		uploadCli:         newUploadHTTPClient(cfg, cli),
 		host:              cfg.Host,
 		tenant:            cfg.Tenant,
 		warehouse:         cfg.Warehouse,
+		userAgent:         userAgent(cfg.ApplicationName),
 		database:          cfg.Database,
 		user:              cfg.User,
 		password:          cfg.Password,
 		role:              cfg.Role,
-		secondaryRoles:    secondaryRoles,
+		secondaryRoles:    defaultSecondaryRoles(cfg.Role),
 		accessTokenLoader: initAccessTokenLoader(cfg),
 		sessionSettings:   cfg.Params,
-		statsTracker:      cfg.StatsTracker,
+		initial: initialSession{
+			database:       cfg.Database,
+			role:           cfg.Role,
+			secondaryRoles: defaultSecondaryRoles(cfg.Role),
+			warehouse:      cfg.Warehouse,
+			settings:       cfg.Params,
+		},
+		timeout:     cfg.Timeout,
+		pageTimeout: pageTimeout(cfg),
+		pingTimeout: cfg.PingTimeout,
+		retryPolicies: map[RequestType]RetryPolicy{
+			Query: cfg.QueryRetryPolicy,
+			Page:  cfg.PageRetryPolicy,
+		},
+		retryDisabled:  cfg.DisableRetry,
+		retryIf:        cfg.RetryIf,
+		statsTracker:   cfg.StatsTracker,
+		structLogger:   cfg.Logger,
+		tracer:         newTracer(cfg),
+		onQueryStart:   cfg.OnQueryStart,
+		onPage:         cfg.OnPage,
+		onQueryEnd:     cfg.OnQueryEnd,
+		onQueryError:   cfg.OnQueryError,
+		onWarning:      cfg.OnWarning,
+		traceRedactSQL: cfg.TraceRedactSQL,
+		errorSQLLength: cfg.ErrorSQLLength,
+		errorRedactSQL: cfg.ErrorRedactSQL,
+		onTokenRotated: cfg.OnTokenRotated,
+		interceptors:   cfg.Interceptors,
+
+		slowQueryThreshold: cfg.SlowQueryThreshold,
+		debugHTTP:          cfg.DebugHTTP,
+		maxResultRows:      cfg.MaxResultRows,
 
 		WaitTimeSeconds:      cfg.WaitTimeSecs,
 		MaxRowsInBuffer:      cfg.MaxRowsInBuffer,
 		MaxRowsPerPage:       cfg.MaxRowsPerPage,
 		PresignedURLDisabled: cfg.PresignedURLDisabled,
 		EmptyFieldAs:         cfg.EmptyFieldAs,
+
+		fileFormat:  cfg.FileFormat,
+		copyOptions: cfg.CopyOptions,
+
+		typedValues: cfg.TypedValues,
+	}
+	for _, host := range cfg.hosts() {
+		c.endpoints = append(c.endpoints, fmt.Sprintf("%s://%s", apiScheme, host))
+	}
+	if len(c.endpoints) > 0 {
+		c.apiEndpoint = c.endpoints[0]
+	}
+	if len(c.endpoints) > 1 {
+		// the Host header follows the url of each endpoint
+		c.host = ""
+	}
+	c.balancer = getLoadBalancer(cfg, c.endpoints)
+	c.breakers = getCircuitBreakers(cfg, c.endpoints)
+	if cfg.SessionToken && cfg.User != "" {
+		c.sessionToken = newSessionTokenLoader(c)
 	}
+	metrics, err := newMetrics(cfg)
+	if err != nil {
+		logger.Warnf("failed to create the OpenTelemetry metrics: %v", err)
+		metrics = cfg.Metrics
+	}
+	c.metrics = metrics
+	return c
 }
 
+// uploadClient returns the http client of the stage uploads and downloads.
+func (c *APIClient) uploadClient() *http.Client {
+	if c.uploadCli != nil {
+		return c.uploadCli
+	}
+	return &http.Client{Timeout: defaultUploadTimeout}
+}
+
+// initAccessTokenLoader chains all the configured token sources, the first one
+// which works is used, so the same config works across different environments.
 func initAccessTokenLoader(cfg *Config) AccessTokenLoader {
 	if cfg.AccessTokenLoader != nil {
 		return cfg.AccessTokenLoader
-	} else if cfg.AccessTokenFile != "" {
-		return NewFileAccessTokenLoader(cfg.AccessTokenFile)
-	} else if cfg.AccessToken != "" {
-		return NewStaticAccessTokenLoader(cfg.AccessToken)
 	}
-	return nil
+	var loaders []AccessTokenLoader
+	if cfg.AccessTokenFile != "" {
+		loaders = append(loaders, NewFileAccessTokenLoader(cfg.AccessTokenFile))
+	}
+	if cfg.AccessToken != "" {
+		loaders = append(loaders, NewStaticAccessTokenLoader(cfg.AccessToken))
+	}
+	if cfg.AccessTokenCommand != "" {
+		loaders = append(loaders, NewCommandAccessTokenLoader(cfg.AccessTokenCommand))
+	}
+	if cfg.PrivateKeyFile != "" {
+		loader := NewKeyPairFileAccessTokenLoader(cfg.User, cfg.PrivateKeyFile)
+		loader.KeyID = cfg.PrivateKeyID
+		loaders = append(loaders, loader)
+	}
+	switch len(loaders) {
+	case 0:
+		return nil
+	case 1:
+		return loaders[0]
+	}
+	return NewChainAccessTokenLoader(loaders...)
 }
 
 func (c *APIClient) doRequest(ctx context.Context, method, path string, req interface{}, resp interface{}) error {
+	return c.doRequestWithTimeout(ctx, c.timeout, method, path, req, resp)
+}
+
+func (c *APIClient) doRequestWithTimeout(ctx context.Context, timeout time.Duration, method, path string, req interface{}, resp interface{}) error {
 	if c.doRequestFunc != nil {
 		return c.doRequestFunc(method, path, req, resp)
 	}
+	parent := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 
 	var err error
 	reqBody := []byte{}
@@ -164,14 +384,22 @@ func (c *APIClient) doRequest(ctx context.Context, method, path string, req inte
 	}
 
 	url := c.makeURL(path)
-	httpReq, err := http.NewRequest(method, url, bytes.NewBuffer(reqBody))
-	if err != nil {
-		return errors.Wrap(err, "failed to create http request")
+	if endpoint, ok := ctx.Value(contextKeyEndpoint).(string); ok && !isAbsoluteURI(path) {
+		url = endpoint + path
 	}
-	httpReq = httpReq.WithContext(ctx)
-
+	breaker := c.breakerOf(url)
+	if err := breaker.allow(); err != nil {
+		return err
+	}
+	defer c.balancer.acquire(c.endpointOf(url))()
 	maxRetries := 2
 	for i := 1; i <= maxRetries; i++ {
+		httpReq, err := http.NewRequest(method, url, bytes.NewBuffer(reqBody))
+		if err != nil {
+			return errors.Wrap(err, "failed to create http request")
+		}
+		httpReq = httpReq.WithContext(ctx)
+
 		headers, err := c.makeHeaders(ctx)
 		if err != nil {
 			return errors.Wrap(err, "failed to make request headers")
@@ -184,40 +412,134 @@ func (c *APIClient) doRequest(ctx context.Context, method, path string, req inte
 			httpReq.Host = c.host
 		}
 
-		httpResp, err := c.cli.Do(httpReq)
+		httpResp, err := c.doHTTP(c.cli, httpReq)
 		if err != nil {
+			// the requests cancelled by the caller say nothing about the server
+			if parent.Err() == nil {
+				breaker.record(false)
+			}
+			if isConnectErr(err) {
+				return connectError{err: err}
+			}
 			return errors.Wrap(ErrDoRequest, err.Error())
 		}
 		defer httpResp.Body.Close()
 
 		httpRespBody, err := io.ReadAll(httpResp.Body)
 		if err != nil {
+			if parent.Err() == nil {
+				breaker.record(false)
+			}
 			return errors.Wrap(ErrReadResponse, err.Error())
 		}
+		breaker.record(httpResp.StatusCode < 500)
 
 		if httpResp.StatusCode == http.StatusUnauthorized {
-			if c.authMethod() == AuthMethodAccessToken && i < maxRetries {
+			_, overridden := ctx.Value(ContextKeyCredentials).(*requestCredentials)
+			if loader := c.tokenLoader(); loader != nil && !overridden && i < maxRetries {
 				// retry with a rotated access token
-				c.accessTokenLoader.LoadAccessToken(context.Background(), true)
+				if _, err := c.loadAccessToken(ctx, loader, true); err != nil {
+					return errors.Wrap(err, "failed to rotate access token")
+				}
 				continue
 			}
-			return NewAPIError("authorization failed", httpResp.StatusCode, httpRespBody)
+			return newResponseError("authorization failed", httpResp, httpRespBody)
+		} else if httpResp.StatusCode == http.StatusTooManyRequests || httpResp.StatusCode == http.StatusServiceUnavailable {
+			apiErr := newResponseError("please retry again later.", httpResp, httpRespBody)
+			apiErr.RetryAfter = parseRetryAfter(httpResp.Header.Get("Retry-After"))
+			return apiErr
 		} else if httpResp.StatusCode >= 500 {
-			return NewAPIError("please retry again later.", httpResp.StatusCode, httpRespBody)
+			return newResponseError("please retry again later.", httpResp, httpRespBody)
 		} else if httpResp.StatusCode >= 400 {
-			return NewAPIError("please check your arguments.", httpResp.StatusCode, httpRespBody)
+			return newResponseError("please check your arguments.", httpResp, httpRespBody)
 		}
 
 		if resp != nil {
 			if err := json.Unmarshal(httpRespBody, &resp); err != nil {
 				return errors.Wrap(err, "failed to unmarshal response body")
 			}
+			if r, ok := resp.(*QueryResponse); ok {
+				r.size = len(httpRespBody)
+				r.requestID = requestIDOf(httpResp.Header)
+			}
 		}
 		return nil
 	}
 	return errors.Errorf("failed to do request after %d retries", maxRetries)
 }
 
+func (c *APIClient) log() Logger {
+	if c.structLogger == nil {
+		return dbLoggerAdapter{}
+	}
+	return c.structLogger
+}
+
+// maxLogSQLLength truncates the queries written to the logs.
+const maxLogSQLLength = 256
+
+// logSQL returns the query to log, the literals are redacted as they may carry the
+// secrets and the user data.
+func logSQL(query string) string {
+	return sqlSnippet(query, maxLogSQLLength, true)
+}
+
+// logQueryEnd logs the query once its last page is returned, elapsed is the time
+// of the request on the client, it's 0 if the query ends on a later page.
+func (c *APIClient) logQueryEnd(resp *QueryResponse, elapsed time.Duration) {
+	if resp.NextURI != "" {
+		return
+	}
+	args := []interface{}{
+		"query_id", resp.ID,
+		"state", resp.State,
+		"rows", resp.Stats.ResultProgress.Rows,
+		"bytes", resp.Stats.ResultProgress.Bytes,
+		"running_time_ms", resp.Stats.RunningTimeMS,
+	}
+	if elapsed > 0 {
+		args = append(args, "elapsed", elapsed)
+	}
+	if resp.Error != nil {
+		c.log().Warn("query failed", append(args, "error", resp.Error)...)
+		return
+	}
+	c.log().Info("query end", args...)
+}
+
+func (c *APIClient) setLastQueryProfile(resp *QueryResponse) {
+	c.profileMu.Lock()
+	defer c.profileMu.Unlock()
+	c.lastQueryProfile = &QueryProfile{
+		QueryID:  resp.ID,
+		State:    resp.State,
+		Stats:    resp.Stats,
+		RawStats: resp.rawStats,
+	}
+}
+
+func (c *APIClient) setLastQueryID(queryID string) {
+	c.profileMu.Lock()
+	defer c.profileMu.Unlock()
+	c.lastQueryID = queryID
+}
+
+// LastQueryID returns the id of the last query started by the client, assigned by
+// the server, so it could be found in system.query_log.
+func (c *APIClient) LastQueryID() string {
+	c.profileMu.Lock()
+	defer c.profileMu.Unlock()
+	return c.lastQueryID
+}
+
+// LastQueryProfile returns the final statistics of the last query finished by the
+// client, it's nil if no query has finished yet.
+func (c *APIClient) LastQueryProfile() *QueryProfile {
+	c.profileMu.Lock()
+	defer c.profileMu.Unlock()
+	return c.lastQueryProfile
+}
+
 func (c *APIClient) trackStats(resp *QueryResponse) {
 	if c.statsTracker == nil {
 		return
@@ -226,12 +548,20 @@ func (c *APIClient) trackStats(resp *QueryResponse) {
 }
 
 func (c *APIClient) makeURL(path string, args ...interface{}) string {
-	format := c.apiEndpoint + path
+	format := path
+	if !isAbsoluteURI(path) {
+		format = c.endpoint() + path
+	}
 	return fmt.Sprintf(format, args...)
 }
 
 func (c *APIClient) authMethod() AuthMethod {
-	if c.user != "" {
+	if c.sessionToken != nil {
+		return AuthMethodSessionToken
+	}
+	// the user without password may be the subject of the access token, like
+	// the key pair authentication
+	if c.user != "" && (c.password != "" || c.accessTokenLoader == nil) {
 		return AuthMethodUserPassword
 	}
 	if c.accessTokenLoader != nil {
@@ -240,26 +570,72 @@ func (c *APIClient) authMethod() AuthMethod {
 	return ""
 }
 
-func (c *APIClient) makeHeaders(ctx context.Context) (http.Header, error) {
-	headers := http.Header{}
-	headers.Set(WarehouseRoute, "warehouse")
-	headers.Set(UserAgent, fmt.Sprintf("databend-go/%s", version))
-	if c.tenant != "" {
-		headers.Set(DatabendTenantHeader, c.tenant)
-	}
-	if c.warehouse != "" {
-		headers.Set(DatabendWarehouseHeader, c.warehouse)
+// tokenLoader returns the loader of the bearer token, it's nil on basic authentication.
+func (c *APIClient) tokenLoader() AccessTokenLoader {
+	switch c.authMethod() {
+	case AuthMethodSessionToken:
+		return c.sessionToken
+	case AuthMethodAccessToken:
+		return c.accessTokenLoader
 	}
+	return nil
+}
 
-	if queryID, ok := ctx.Value(ContextKeyQueryID).(string); ok {
-		headers.Set(DatabendQueryIDHeader, queryID)
+// loadAccessToken loads the token and fires the onTokenRotated hook if it is
+// different from the last loaded one.
+func (c *APIClient) loadAccessToken(ctx context.Context, loader AccessTokenLoader, forceRotate bool) (string, error) {
+	token, err := loader.LoadAccessToken(ctx, forceRotate)
+	if err != nil {
+		return "", err
+	}
+	c.tokenMu.Lock()
+	rotated := token != c.lastToken
+	c.lastToken = token
+	c.tokenMu.Unlock()
+	if rotated {
+		c.log().Info("access token rotated", "auth_method", c.authMethod(), "force", forceRotate)
+		if c.onTokenRotated != nil {
+			c.onTokenRotated(token)
+		}
 	}
+	return token, nil
+}
 
+// requestCredentials overrides the credentials of the client for a single request.
+type requestCredentials struct {
+	user        string
+	password    string
+	accessToken string
+}
+
+// WithUserPassword returns a context to run the queries as the given user instead of
+// the user of the connection, so a service can act on behalf of its end users without
+// a connection pool per user.
+func WithUserPassword(ctx context.Context, user, password string) context.Context {
+	return context.WithValue(ctx, ContextKeyCredentials, &requestCredentials{user: user, password: password})
+}
+
+// WithAccessToken returns a context to run the queries with the given access token
+// instead of the credentials of the connection.
+func WithAccessToken(ctx context.Context, accessToken string) context.Context {
+	return context.WithValue(ctx, ContextKeyCredentials, &requestCredentials{accessToken: accessToken})
+}
+
+func (c *APIClient) makeHeaders(ctx context.Context) (http.Header, error) {
+	headers := c.makeCommonHeaders(ctx)
+	if cred, ok := ctx.Value(ContextKeyCredentials).(*requestCredentials); ok {
+		if cred.accessToken != "" {
+			headers.Set(Authorization, fmt.Sprintf("Bearer %s", cred.accessToken))
+		} else {
+			headers.Set(Authorization, fmt.Sprintf("Basic %s", encode(cred.user, cred.password)))
+		}
+		return headers, nil
+	}
 	switch c.authMethod() {
 	case AuthMethodUserPassword:
 		headers.Set(Authorization, fmt.Sprintf("Basic %s", encode(c.user, c.password)))
-	case AuthMethodAccessToken:
-		accessToken, err := c.accessTokenLoader.LoadAccessToken(context.TODO(), false)
+	case AuthMethodAccessToken, AuthMethodSessionToken:
+		accessToken, err := c.loadAccessToken(ctx, c.tokenLoader(), false)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to load access token")
 		}
@@ -271,19 +647,49 @@ func (c *APIClient) makeHeaders(ctx context.Context) (http.Header, error) {
 	return headers, nil
 }
 
-func encode(name string, key string) string {
-	return base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", name, key)))
+func (c *APIClient) makeCommonHeaders(ctx context.Context) http.Header {
+	headers := http.Header{}
+	headers.Set(WarehouseRoute, "warehouse")
+	ua := c.userAgent
+	if ua == "" {
+		ua = userAgent("")
+	}
+	headers.Set(UserAgent, ua)
+	if c.tenant != "" {
+		headers.Set(DatabendTenantHeader, c.tenant)
+	}
+	c.sessionMu.Lock()
+	warehouse := c.warehouse
+	c.sessionMu.Unlock()
+	if wh, ok := ctx.Value(ContextKeyWarehouse).(string); ok && wh != "" {
+		warehouse = wh
+	}
+	if warehouse != "" {
+		headers.Set(DatabendWarehouseHeader, warehouse)
+	}
+
+	if queryID, ok := ctx.Value(ContextKeyQueryID).(string); ok {
+		headers.Set(DatabendQueryIDHeader, queryID)
+	}
+	if label, ok := ctx.Value(ContextKeyDeduplicateLabel).(string); ok && label != "" {
+		headers.Set(DatabendDeduplicateLabelHeader, label)
+	}
+	if node, ok := ctx.Value(contextKeyStickyNode).(string); ok && node != "" {
+		headers.Set(DatabendStickyNodeHeader, node)
+	}
+	return headers
 }
 
-// databendInsecureTransport is the transport object that doesn't do certificate revocation check.
-var databendInsecureTransport = &http.Transport{
-	MaxIdleConns:    10,
-	IdleConnTimeout: 30 * time.Minute,
-	Proxy:           http.ProxyFromEnvironment,
-	DialContext: (&net.Dialer{
-		Timeout:   30 * time.Second,
-		KeepAlive: 30 * time.Second,
-	}).DialContext,
+// userAgent is the User-Agent of the requests, with the application name first.
+func userAgent(applicationName string) string {
+	if applicationName == "" {
+		return "databend-go/" + version
+	}
+	return applicationName + " databend-go/" + version
+}
+
+func encode(name string, key string) string {
+	return base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", name, key)))
 }
 
 func (c *APIClient) getPagenationConfig() *PaginationConfig {
@@ -298,36 +704,82 @@ func (c *APIClient) getPagenationConfig() *PaginationConfig {
 }
 
 func (c *APIClient) getSessionState() *SessionState {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
 	return &SessionState{
 		Database:       c.database,
 		Role:           c.role,
 		SecondaryRoles: c.secondaryRoles,
 		Settings:       c.sessionSettings,
+		TxnState:       c.txnState,
+		NeedSticky:     c.needSticky,
+		NeedKeepAlive:  c.needKeepAlive,
+		raw:            c.sessionStateRaw,
 	}
 }
 
-func (c *APIClient) DoQuery(ctx context.Context, query string, args []driver.Value) (*QueryResponse, error) {
+// DoQuery starts the query and returns its first response, the opts change the
+// query only.
+func (c *APIClient) DoQuery(ctx context.Context, query string, args []driver.Value, opts ...QueryOption) (*QueryResponse, error) {
+	ctx = withQueryOptions(ctx, opts)
 	q, err := buildQuery(query, args)
 	if err != nil {
 		return nil, err
 	}
-	request := QueryRequest{
-		SQL:        q,
-		Pagination: c.getPagenationConfig(),
-		Session:    c.getSessionState(),
+	if c.txnFailed() && !isTxnEnd(q) {
+		return nil, &TxnNeedsRestartError{Err: ErrTransactionAborted}
 	}
+	inTxn := c.inTxn()
+	ctx = withWriteDeduplication(ctx, q)
+	request := c.queryRequest(ctx, q)
 
 	path := "/v1/query"
 	var result QueryResponse
-	err = c.doRequest(ctx, "POST", path, request, &result)
+	start := time.Now()
+	c.log().Debug("query start", "query_id", queryIDOf(ctx), "sql", logSQL(q))
+	// the sticky session stays on the node which keeps its states
+	sticky := c.needsSticky()
+	if sticky {
+		ctx = c.withStickyNode(ctx)
+	}
+	endpoint := c.pickEndpoint(!sticky)
+	for i := 0; ; i++ {
+		// the query goes to its own endpoint even if the others fail over meanwhile
+		err = c.doRequest(context.WithValue(ctx, contextKeyEndpoint, endpoint), "POST", path, request, &result)
+		// the session state is kept by the client, so a new query could go to any endpoint
+		if err == nil || sticky || i >= len(c.endpoints)-1 || !isFailoverErr(err) {
+			break
+		}
+		endpoint = c.failover(endpoint)
+	}
 	if err != nil {
+		c.log().Warn("query request failed", "query_id", queryIDOf(ctx), "endpoint", endpoint, "error", err)
 		return nil, errors.Wrap(err, "failed to do query request")
 	}
-	if result.Error != nil {
-		return nil, errors.Wrap(result.Error, "query error")
+	c.setLastQueryID(result.ID)
+	if t := queryTrackerOf(ctx); t != nil {
+		// the failed query is not started, but its id is known
+		if result.ID != "" {
+			t.queryID = result.ID
+		}
+		t.requestID = result.requestID
 	}
+	// the failed query may have aborted the transaction
+	c.dropQuerySettings(ctx, &result)
 	c.applySessionState(&result)
+	if result.Error != nil {
+		c.log().Warn("query failed", "query_id", result.ID, "error", result.Error)
+		err = errors.Wrap(result.Error, "query error")
+		if !isTxnEnd(q) && (c.txnFailed() || inTxn && isTxnTimeout(result.Error)) {
+			return nil, &TxnNeedsRestartError{Err: err}
+		}
+		return nil, err
+	}
+	c.pinQueryURIs(&result, endpoint)
+	c.trackNode(&result)
 	c.trackStats(&result)
+	c.logQueryEnd(&result, time.Since(start))
+	c.trackQuery(&result, start, time.Since(start))
 	return &result, nil
 }
 
@@ -335,6 +787,8 @@ func (c *APIClient) applySessionState(response *QueryResponse) {
 	if response.Session == nil {
 		return
 	}
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
 	if response.Session.Database != "" {
 		c.database = response.Session.Database
 	}
@@ -349,6 +803,169 @@ func (c *APIClient) applySessionState(response *QueryResponse) {
 		}
 		c.sessionSettings = newSessionSettings
 	}
+	c.txnState = response.Session.TxnState
+	c.needSticky = response.Session.NeedSticky
+	c.needKeepAlive = response.Session.NeedKeepAlive
+	c.sessionStateRaw = response.Session.raw
+}
+
+// defaultSecondaryRoles returns the secondary roles along with the role.
+//
+// if role is set in config, we'd prefer to limit it as the only effective role,
+// so you could limit the privileges by setting a role with limited privileges.
+// however this can be overridden by executing `SET SECONDARY ROLES ALL` in the
+// query.
+// secondaryRoles now have two viable values:
+// - nil: means enabling ALL the granted roles of the user
+// - []string{}: means enabling NONE of the granted roles
+func defaultSecondaryRoles(role string) *[]string {
+	if len(role) > 0 {
+		return &[]string{}
+	}
+	return nil
+}
+
+// SetRole switches the role of the following queries, like the role in the config,
+// it's the only effective role until SetSecondaryRoles is called.
+func (c *APIClient) SetRole(ctx context.Context, role string) error {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	c.role = role
+	c.secondaryRoles = defaultSecondaryRoles(role)
+	return nil
+}
+
+// SetSecondaryRoles sets the secondary roles of the following queries, nil enables
+// ALL the granted roles of the user, and an empty slice enables NONE of them.
+func (c *APIClient) SetSecondaryRoles(ctx context.Context, roles []string) error {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	if roles == nil {
+		c.secondaryRoles = nil
+		return nil
+	}
+	secondaryRoles := append([]string{}, roles...)
+	c.secondaryRoles = &secondaryRoles
+	return nil
+}
+
+// UseWarehouse switches the warehouse of the following queries.
+func (c *APIClient) UseWarehouse(ctx context.Context, warehouse string) error {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	c.warehouse = warehouse
+	return nil
+}
+
+// WithWarehouse returns a context to run the queries on the given warehouse instead
+// of the warehouse of the connection.
+func WithWarehouse(ctx context.Context, warehouse string) context.Context {
+	return context.WithValue(ctx, ContextKeyWarehouse, warehouse)
+}
+
+// SetSetting sets the session setting, which is sent with the following queries
+// like it's set by a SET statement.
+func (c *APIClient) SetSetting(ctx context.Context, key, value string) error {
+	if key == "" {
+		return errors.New("setting name required")
+	}
+	c.updateSettings(func(settings map[string]string) {
+		settings[key] = value
+	})
+	return nil
+}
+
+// UnsetSetting restores the session setting to its default like UNSET.
+func (c *APIClient) UnsetSetting(ctx context.Context, key string) error {
+	if key == "" {
+		return errors.New("setting name required")
+	}
+	c.updateSettings(func(settings map[string]string) {
+		delete(settings, key)
+	})
+	return nil
+}
+
+// updateSettings updates a copy of the settings, since they may be shared with the
+// Config.Params.
+func (c *APIClient) updateSettings(update func(settings map[string]string)) {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	settings := make(map[string]string, len(c.sessionSettings)+1)
+	for k, v := range c.sessionSettings {
+		settings[k] = v
+	}
+	update(settings)
+	// the settings in sessionStateRaw are replaced by the ones of the SessionState
+	// when it's sent
+	c.sessionSettings = settings
+}
+
+// initialSession is the state of the session when the client is created.
+type initialSession struct {
+	database       string
+	role           string
+	secondaryRoles *[]string
+	warehouse      string
+	settings       map[string]string
+}
+
+// resetSession drops the database, the roles, the warehouse and the settings changed
+// after the client is created, so they do not leak to the next user of the session.
+func (c *APIClient) resetSession() {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	c.database = c.initial.database
+	c.role = c.initial.role
+	c.secondaryRoles = c.initial.secondaryRoles
+	c.warehouse = c.initial.warehouse
+	c.sessionSettings = c.initial.settings
+}
+
+// txnFailed tells whether the transaction of the session is aborted.
+func (c *APIClient) txnFailed() bool {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	return c.txnState == TxnStateFail
+}
+
+// inTxn tells whether the session has a transaction not ended yet.
+func (c *APIClient) inTxn() bool {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	return c.txnState == TxnStateActive || c.txnState == TxnStateFail
+}
+
+// trackNode remembers the node which runs the query, the following requests of the
+// query and the sticky session are routed to it.
+func (c *APIClient) trackNode(response *QueryResponse) {
+	if response.NodeID != "" {
+		c.sessionMu.Lock()
+		c.nodeID = response.NodeID
+		c.sessionMu.Unlock()
+	}
+}
+
+func (c *APIClient) withStickyNode(ctx context.Context) context.Context {
+	c.sessionMu.Lock()
+	nodeID := c.nodeID
+	c.sessionMu.Unlock()
+	if nodeID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKeyStickyNode, nodeID)
+}
+
+// needsSticky tells whether the queries of the session must go to the same node.
+func (c *APIClient) needsSticky() bool {
+	c.sessionMu.Lock()
+	defer c.sessionMu.Unlock()
+	return c.isSticky()
+}
+
+// isSticky is needsSticky with sessionMu held.
+func (c *APIClient) isSticky() bool {
+	return c.needSticky || c.txnState == TxnStateActive
 }
 
 func (c *APIClient) WaitForQuery(ctx context.Context, result *QueryResponse) (*QueryResponse, error) {
@@ -359,13 +976,14 @@ func (c *APIClient) WaitForQuery(ctx context.Context, result *QueryResponse) (*Q
 	for result.NextURI != "" {
 		schema := result.Schema
 		data := result.Data
-		result, err = c.QueryPage(ctx, result.NextURI)
+		nextURI := result.NextURI
+		result, err = c.QueryPage(ctx, nextURI)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to query page")
 		}
 		c.trackStats(result)
 		if result.Error != nil {
-			return nil, errors.Wrap(result.Error, "query page failed")
+			return nil, c.pageError(ctx, result.Error, nextURI, result)
 		}
 		result.Schema = schema
 		result.Data = append(data, result.Data...)
@@ -374,7 +992,8 @@ func (c *APIClient) WaitForQuery(ctx context.Context, result *QueryResponse) (*Q
 	return result, nil
 }
 
-func (c *APIClient) QuerySingle(ctx context.Context, query string, args []driver.Value) (*QueryResponse, error) {
+func (c *APIClient) QuerySingle(ctx context.Context, query string, args []driver.Value, opts ...QueryOption) (*QueryResponse, error) {
+	ctx = withQueryOptions(ctx, opts)
 	result, err := c.DoQuery(ctx, query, args)
 	if err != nil {
 		return nil, err
@@ -394,96 +1013,195 @@ func buildQuery(query string, params []driver.Value) (string, error) {
 	return query, nil
 }
 
-func (c *APIClient) QuerySync(ctx context.Context, query string, args []driver.Value, respCh chan QueryResponse) error {
-	// fmt.Printf("query sync %s", query)
+// QuerySync runs the query and sends its responses to respCh, the opts change the
+// query only.
+func (c *APIClient) QuerySync(ctx context.Context, query string, args []driver.Value, respCh chan QueryResponse, opts ...QueryOption) error {
+	ctx = withQueryOptions(ctx, opts)
+	// the rerun keeps the deduplicate label of the write
+	ctx = withWriteDeduplication(ctx, query)
+	queryID, delivered, err := c.querySync(ctx, query, args, respCh)
+	if queryID != "" && isQueryLost(err) {
+		if delivered || !canRerun(ctx, query) {
+			return &QueryLostError{QueryID: queryID, Err: err}
+		}
+		logger.Infof("rerun query %s after its node is lost", queryID)
+		_, _, err = c.querySync(ctx, query, args, respCh)
+	}
+	return err
+}
+
+// querySync returns the id of the query once it's started, and whether any data
+// has been sent to respCh.
+func (c *APIClient) querySync(ctx context.Context, query string, args []driver.Value, respCh chan QueryResponse) (string, bool, error) {
 	var r0 *QueryResponse
-	err := retry.Do(
-		func() error {
-			r, err := c.DoQuery(ctx, query, args)
-			if err != nil {
-				return err
-			}
-			r0 = r
-			return nil
-		},
-		// other err no need to retry
-		retry.RetryIf(func(err error) bool {
-			if err != nil && (IsProxyErr(err) || strings.Contains(err.Error(), ProvisionWarehouseTimeout)) {
-				return true
-			}
-			return false
-		}),
-		retry.Delay(2*time.Second),
-		retry.Attempts(5),
-		retry.DelayType(retry.FixedDelay),
-	)
+	start := time.Now()
+	ctx, tracker := c.beginQuery(ctx, query)
+	err := c.DoRetry(ctx, func() error {
+		r, err := c.DoQuery(ctx, query, args)
+		if err != nil {
+			return err
+		}
+		r0 = r
+		return nil
+	}, Query)
 	if err != nil {
-		return errors.Wrap(err, "query sync failed")
+		c.failQuery(queryIDOf(ctx), start, err)
+		tracker.end(nil, err)
+		return "", false, errors.Wrap(tracker.wrap(err), "query sync failed")
 	}
+	tracker.started(r0)
 	if r0.Error != nil {
-		return errors.Wrap(r0.Error, "query has error")
+		tracker.end(r0, r0.Error)
+		return "", false, errors.Wrap(tracker.wrap(r0.Error), "query has error")
 	}
-	if err != nil {
-		return err
+	if err := tracker.checkRows(r0); err != nil {
+		// the rows up to the limit are delivered before the error
+		respCh <- *r0
+		tracker.end(r0, err)
+		return r0.ID, len(r0.Data) > 0, tracker.wrap(err)
 	}
 	respCh <- *r0
-	nextUri := r0.NextURI
-	for len(nextUri) != 0 {
-		p, err := c.QueryPage(ctx, nextUri)
+	delivered := len(r0.Data) > 0
+	last := r0
+	for len(last.NextURI) != 0 {
+		p, err := c.QueryPage(ctx, last.NextURI)
 		if err != nil {
-			return err
+			c.failQuery(r0.ID, time.Time{}, err)
+			tracker.end(last, err)
+			return r0.ID, delivered, tracker.wrap(err)
 		}
 		if p.Error != nil {
-			return errors.Wrap(p.Error, "query page has error")
+			tracker.end(p, p.Error)
+			return r0.ID, delivered, tracker.wrap(c.pageError(ctx, p.Error, last.NextURI, p))
+		}
+		if err := tracker.checkRows(p); err != nil {
+			respCh <- *p
+			tracker.end(p, err)
+			return r0.ID, delivered || len(p.Data) > 0, tracker.wrap(err)
 		}
-		nextUri = p.NextURI
+		last = p
 		respCh <- *p
+		delivered = delivered || len(p.Data) > 0
 	}
-	return nil
+	tracker.end(last, nil)
+	return r0.ID, delivered, nil
+}
+
+// pageError adds the page of the query at nextURI and the node of its response to
+// the error.
+func (c *APIClient) pageError(ctx context.Context, err error, nextURI string, resp *QueryResponse) error {
+	pageErr := &PageError{NextURI: nextURI, Err: err}
+	if t := queryTrackerOf(ctx); t != nil {
+		pageErr.Page = t.pages
+	}
+	if resp != nil && resp.NodeID != "" {
+		pageErr.NodeID = resp.NodeID
+	} else if node, ok := ctx.Value(contextKeyStickyNode).(string); ok {
+		pageErr.NodeID = node
+	}
+	return pageErr
 }
 
 func (c *APIClient) QueryPage(ctx context.Context, nextURI string) (*QueryResponse, error) {
 	var result QueryResponse
-	err := retry.Do(
-		func() error {
-			return c.doRequest(ctx, "GET", nextURI, nil, &result)
-		},
-		retry.RetryIf(func(err error) bool {
-			if err == nil {
-				return false
-			}
-			if errors.Is(err, ErrDoRequest) || errors.Is(err, ErrReadResponse) || IsProxyErr(err) {
-				return true
-			}
-			return false
-		}),
-		retry.Delay(1*time.Second),
-		retry.Attempts(3),
-		retry.DelayType(retry.FixedDelay),
-	)
+	ctx = c.withStickyNode(ctx)
+	ctx, span := c.startPageSpan(ctx, nextURI)
+	defer span.End()
+	start := time.Now()
+	if t := queryTrackerOf(ctx); t != nil {
+		t.pages++
+	}
+	err := c.DoRetry(ctx, func() error {
+		return c.doRequestWithTimeout(ctx, c.pageTimeout, "GET", nextURI, nil, &result)
+	}, Page)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to query page")
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, errors.Wrap(c.pageError(ctx, err, nextURI, nil), "failed to query page")
 	}
+	c.trackPage(&result, time.Since(start))
+	if t := queryTrackerOf(ctx); t != nil {
+		t.page(&result)
+	}
+	c.pinQueryURIs(&result, c.endpointOf(nextURI))
+	c.trackNode(&result)
+	c.dropQuerySettings(ctx, &result)
+	c.applySessionState(&result)
 	c.trackStats(&result)
+	c.logQueryEnd(&result, 0)
 	return &result, nil
 }
 
+// Ping checks the server with a cheap query, it's not retried and times out in
+// PingTimeout, so it could be used by the health checks.
+func (c *APIClient) Ping(ctx context.Context) error {
+	timeout := c.pingTimeout
+	if timeout <= 0 {
+		timeout = defaultPingTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	request := QueryRequest{
+		SQL:     "SELECT 1",
+		Session: c.getSessionState(),
+		Pagination: &PaginationConfig{
+			WaitTime:       1,
+			MaxRowsPerPage: 1,
+		},
+	}
+	var result QueryResponse
+	if err := c.doRequestWithTimeout(ctx, 0, "POST", "/v1/query", request, &result); err != nil {
+		return errors.Wrap(err, "ping failed")
+	}
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "ping failed")
+	}
+	if result.FinalURI != "" {
+		// release the query on the server
+		ctx = context.WithValue(ctx, contextKeyStickyNode, result.NodeID)
+		_ = c.doRequestWithTimeout(ctx, 0, "GET", result.FinalURI, nil, nil)
+	}
+	return nil
+}
+
 func (c *APIClient) KillQuery(ctx context.Context, killURI string) error {
-	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	ctx, cancel := context.WithTimeout(c.withStickyNode(ctx), 30*time.Second)
 	defer cancel()
 	return c.doRequest(ctx, "POST", killURI, nil, nil)
 }
 
-func (c *APIClient) InsertWithStage(ctx context.Context, sql string, stage *StageLocation, fileFormatOptions, copyOptions map[string]string) (*QueryResponse, error) {
+// KillQueryID kills the query by its id without its QueryResponse, like a query
+// found in the logs or the system tables. The query is killed by the kill handler of
+// the HTTP queries, or by KILL QUERY if the handler does not know it, like the query
+// runs on another node or is not started over HTTP.
+func (c *APIClient) KillQueryID(ctx context.Context, queryID string) error {
+	if queryID == "" {
+		return errors.New("query id required to kill the query")
+	}
+	err := c.KillQuery(ctx, "/v1/query/"+url.PathEscape(queryID)+"/kill")
+	if !IsNotFound(err) {
+		return err
+	}
+	_, err = c.QuerySingle(ctx, "KILL QUERY ?", []driver.Value{queryID})
+	return errors.Wrapf(err, "failed to kill query %s", queryID)
+}
+
+// InsertWithStage loads the staged files with the stage attached insert sql. If copyOptions
+// is nil, the options set by WithCopyOptions on ctx or the default copy options are used.
+func (c *APIClient) InsertWithStage(ctx context.Context, sql string, stage *StageLocation, fileFormatOptions map[string]string, copyOptions *CopyOptions) (*QueryResponse, error) {
 	if stage == nil {
 		return nil, errors.New("stage location required for insert with stage")
 	}
 	if fileFormatOptions == nil {
 		fileFormatOptions = c.NewDefaultCSVFormatOptions()
 	}
+	if copyOptions == nil {
+		copyOptions, _ = ctx.Value(ContextKeyCopyOptions).(*CopyOptions)
+	}
 	if copyOptions == nil {
 		copyOptions = c.NewDefaultCopyOptions()
 	}
+	ctx = withWriteDeduplication(ctx, sql)
 	request := QueryRequest{
 		SQL:        sql,
 		Pagination: c.getPagenationConfig(),
@@ -491,7 +1209,7 @@ func (c *APIClient) InsertWithStage(ctx context.Context, sql string, stage *Stag
 		StageAttachment: &StageAttachmentConfig{
 			Location:          stage.String(),
 			FileFormatOptions: fileFormatOptions,
-			CopyOptions:       copyOptions,
+			CopyOptions:       copyOptions.toMap(),
 		},
 	}
 
@@ -506,21 +1224,42 @@ func (c *APIClient) InsertWithStage(ctx context.Context, sql string, stage *Stag
 }
 
 func (c *APIClient) UploadToStage(ctx context.Context, stage *StageLocation, input *bufio.Reader, size int64) error {
+	start := time.Now()
+	var err error
 	if c.PresignedURLDisabled {
-		return c.UploadToStageByAPI(ctx, stage, input, size)
+		err = c.UploadToStageByAPI(ctx, stage, input, size)
 	} else {
-		return c.UploadToStageByPresignURL(ctx, stage, input, size)
+		err = c.UploadToStageByPresignURL(ctx, stage, input, size)
 	}
+	if c.metrics != nil {
+		c.metrics.StageUploaded(size, time.Since(start), err)
+	}
+	if err != nil {
+		c.log().Warn("upload to stage failed", "stage", stage.String(), "size", size, "error", err)
+		return err
+	}
+	c.log().Info("upload to stage", "stage", stage.String(), "size", size, "elapsed", time.Since(start))
+	return nil
 }
 
 func (c *APIClient) GetPresignedURL(ctx context.Context, stage *StageLocation) (*PresignedResponse, error) {
+	return c.getPresignedURL(ctx, "UPLOAD", stage)
+}
+
+// GetPresignedDownloadURL returns a presigned url to fetch the given stage file.
+func (c *APIClient) GetPresignedDownloadURL(ctx context.Context, stage *StageLocation) (*PresignedResponse, error) {
+	return c.getPresignedURL(ctx, "DOWNLOAD", stage)
+}
+
+func (c *APIClient) getPresignedURL(ctx context.Context, action string, stage *StageLocation) (*PresignedResponse, error) {
 	var headers string
-	presignUploadSQL := fmt.Sprintf("PRESIGN UPLOAD %s", stage)
-	resp, err := c.QuerySingle(ctx, presignUploadSQL, nil)
+	presignSQL := fmt.Sprintf("PRESIGN %s %s", action, stage)
+	resp, err := c.QuerySingle(ctx, presignSQL, nil)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to query presign url")
 	}
-	if len(resp.Data) < 1 || len(resp.Data[0]) < 2 {
+	// the row is the method, the headers and the url
+	if len(resp.Data) < 1 || len(resp.Data[0]) < 3 {
 		return nil, errors.Errorf("generate presign url invalid response: %+v", resp.Data)
 	}
 
@@ -551,11 +1290,7 @@ func (c *APIClient) UploadToStageByPresignURL(ctx context.Context, stage *StageL
 		req.Header.Set(k, v)
 	}
 	req.ContentLength = size
-	// TODO: configurable timeout
-	httpClient := &http.Client{
-		Timeout: time.Second * 60,
-	}
-	resp, err := httpClient.Do(req)
+	resp, err := c.doHTTP(c.uploadClient(), req)
 	if err != nil {
 		return errors.Wrap(err, "failed to upload to stage by presigned url")
 	}
@@ -604,11 +1339,7 @@ func (c *APIClient) UploadToStageByAPI(ctx context.Context, stage *StageLocation
 	req.Header.Set("stage_name", stage.Name)
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 
-	// TODO: configurable timeout
-	httpClient := &http.Client{
-		Timeout: time.Second * 60,
-	}
-	resp, err := httpClient.Do(req)
+	resp, err := c.doHTTP(c.uploadClient(), req)
 	if err != nil {
 		return errors.Wrap(err, "failed http do request")
 	}
@@ -620,12 +1351,54 @@ func (c *APIClient) UploadToStageByAPI(ctx context.Context, stage *StageLocation
 	}
 
 	if resp.StatusCode == http.StatusUnauthorized {
-		return NewAPIError("please check your user/password.", resp.StatusCode, respBody)
+		return newResponseError("please check your user/password.", resp, respBody)
 	} else if resp.StatusCode >= 500 {
-		return NewAPIError("please retry again later.", resp.StatusCode, respBody)
+		return newResponseError("please retry again later.", resp, respBody)
 	} else if resp.StatusCode >= 400 {
-		return NewAPIError("please check your arguments.", resp.StatusCode, respBody)
+		return newResponseError("please check your arguments.", resp, respBody)
+	}
+
+	return nil
+}
+
+// ErrPresignDisabled is returned by DownloadFromStage when the presigned urls are
+// disabled, as the server has no API to download the stage files.
+var ErrPresignDisabled = errors.New("download from stage requires the presigned url, which is disabled")
+
+// DownloadFromStage writes the stage file to output.
+func (c *APIClient) DownloadFromStage(ctx context.Context, stage *StageLocation, output io.Writer) error {
+	if c.PresignedURLDisabled {
+		return ErrPresignDisabled
+	}
+	return c.DownloadFromStageByPresignURL(ctx, stage, output)
+}
+
+// DownloadFromStageByPresignURL downloads the stage file by PRESIGN DOWNLOAD.
+func (c *APIClient) DownloadFromStageByPresignURL(ctx context.Context, stage *StageLocation, output io.Writer) error {
+	presigned, err := c.GetPresignedDownloadURL(ctx, stage)
+	if err != nil {
+		return errors.Wrap(err, "failed to get presigned download url")
 	}
 
+	req, err := http.NewRequest(presigned.Method, presigned.URL, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	for k, v := range presigned.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := c.doHTTP(c.uploadClient(), req)
+	if err != nil {
+		return errors.Wrap(err, "failed to download from stage by presigned url")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return errors.Errorf("failed to download from stage by presigned url, status code: %d, body: %s", resp.StatusCode, string(respBody))
+	}
+	if _, err = io.Copy(output, resp.Body); err != nil {
+		return errors.Wrap(err, "failed to read stage file")
+	}
 	return nil
 }
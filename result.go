@@ -1,6 +1,10 @@
 package godatabend
 
-import "database/sql/driver"
+import (
+	"database/sql/driver"
+	"strconv"
+	"strings"
+)
 
 var emptyResult driver.Result = noResult{}
 
@@ -13,3 +17,94 @@ func (noResult) LastInsertId() (int64, error) {
 func (noResult) RowsAffected() (int64, error) {
 	return 0, nil
 }
+
+// QueryResult is the result of ExecContext with the stats of the query, its
+// RowsAffected is the number of the rows reported by UPDATE, DELETE, MERGE and COPY,
+// or the number of the rows written.
+//
+// database/sql wraps the driver results, so assert it on the result of
+// DatabendConn.ExecContext in sql.Conn.Raw.
+type QueryResult struct {
+	queryID  string
+	stats    QueryStats
+	warnings []string
+
+	// schema is the schema of the rows returned by the DML
+	schema      []DataField
+	affected    int64
+	hasAffected bool
+}
+
+// Warnings returns the warnings of the query, like the deprecated syntax.
+func (r *QueryResult) Warnings() []string {
+	return r.warnings
+}
+
+// QueryID returns the id of the query assigned by the server.
+func (r *QueryResult) QueryID() string {
+	return r.queryID
+}
+
+// Schema returns the columns of the rows returned by the statement, like the
+// "number of rows inserted" of INSERT.
+func (r *QueryResult) Schema() (Schema, error) {
+	return ParseSchema(r.schema)
+}
+
+func (r *QueryResult) LastInsertId() (int64, error) {
+	return 0, nil
+}
+
+func (r *QueryResult) RowsAffected() (int64, error) {
+	if r.hasAffected {
+		return r.affected, nil
+	}
+	return int64(r.stats.WriteProgress.Rows), nil
+}
+
+// addRows counts the rows in the result of the DML, like the "number of rows
+// updated" of UPDATE, which is exact while the write progress of UPDATE and DELETE
+// includes the rows rewritten in the same blocks, and the Rows_loaded of COPY.
+func (r *QueryResult) addRows(resp *QueryResponse) {
+	if len(resp.Schema) > 0 {
+		r.schema = resp.Schema
+	}
+	var columns []int
+	for i, field := range r.schema {
+		name := strings.ToLower(field.Name)
+		if strings.HasPrefix(name, "number of rows") || name == "rows_loaded" {
+			columns = append(columns, i)
+		}
+	}
+	for _, row := range resp.Data {
+		for _, i := range columns {
+			if i >= len(row) {
+				continue
+			}
+			if n, err := strconv.ParseInt(row[i], 10, 64); err == nil {
+				r.affected += n
+				r.hasAffected = true
+			}
+		}
+	}
+}
+
+// RowsWritten returns the number of the rows written by the query.
+func (r *QueryResult) RowsWritten() uint64 {
+	return r.stats.WriteProgress.Rows
+}
+
+// BytesWritten returns the number of the bytes written by the query.
+func (r *QueryResult) BytesWritten() uint64 {
+	return r.stats.WriteProgress.Bytes
+}
+
+// RowsRead returns the number of the rows scanned by the query.
+func (r *QueryResult) RowsRead() uint64 {
+	return r.stats.ScanProgress.Rows
+}
+
+// Stats returns the stats of the query in its last response.
+func (r *QueryResult) Stats() QueryStats {
+	return r.stats
+}
@@ -0,0 +1,247 @@
+package godatabend
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/avast/retry-go"
+)
+
+// RequestType tells DoRetry which retry policy applies to a request.
+type RequestType int
+
+const (
+	// Query is the initial request which starts a query.
+	Query RequestType = iota
+	// Page is a request fetching the next page of a running query.
+	Page
+)
+
+func (t RequestType) String() string {
+	switch t {
+	case Query:
+		return "query"
+	case Page:
+		return "page"
+	}
+	return fmt.Sprintf("RequestType(%d)", int(t))
+}
+
+const (
+	RETRY_DELAY_FIXED   = "fixed"
+	RETRY_DELAY_BACKOFF = "backoff"
+)
+
+// maxRetryAfterDelay caps the delay honored from a Retry-After header.
+const maxRetryAfterDelay = 30 * time.Second
+
+// RetryPolicy configures the retries of a type of requests, the zero fields mean
+// the defaults of the request type.
+type RetryPolicy struct {
+	// Attempts is the total number of attempts, 1 disables the retries.
+	Attempts uint
+	// Delay is the delay before the first retry.
+	Delay time.Duration
+	// MaxDelay caps the delays growing with RETRY_DELAY_BACKOFF.
+	MaxDelay time.Duration
+	// MaxJitter is the upper bound of a random delay added to each delay.
+	MaxJitter time.Duration
+	// DelayType is RETRY_DELAY_FIXED or RETRY_DELAY_BACKOFF.
+	DelayType string
+}
+
+var defaultRetryPolicies = map[RequestType]RetryPolicy{
+	Query: {Attempts: 5, Delay: 2 * time.Second, DelayType: RETRY_DELAY_FIXED},
+	Page:  {Attempts: 3, Delay: 1 * time.Second, DelayType: RETRY_DELAY_FIXED},
+}
+
+// withDefaults fills the zero fields of p with the ones of d.
+func (p RetryPolicy) withDefaults(d RetryPolicy) RetryPolicy {
+	if p.Attempts == 0 {
+		p.Attempts = d.Attempts
+	}
+	if p.Delay == 0 {
+		p.Delay = d.Delay
+	}
+	if p.MaxDelay == 0 {
+		p.MaxDelay = d.MaxDelay
+	}
+	if p.MaxJitter == 0 {
+		p.MaxJitter = d.MaxJitter
+	}
+	if p.DelayType == "" {
+		p.DelayType = d.DelayType
+	}
+	return p
+}
+
+func (p *RetryPolicy) addParam(key, value string) (err error) {
+	switch key {
+	case "attempts":
+		var attempts uint64
+		attempts, err = strconv.ParseUint(value, 10, 32)
+		p.Attempts = uint(attempts)
+	case "delay":
+		p.Delay, err = parseDuration(value)
+	case "max_delay":
+		p.MaxDelay, err = parseDuration(value)
+	case "jitter":
+		p.MaxJitter, err = parseDuration(value)
+	case "delay_type":
+		switch value {
+		case RETRY_DELAY_FIXED, RETRY_DELAY_BACKOFF:
+			p.DelayType = value
+		default:
+			return fmt.Errorf("unknown delay type")
+		}
+	}
+	return
+}
+
+func (p *RetryPolicy) formatParams(query url.Values, prefix string) {
+	if p.Attempts != 0 {
+		query.Set(prefix+"attempts", strconv.FormatUint(uint64(p.Attempts), 10))
+	}
+	if p.Delay != 0 {
+		query.Set(prefix+"delay", p.Delay.String())
+	}
+	if p.MaxDelay != 0 {
+		query.Set(prefix+"max_delay", p.MaxDelay.String())
+	}
+	if p.MaxJitter != 0 {
+		query.Set(prefix+"jitter", p.MaxJitter.String())
+	}
+	if p.DelayType != "" {
+		query.Set(prefix+"delay_type", p.DelayType)
+	}
+}
+
+// delay returns the delay after the n-th failed attempt, it prefers the server's
+// Retry-After.
+func (p RetryPolicy) delay(n uint, err error) time.Duration {
+	if d := retryAfterDelay(err); d > 0 {
+		return d
+	}
+	d := p.Delay
+	if p.DelayType == RETRY_DELAY_BACKOFF {
+		for i := uint(0); i < n && d < time.Hour; i++ {
+			d *= 2
+		}
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if p.MaxJitter > 0 {
+		d += time.Duration(rand.Int63n(int64(p.MaxJitter)))
+	}
+	return d
+}
+
+// retryPolicy returns the retry policy of the request type with the defaults filled.
+func (c *APIClient) retryPolicy(t RequestType) RetryPolicy {
+	policy := c.retryPolicies[t].withDefaults(defaultRetryPolicies[t])
+	if c.retryDisabled {
+		policy.Attempts = 1
+	}
+	return policy
+}
+
+// DoRetry runs f with the retry policy of the given request type. When the
+// server answers 429/503 with a Retry-After header, the next attempt waits
+// for the requested delay (capped) instead of the policy's one. It stops
+// retrying once ctx is done, or its deadline comes before the next attempt.
+func (c *APIClient) DoRetry(ctx context.Context, f retry.RetryableFunc, t RequestType) error {
+	retryIf := c.retryIf
+	if retryIf == nil {
+		retryIf = IsRetryable
+	}
+	policy := c.retryPolicy(t)
+	var (
+		n    uint
+		next time.Duration
+	)
+	return retry.Do(
+		f,
+		retry.RetryIf(func(err error) bool {
+			if err == nil || ctx.Err() != nil || !retryIf(err, t) {
+				return false
+			}
+			next = policy.delay(n, err)
+			n++
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < next {
+				return false
+			}
+			c.log().Warn("retry request", "type", t, "attempt", n, "delay", next, "error", err)
+			if c.metrics != nil {
+				c.metrics.RequestRetried(t, err)
+			}
+			return true
+		}),
+		retry.Context(ctx),
+		// keep the error of the last attempt unwrappable by errors.Is and errors.As
+		retry.LastErrorOnly(true),
+		retry.Attempts(policy.Attempts),
+		retry.DelayType(func(uint, error, *retry.Config) time.Duration {
+			return next
+		}),
+	)
+}
+
+// IsRetryable is the built-in rule whether a failed request of the request type is
+// retried, Config.RetryIf can call it to extend the rule rather than replace it.
+func IsRetryable(err error, t RequestType) bool {
+	if IsProxyErr(err) || IsRetryLater(err) {
+		return true
+	}
+	switch t {
+	case Query:
+		return IsProvisionWarehouseTimeout(err)
+	default:
+		return errors.Is(err, ErrDoRequest) || errors.Is(err, ErrReadResponse)
+	}
+}
+
+// IsTransient reports whether the operation failed with err is safe to be run again
+// by the application, like the network errors, the 5xx responses, the warehouse
+// still provisioning and the lost queries. The errors of the statements like the
+// syntax errors and permission denied are permanent, so are the canceled contexts.
+func IsTransient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var lostErr *QueryLostError
+	if errors.As(err, &lostErr) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, ErrDoRequest) ||
+		errors.Is(err, ErrReadResponse) ||
+		errors.Is(err, ErrCircuitOpen) ||
+		IsProvisionWarehouseTimeout(err) {
+		return true
+	}
+	var apiErr APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500 || apiErr.StatusCode == http.StatusTooManyRequests
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// retryAfterDelay returns the capped Retry-After of err, or 0 if there is none.
+func retryAfterDelay(err error) time.Duration {
+	var apiErr APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		if apiErr.RetryAfter > maxRetryAfterDelay {
+			return maxRetryAfterDelay
+		}
+		return apiErr.RetryAfter
+	}
+	return 0
+}
@@ -6,8 +6,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"reflect"
-	"strings"
+	"strconv"
+	"time"
 )
 
 type nextRows struct {
@@ -16,7 +18,16 @@ type nextRows struct {
 	respData *QueryResponse
 	columns  []string
 	types    []string
+	descs    []*TypeDesc
+	schema   Schema
 	parsers  []DataParser
+	// tracker observes the query until the rows are done
+	tracker *queryTracker
+	// err is returned after the rows, like ErrResultTooLarge after the rows up to
+	// Config.MaxResultRows.
+	err error
+	// delivered is set once a row is returned
+	delivered bool
 }
 
 func waitForQueryResult(ctx context.Context, dc *DatabendConn, result *QueryResponse) (*QueryResponse, error) {
@@ -28,18 +39,19 @@ func waitForQueryResult(ctx context.Context, dc *DatabendConn, result *QueryResp
 	var err error
 	for result.NextURI != "" && len(result.Data) == 0 {
 		dc.log("wait for query result", result.NextURI)
-		result, err = dc.rest.QueryPage(ctx, result.NextURI)
+		prev := result
+		result, err = dc.rest.QueryPage(ctx, prev.NextURI)
 		if errors.Is(err, context.Canceled) {
 			// context might be canceled due to timeout or canceled. if it's canceled, we need call
 			// the kill url to tell the backend it's killed.
-			dc.log("query canceled", result.ID)
-			dc.rest.KillQuery(context.Background(), result.KillURI)
+			dc.log("query canceled", prev.ID)
+			dc.rest.KillQuery(context.Background(), prev.KillURI)
 			return nil, err
 		} else if err != nil {
 			return nil, err
 		}
 		if result.Error != nil {
-			return nil, result.Error
+			return nil, dc.rest.pageError(ctx, result.Error, prev.NextURI, result)
 		}
 	}
 	result.Schema = schema
@@ -60,14 +72,19 @@ func newNextRows(ctx context.Context, dc *DatabendConn, resp *QueryResponse) (*n
 		types = append(types, field.Type)
 	}
 
+	opt := &DataParserOptions{TypedValues: dc.typedValues}
+	descs := make([]*TypeDesc, len(types))
+	schema := make(Schema, len(types))
 	parsers := make([]DataParser, len(types))
 	for i, typ := range types {
 		desc, err := ParseTypeDesc(typ)
 		if err != nil {
 			return nil, fmt.Errorf("newTextRows: failed to parse a description of the type '%s': %w", typ, err)
 		}
+		descs[i] = desc
+		schema[i] = NewField(columns[i], desc)
 
-		parsers[i], err = NewDataParser(desc, &DataParserOptions{})
+		parsers[i], err = NewDataParser(desc, opt)
 		if err != nil {
 			return nil, fmt.Errorf("newTextRows: failed to create a data parser for the type '%s': %w", typ, err)
 		}
@@ -79,16 +96,38 @@ func newNextRows(ctx context.Context, dc *DatabendConn, resp *QueryResponse) (*n
 		respData: result,
 		columns:  columns,
 		types:    types,
+		descs:    descs,
+		schema:   schema,
 		parsers:  parsers,
 	}
 	return rows, nil
 }
 
+// QueryID returns the id of the query assigned by the server.
+func (r *nextRows) QueryID() string {
+	return r.respData.ID
+}
+
+// Warnings returns the warnings of the query received so far.
+func (r *nextRows) Warnings() []string {
+	if r.tracker == nil {
+		return nil
+	}
+	return r.tracker.warnings
+}
+
+// Schema returns the columns with their types parsed.
+func (r *nextRows) Schema() Schema {
+	return r.schema
+}
+
 func (r *nextRows) Columns() []string {
 	return r.columns
 }
 
 func (r *nextRows) Close() error {
+	r.dc.rest.forgetQuery(r.respData.ID)
+	defer r.endQuery(nil)
 	if len(r.respData.NextURI) != 0 {
 		_, err := r.dc.rest.QueryPage(r.dc.ctx, r.respData.NextURI)
 		if err != nil {
@@ -101,23 +140,20 @@ func (r *nextRows) Close() error {
 
 func (r *nextRows) Next(dest []driver.Value) error {
 	if len(r.respData.Data) == 0 {
-		resp, err := waitForQueryResult(r.ctx, r.dc, r.respData)
-		if err != nil {
+		if err := r.nextPage(); err != nil {
 			return err
 		}
-		r.respData = resp
 	}
 
 	if len(r.respData.Data) == 0 {
-		return io.EOF
+		return r.done()
 	}
 
-	lineData := r.respData.Data[0]
-	r.respData.Data = r.respData.Data[1:]
+	lineData, nulls := r.respData.popRow()
+	r.delivered = true
 
 	for j := range lineData {
-		reader := strings.NewReader(lineData[j])
-		v, err := r.parsers[j].Parse(reader)
+		v, err := parseCell(r.parsers[j], lineData[j], j < len(nulls) && nulls[j])
 		if err != nil {
 			r.dc.log("parse error ", err)
 			return err
@@ -127,6 +163,46 @@ func (r *nextRows) Next(dest []driver.Value) error {
 	return nil
 }
 
+// nextPage waits for the next page with rows, the page is the last one if it has
+// no rows.
+func (r *nextRows) nextPage() error {
+	resp, err := waitForQueryResult(r.ctx, r.dc, r.respData)
+	if err != nil {
+		r.dc.rest.failQuery(r.respData.ID, time.Time{}, err)
+		r.endQuery(err)
+	}
+	if r.delivered && isQueryLost(err) {
+		// some rows have been returned, so the query can not be run again
+		return &QueryLostError{QueryID: r.respData.ID, Err: err}
+	} else if err != nil {
+		if r.tracker != nil {
+			return r.tracker.wrap(err)
+		}
+		return err
+	}
+	r.respData = resp
+	if r.tracker != nil && r.err == nil {
+		r.err = r.tracker.checkRows(resp)
+	}
+	return nil
+}
+
+// done ends the query after the last row, it returns io.EOF or the error after the
+// rows.
+func (r *nextRows) done() error {
+	r.endQuery(r.err)
+	if r.err != nil {
+		return r.tracker.wrap(r.err)
+	}
+	return io.EOF
+}
+
+func (r *nextRows) endQuery(err error) {
+	if r.tracker != nil {
+		r.tracker.end(r.respData, err)
+	}
+}
+
 // ColumnTypeScanType implements the driver.RowsColumnTypeScanType
 func (r *nextRows) ColumnTypeScanType(index int) reflect.Type {
 	return r.parsers[index].Type()
@@ -137,20 +213,49 @@ func (r *nextRows) ColumnTypeDatabaseTypeName(index int) string {
 	return r.types[index]
 }
 
-// // ColumnTypeDatabaseTypeName implements the driver.RowsColumnTypeLength
-// func (r *nextRows) ColumnTypeLength(index int) (int64, bool) {
-// 	// TODO: implement this
-// 	return 10, true
-// }
+// ColumnTypeLength implements the driver.RowsColumnTypeLength, String and Binary
+// are variable length without a limit.
+func (r *nextRows) ColumnTypeLength(index int) (int64, bool) {
+	desc := unwrapNullable(r.descs[index])
+	switch desc.Name {
+	case "String", "Binary":
+		return math.MaxInt64, true
+	case "FixedString":
+		if len(desc.Args) == 1 {
+			if n, err := strconv.ParseInt(desc.Args[0].Name, 10, 64); err == nil {
+				return n, true
+			}
+		}
+	}
+	return 0, false
+}
 
-// // ColumnTypeDatabaseTypeName implements the driver.RowsColumnTypeNullable
-// func (r *nextRows) ColumnTypeNullable(index int) (bool, bool) {
-// 	// TODO: implement this
-// 	return true, true
-// }
+// ColumnTypeNullable implements the driver.RowsColumnTypeNullable
+func (r *nextRows) ColumnTypeNullable(index int) (bool, bool) {
+	return r.schema[index].Nullable, true
+}
 
-// // ColumnTypeDatabaseTypeName implements the driver.RowsColumnTypePrecisionScale
-// func (r *nextRows) ColumnTypePrecisionScale(index int) (int64, int64, bool) {
-// 	// TODO: implement this
-// 	return 10, 10, true
-// }
+// ColumnTypePrecisionScale implements the driver.RowsColumnTypePrecisionScale for
+// the Decimal(precision, scale) columns.
+func (r *nextRows) ColumnTypePrecisionScale(index int) (int64, int64, bool) {
+	desc := unwrapNullable(r.descs[index])
+	if desc.Name != "Decimal" || len(desc.Args) != 2 {
+		return 0, 0, false
+	}
+	precision, err := strconv.ParseInt(desc.Args[0].Name, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	scale, err := strconv.ParseInt(desc.Args[1].Name, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return precision, scale, true
+}
+
+func unwrapNullable(desc *TypeDesc) *TypeDesc {
+	for desc.Name == "Nullable" && len(desc.Args) == 1 {
+		desc = desc.Args[0]
+	}
+	return desc
+}
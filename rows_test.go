@@ -2,6 +2,7 @@ package godatabend
 
 import (
 	"context"
+	"math"
 	"reflect"
 	"testing"
 
@@ -28,3 +29,37 @@ func TestTextRows(t *testing.T) {
 	assert.Equal(t, "Int32", rows.ColumnTypeDatabaseTypeName(0))
 	assert.Equal(t, "String", rows.ColumnTypeDatabaseTypeName(2))
 }
+
+func TestColumnTypeMetadata(t *testing.T) {
+	rows, err := newNextRows(context.Background(), &DatabendConn{}, &QueryResponse{
+		Schema: []DataField{
+			{Name: "d", Type: "Decimal(38, 10)"},
+			{Name: "nd", Type: "Nullable(Decimal(10, 2))"},
+			{Name: "s", Type: "String"},
+			{Name: "b", Type: "Nullable(Binary)"},
+			{Name: "i", Type: "Int32"},
+		},
+		State: "Succeeded",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for i, c := range []struct {
+		precision, scale int64
+		ok               bool
+	}{{38, 10, true}, {10, 2, true}, {0, 0, false}, {0, 0, false}, {0, 0, false}} {
+		precision, scale, ok := rows.ColumnTypePrecisionScale(i)
+		assert.Equal(t, c.ok, ok)
+		assert.Equal(t, c.precision, precision)
+		assert.Equal(t, c.scale, scale)
+	}
+	for i, c := range []struct {
+		length int64
+		ok     bool
+	}{{0, false}, {0, false}, {math.MaxInt64, true}, {math.MaxInt64, true}, {0, false}} {
+		length, ok := rows.ColumnTypeLength(i)
+		assert.Equal(t, c.ok, ok)
+		assert.Equal(t, c.length, length)
+	}
+}
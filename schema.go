@@ -0,0 +1,80 @@
+package godatabend
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Field is a column of the results with its type parsed.
+type Field struct {
+	Name string
+	// Type is the type of the values, it's T of Nullable(T).
+	Type *TypeDesc
+	// Nullable tells whether the values could be NULL.
+	Nullable bool
+}
+
+// TypeName returns the type of the column like the server, like Nullable(Int32).
+func (f Field) TypeName() string {
+	if f.Nullable && f.Type.Name != "NULL" && f.Type.Name != "Nothing" {
+		return "Nullable(" + f.Type.String() + ")"
+	}
+	return f.Type.String()
+}
+
+// Schema is the columns of the results.
+type Schema []Field
+
+// ParseSchema parses the types of the schema of QueryResponse.
+func ParseSchema(fields []DataField) (Schema, error) {
+	schema := make(Schema, len(fields))
+	for i, field := range fields {
+		desc, err := ParseTypeDesc(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the type '%s' of %s: %w", field.Type, field.Name, err)
+		}
+		schema[i] = NewField(field.Name, desc)
+	}
+	return schema, nil
+}
+
+// NewField returns the column of the type, the Nullable types are unwrapped.
+func NewField(name string, desc *TypeDesc) Field {
+	t := unwrapNullable(desc)
+	return Field{
+		Name:     name,
+		Type:     t,
+		Nullable: t != desc || t.Name == "NULL" || t.Name == "Nothing",
+	}
+}
+
+// Names returns the names of the columns.
+func (s Schema) Names() []string {
+	names := make([]string, len(s))
+	for i, f := range s {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// Index returns the index of the column, or -1 if there is no such column.
+func (s Schema) Index(name string) int {
+	for i, f := range s {
+		if f.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// String returns the type like the server, like Array(Nullable(String)).
+func (t *TypeDesc) String() string {
+	if len(t.Args) == 0 {
+		return t.Name
+	}
+	args := make([]string, len(t.Args))
+	for i, arg := range t.Args {
+		args[i] = arg.String()
+	}
+	return t.Name + "(" + strings.Join(args, ", ") + ")"
+}
@@ -0,0 +1,58 @@
+package godatabend
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchema(t *testing.T) {
+	schema, err := ParseSchema([]DataField{
+		{Name: "a", Type: "Int32"},
+		{Name: "b", Type: "Nullable(Decimal(10, 2))"},
+		{Name: "c", Type: "Array(Nullable(String))"},
+		{Name: "d", Type: "NULL"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "d"}, schema.Names())
+	assert.Equal(t, 2, schema.Index("c"))
+	assert.Equal(t, -1, schema.Index("e"))
+
+	for i, c := range []struct {
+		typeName string
+		nullable bool
+	}{
+		{"Int32", false},
+		{"Nullable(Decimal(10, 2))", true},
+		{"Array(Nullable(String))", false},
+		{"NULL", true},
+	} {
+		assert.Equal(t, c.typeName, schema[i].TypeName())
+		assert.Equal(t, c.nullable, schema[i].Nullable)
+	}
+	assert.Equal(t, "Decimal", schema[1].Type.Name)
+	assert.Equal(t, "Nullable", schema[2].Type.Args[0].Name)
+
+	_, err = ParseSchema([]DataField{{Name: "a", Type: "Array("}})
+	assert.Error(t, err)
+}
+
+func TestRowsSchema(t *testing.T) {
+	rows, err := newNextRows(context.Background(), &DatabendConn{}, &QueryResponse{
+		Schema: []DataField{
+			{Name: "a", Type: "Int32"},
+			{Name: "b", Type: "Nullable(String)"},
+		},
+		State: "Succeeded",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "b", rows.Schema()[1].Name)
+	nullable, ok := rows.ColumnTypeNullable(0)
+	assert.True(t, ok)
+	assert.False(t, nullable)
+	nullable, ok = rows.ColumnTypeNullable(1)
+	assert.True(t, ok)
+	assert.True(t, nullable)
+}
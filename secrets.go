@@ -0,0 +1,77 @@
+package godatabend
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SecretProvider resolves the secret values like the password or the access token
+// by name when the connection is opened, so they can be kept in a secrets manager
+// like Vault or AWS Secrets Manager instead of the DSN.
+type SecretProvider interface {
+	GetSecret(ctx context.Context, name string) (string, error)
+}
+
+// SecretProviderFunc adapts a function to SecretProvider.
+type SecretProviderFunc func(ctx context.Context, name string) (string, error)
+
+func (f SecretProviderFunc) GetSecret(ctx context.Context, name string) (string, error) {
+	return f(ctx, name)
+}
+
+// EnvSecretProvider reads the secrets from the environment variables.
+type EnvSecretProvider struct{}
+
+func (EnvSecretProvider) GetSecret(ctx context.Context, name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", errors.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
+
+// FileSecretProvider reads the secrets from the files named by the secrets in Dir,
+// like the secrets mounted by Kubernetes or Docker.
+type FileSecretProvider struct {
+	Dir string
+}
+
+func (p FileSecretProvider) GetSecret(ctx context.Context, name string) (string, error) {
+	buf, err := ioutil.ReadFile(filepath.Join(p.Dir, name))
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read secret %s", name)
+	}
+	return strings.TrimRight(string(buf), "\r\n"), nil
+}
+
+// resolveSecrets fills the password and the access token from the secret provider,
+// the environment variables are used if no provider is set.
+func (cfg *Config) resolveSecrets(ctx context.Context) error {
+	if cfg.PasswordSecret == "" && cfg.AccessTokenSecret == "" {
+		return nil
+	}
+	provider := cfg.SecretProvider
+	if provider == nil {
+		provider = EnvSecretProvider{}
+	}
+	if cfg.PasswordSecret != "" {
+		password, err := provider.GetSecret(ctx, cfg.PasswordSecret)
+		if err != nil {
+			return errors.Wrap(err, "failed to resolve password")
+		}
+		cfg.Password = password
+	}
+	if cfg.AccessTokenSecret != "" {
+		token, err := provider.GetSecret(ctx, cfg.AccessTokenSecret)
+		if err != nil {
+			return errors.Wrap(err, "failed to resolve access token")
+		}
+		cfg.AccessToken = token
+	}
+	return nil
+}
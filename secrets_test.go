@@ -0,0 +1,30 @@
+package godatabend
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveSecrets(t *testing.T) {
+	t.Setenv("TEST_DATABEND_PASSWORD", "pass1")
+	cfg, err := ParseDSN("databend://root@localhost:8000/default?password_secret=TEST_DATABEND_PASSWORD")
+	require.NoError(t, err)
+	require.NoError(t, cfg.resolveSecrets(context.Background()))
+	assert.Equal(t, "pass1", cfg.Password)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "token"), []byte("abc\n"), 0600))
+	cfg = NewConfig()
+	cfg.AccessTokenSecret = "token"
+	cfg.SecretProvider = FileSecretProvider{Dir: dir}
+	require.NoError(t, cfg.resolveSecrets(context.Background()))
+	assert.Equal(t, "abc", cfg.AccessToken)
+
+	cfg.AccessTokenSecret = "missing"
+	assert.Error(t, cfg.resolveSecrets(context.Background()))
+}
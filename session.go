@@ -0,0 +1,115 @@
+package godatabend
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// ErrSessionLost is returned by Session when its connection is broken or closed, the
+// temporary tables, session variables and transaction on it are gone.
+var ErrSessionLost = errors.New("databend: the session of the pinned connection is lost")
+
+// Session pins a single connection of the pool, all the work on it shares the same
+// server session. Use it for the workloads depending on the session, like temporary
+// tables, SET variables and transactions started with BEGIN, which may be lost
+// silently when the pool hands the statements to different connections.
+type Session struct {
+	conn   *sql.Conn
+	client *APIClient
+}
+
+// PinSession takes a connection from the pool for the Session, the connection is
+// returned to the pool on Close.
+func PinSession(ctx context.Context, db *sql.DB) (*Session, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{conn: conn}
+	err = conn.Raw(func(driverConn interface{}) error {
+		dc, ok := driverConn.(*DatabendConn)
+		if !ok {
+			return fmt.Errorf("databend: unexpected driver connection %T", driverConn)
+		}
+		s.client = dc.rest
+		return nil
+	})
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Client returns the APIClient of the pinned connection, to change the session like
+// SetSetting or UseWarehouse.
+func (s *Session) Client() *APIClient {
+	return s.client
+}
+
+// Conn returns the pinned connection.
+func (s *Session) Conn() *sql.Conn {
+	return s.conn
+}
+
+func (s *Session) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	result, err := s.conn.ExecContext(ctx, query, args...)
+	return result, s.sessionErr(err)
+}
+
+func (s *Session) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	rows, err := s.conn.QueryContext(ctx, query, args...)
+	return rows, s.sessionErr(err)
+}
+
+// QueryRowContext runs the query on the pinned connection, the error of the
+// session is returned on Scan like the other errors.
+func (s *Session) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return s.conn.QueryRowContext(ctx, query, args...)
+}
+
+func (s *Session) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	tx, err := s.conn.BeginTx(ctx, opts)
+	return tx, s.sessionErr(err)
+}
+
+// Close returns the connection to the pool, the session is reset before it is
+// used by others.
+func (s *Session) Close() error {
+	return s.conn.Close()
+}
+
+// check makes sure the work still runs on the session pinned at first.
+func (s *Session) check() error {
+	err := s.conn.Raw(func(driverConn interface{}) error {
+		dc, ok := driverConn.(*DatabendConn)
+		if !ok || !dc.IsValid() || dc.rest != s.client {
+			return ErrSessionLost
+		}
+		return nil
+	})
+	if errors.Is(err, sql.ErrConnDone) {
+		return errors.Wrap(ErrSessionLost, err.Error())
+	}
+	return err
+}
+
+func (s *Session) sessionErr(err error) error {
+	if errors.Is(err, driver.ErrBadConn) {
+		return errors.Wrap(ErrSessionLost, err.Error())
+	}
+	return err
+}
@@ -0,0 +1,43 @@
+package godatabend
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPinSession(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"id":"q1","state":"Succeeded"}`))
+	}))
+	defer ts.Close()
+
+	db, err := sql.Open("databend", "databend://root:root@"+strings.TrimPrefix(ts.URL, "http://")+"/default?sslmode=disable")
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	s, err := PinSession(ctx, db)
+	require.NoError(t, err)
+	require.NotNil(t, s.Client())
+	_, err = s.ExecContext(ctx, "CREATE TEMP TABLE t(a int)")
+	require.NoError(t, err)
+
+	// the connection is broken
+	require.NoError(t, s.Conn().Raw(func(driverConn interface{}) error {
+		return driverConn.(*DatabendConn).Close()
+	}))
+	_, err = s.ExecContext(ctx, "INSERT INTO t VALUES (1)")
+	assert.True(t, errors.Is(err, ErrSessionLost))
+
+	require.NoError(t, s.Close())
+	_, err = s.QueryContext(ctx, "SELECT * FROM t")
+	assert.True(t, errors.Is(err, ErrSessionLost))
+}
@@ -0,0 +1,173 @@
+package godatabend
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type sessionTokens struct {
+	SessionToken          string `json:"session_token"`
+	RefreshToken          string `json:"refresh_token"`
+	SessionTokenTTLInSecs int64  `json:"session_token_ttl_in_secs"`
+	RefreshTokenTTLInSecs int64  `json:"refresh_token_ttl_in_secs"`
+}
+
+type loginRequest struct {
+	Database string            `json:"database,omitempty"`
+	Role     string            `json:"role,omitempty"`
+	Settings map[string]string `json:"settings,omitempty"`
+}
+
+type refreshRequest struct {
+	SessionToken string `json:"session_token"`
+}
+
+type sessionTokenResponse struct {
+	Version   string         `json:"version"`
+	SessionID string         `json:"session_id"`
+	Tokens    *sessionTokens `json:"tokens"`
+	Error     *QueryError    `json:"error"`
+}
+
+// sessionTokenLoader exchanges the user and password for a session token once with
+// /v1/session/login, and refreshes it with the refresh token before it expires or
+// when the server rejects it. It falls back to login again if the refresh fails.
+type sessionTokenLoader struct {
+	client *APIClient
+
+	mu            sync.Mutex
+	tokens        *sessionTokens
+	sessionExpiry time.Time
+	refreshExpiry time.Time
+}
+
+func newSessionTokenLoader(client *APIClient) *sessionTokenLoader {
+	return &sessionTokenLoader{client: client}
+}
+
+func (l *sessionTokenLoader) LoadAccessToken(ctx context.Context, forceRotate bool) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if l.tokens != nil && !forceRotate && now.Before(l.sessionExpiry) {
+		return l.tokens.SessionToken, nil
+	}
+	if l.tokens != nil && now.Before(l.refreshExpiry) {
+		err := l.refresh(ctx)
+		if err == nil {
+			return l.tokens.SessionToken, nil
+		}
+		logger.WithContext(ctx).Warnf("refresh session token failed, login again: %v", err)
+	}
+	if err := l.login(ctx); err != nil {
+		return "", err
+	}
+	return l.tokens.SessionToken, nil
+}
+
+func (l *sessionTokenLoader) login(ctx context.Context) error {
+	c := l.client
+	req := loginRequest{
+		Database: c.database,
+		Role:     c.role,
+		Settings: c.sessionSettings,
+	}
+	authHeader := fmt.Sprintf("Basic %s", encode(c.user, c.password))
+	resp, err := l.doRequest(ctx, "/v1/session/login", authHeader, req)
+	if err != nil {
+		return errors.Wrap(err, "failed to login")
+	}
+	l.setTokens(resp.Tokens)
+	return nil
+}
+
+func (l *sessionTokenLoader) refresh(ctx context.Context) error {
+	req := refreshRequest{SessionToken: l.tokens.SessionToken}
+	authHeader := fmt.Sprintf("Bearer %s", l.tokens.RefreshToken)
+	resp, err := l.doRequest(ctx, "/v1/session/refresh", authHeader, req)
+	if err != nil {
+		return errors.Wrap(err, "failed to refresh session token")
+	}
+	l.setTokens(resp.Tokens)
+	return nil
+}
+
+func (l *sessionTokenLoader) setTokens(tokens *sessionTokens) {
+	now := time.Now()
+	l.tokens = tokens
+	// refresh a little earlier to avoid using an expiring token on the fly
+	l.sessionExpiry = now.Add(time.Duration(tokens.SessionTokenTTLInSecs) * time.Second * 9 / 10)
+	l.refreshExpiry = now.Add(time.Duration(tokens.RefreshTokenTTLInSecs) * time.Second * 9 / 10)
+}
+
+func (l *sessionTokenLoader) loggedIn() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.tokens != nil
+}
+
+// drop forgets the tokens once the session is logged out.
+func (l *sessionTokenLoader) drop() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens = nil
+	l.sessionExpiry = time.Time{}
+	l.refreshExpiry = time.Time{}
+}
+
+func (l *sessionTokenLoader) doRequest(ctx context.Context, path, authHeader string, req interface{}) (*sessionTokenResponse, error) {
+	c := l.client
+	reqBody, err := json.Marshal(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal request body")
+	}
+	httpReq, err := http.NewRequest("POST", c.makeURL(path), bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create http request")
+	}
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	httpReq = httpReq.WithContext(ctx)
+	httpReq.Header = c.makeCommonHeaders(ctx)
+	httpReq.Header.Set(Authorization, authHeader)
+	httpReq.Header.Set(contentType, jsonContentType)
+	httpReq.Header.Set(accept, jsonContentType)
+	if len(c.host) > 0 {
+		httpReq.Host = c.host
+	}
+
+	httpResp, err := c.doHTTP(c.cli, httpReq)
+	if err != nil {
+		return nil, errors.Wrap(ErrDoRequest, err.Error())
+	}
+	defer httpResp.Body.Close()
+	httpRespBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, errors.Wrap(ErrReadResponse, err.Error())
+	}
+	if httpResp.StatusCode >= 400 {
+		return nil, newResponseError("session token request failed.", httpResp, httpRespBody)
+	}
+	resp := &sessionTokenResponse{}
+	if err = json.Unmarshal(httpRespBody, resp); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal response body")
+	}
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	if resp.Tokens == nil {
+		return nil, errors.New("no session tokens in response")
+	}
+	return resp, nil
+}
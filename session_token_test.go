@@ -0,0 +1,65 @@
+package godatabend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionTokenLogin(t *testing.T) {
+	var logins, refreshes int
+	validToken := ""
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get(Authorization)
+		switch r.URL.Path {
+		case "/v1/session/login":
+			user, password, _ := r.BasicAuth()
+			assert.Equal(t, "root", user)
+			assert.Equal(t, "pass", password)
+			logins++
+		case "/v1/session/refresh":
+			assert.Equal(t, "Bearer refresh", auth)
+			refreshes++
+		case "/v1/query":
+			if auth != "Bearer "+validToken {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(QueryResponse{ID: "q1"})
+			return
+		}
+		validToken = fmt.Sprintf("session%d", logins+refreshes)
+		_, _ = fmt.Fprintf(w, `{"tokens":{"session_token":"%s","refresh_token":"refresh","session_token_ttl_in_secs":3600,"refresh_token_ttl_in_secs":14400}}`, validToken)
+	}))
+	defer ts.Close()
+
+	cfg := NewConfig()
+	cfg.Host = strings.TrimPrefix(ts.URL, "http://")
+	cfg.SSLMode = SSL_MODE_DISABLE
+	cfg.User = "root"
+	cfg.Password = "pass"
+	cfg.SessionToken = true
+	c := NewAPIClientFromConfig(cfg)
+	assert.Equal(t, AuthMethodSessionToken, c.authMethod())
+
+	_, err := c.DoQuery(context.Background(), "SELECT 1", nil)
+	require.NoError(t, err)
+	_, err = c.DoQuery(context.Background(), "SELECT 1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, logins)
+	assert.Equal(t, 0, refreshes)
+
+	// the server rejects the session token, it should be refreshed
+	validToken = "expired"
+	_, err = c.DoQuery(context.Background(), "SELECT 1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, logins)
+	assert.Equal(t, 1, refreshes)
+}
@@ -0,0 +1,92 @@
+package godatabend
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type settingKind int
+
+const (
+	settingUint settingKind = iota
+	// settingBool is sent as 0 or 1 like the server does
+	settingBool
+	settingEnum
+)
+
+type settingSpec struct {
+	kind   settingKind
+	values []string // the values of settingEnum
+}
+
+// knownSettings are the session settings checked before they are sent, the other
+// settings are passed to the server untouched, like timezone, which is checked by
+// the time zones of the server, as the client may have no time zone database.
+// https://docs.databend.com/sql/sql-reference/settings
+var knownSettings = map[string]settingSpec{
+	"max_threads":                  {kind: settingUint},
+	"max_memory_usage":             {kind: settingUint},
+	"max_block_size":               {kind: settingUint},
+	"max_execute_time_in_seconds":  {kind: settingUint},
+	"max_storage_io_requests":      {kind: settingUint},
+	"max_inlist_to_or":             {kind: settingUint},
+	"parquet_max_block_size":       {kind: settingUint},
+	"input_read_buffer_size":       {kind: settingUint},
+	"storage_read_buffer_size":     {kind: settingUint},
+	"group_by_two_level_threshold": {kind: settingUint},
+	"lazy_read_threshold":          {kind: settingUint},
+	"query_result_cache_max_bytes": {kind: settingUint},
+	"query_result_cache_ttl_secs":  {kind: settingUint},
+	"join_spilling_memory_ratio":   {kind: settingUint},
+	"week_start":                   {kind: settingUint},
+
+	"enable_query_result_cache":         {kind: settingBool},
+	"enable_cbo":                        {kind: settingBool},
+	"enable_dphyp":                      {kind: settingBool},
+	"enable_bushy_join":                 {kind: settingBool},
+	"disable_join_reorder":              {kind: settingBool},
+	"enable_distributed_copy_into":      {kind: settingBool},
+	"enable_aggregating_index_scan":     {kind: settingBool},
+	"enable_table_lock":                 {kind: settingBool},
+	"enable_parquet_page_index":         {kind: settingBool},
+	"format_null_as_str":                {kind: settingBool},
+	"hide_options_in_show_create_table": {kind: settingBool},
+	"unquoted_ident_case_sensitive":     {kind: settingBool},
+	"quoted_ident_case_sensitive":       {kind: settingBool},
+
+	"sql_dialect":         {kind: settingEnum, values: []string{"PostgreSQL", "MySQL", "Hive", "PRQL", "Experimental"}},
+	"numeric_cast_option": {kind: settingEnum, values: []string{"rounding", "truncating"}},
+}
+
+// normalizeSetting checks the value of a known setting and returns it in the form
+// of the server, like 1 for true, the unknown settings are returned as is.
+func normalizeSetting(key, value string) (string, error) {
+	spec, ok := knownSettings[strings.ToLower(key)]
+	if !ok {
+		return value, nil
+	}
+	switch spec.kind {
+	case settingUint:
+		if _, err := strconv.ParseUint(value, 10, 64); err != nil {
+			return "", fmt.Errorf("expect an unsigned integer")
+		}
+	case settingBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("expect a boolean")
+		}
+		if b {
+			return "1", nil
+		}
+		return "0", nil
+	case settingEnum:
+		for _, v := range spec.values {
+			if strings.EqualFold(v, value) {
+				return v, nil
+			}
+		}
+		return "", fmt.Errorf("expect one of %s", strings.Join(spec.values, ", "))
+	}
+	return value, nil
+}
@@ -0,0 +1,207 @@
+package godatabend
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var stageFileTimeFormats = []string{
+	"2006-01-02 15:04:05.000 -0700",
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+	time.RFC3339Nano,
+}
+
+// StageFile describes a file listed in a stage.
+type StageFile struct {
+	Name         string
+	Size         uint64
+	MD5          string
+	LastModified time.Time
+}
+
+// ListStageFiles lists the files under the stage location, pattern is an optional
+// regular expression to filter the file names.
+func (c *APIClient) ListStageFiles(ctx context.Context, stage *StageLocation, pattern string) ([]StageFile, error) {
+	sql := fmt.Sprintf("LIST %s", stage)
+	if pattern != "" {
+		sql += fmt.Sprintf(" PATTERN = %s", quote(escape(pattern)))
+	}
+	resp, err := c.QuerySingle(ctx, sql, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list stage files")
+	}
+	files := make([]StageFile, 0, len(resp.Data))
+	for _, row := range resp.Data {
+		if len(row) < 4 {
+			return nil, errors.Errorf("list stage files invalid response: %+v", row)
+		}
+		file := StageFile{
+			Name: row[0],
+			MD5:  row[2],
+		}
+		if file.MD5 == "NULL" {
+			file.MD5 = ""
+		}
+		file.Size, err = strconv.ParseUint(row[1], 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid size of stage file %s", file.Name)
+		}
+		file.LastModified, err = parseStageFileTime(row[3])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid last_modified of stage file %s", file.Name)
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// RemoveStageFiles removes the files under the stage location, pattern is an optional
+// regular expression to filter the file names.
+func (c *APIClient) RemoveStageFiles(ctx context.Context, stage *StageLocation, pattern string) error {
+	sql := fmt.Sprintf("REMOVE %s", stage)
+	if pattern != "" {
+		sql += fmt.Sprintf(" PATTERN = %s", quote(escape(pattern)))
+	}
+	if _, err := c.QuerySingle(ctx, sql, nil); err != nil {
+		return errors.Wrap(err, "failed to remove stage files")
+	}
+	return nil
+}
+
+// NewFileFormatOptions returns the file format options for the given format
+// name, like csv, tsv, ndjson or parquet.
+func (c *APIClient) NewFileFormatOptions(format string) map[string]string {
+	if format == "" || strings.EqualFold(format, "csv") {
+		return c.NewDefaultCSVFormatOptions()
+	}
+	return map[string]string{
+		"type": strings.ToUpper(format),
+	}
+}
+
+// LoadFile uploads the local file to a temporary location in the user stage and
+// loads it into the table, the staged file is removed afterwards.
+func (c *APIClient) LoadFile(ctx context.Context, localPath, table, format string) (*QueryResponse, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "open file failed")
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, errors.Wrap(err, "get file size failed")
+	}
+
+	if format == "" {
+		format = c.fileFormat
+	}
+	stage := NewTempStageLocation(filepath.Base(localPath))
+	if err = c.UploadToStage(ctx, stage, bufio.NewReader(f), fi.Size()); err != nil {
+		return nil, errors.Wrap(err, "upload to stage failed")
+	}
+	defer func() {
+		if err := c.RemoveStageFiles(context.Background(), stage, ""); err != nil {
+			logger.WithContext(ctx).Warnf("remove staged file %s failed: %v", stage, err)
+		}
+	}()
+
+	sql := fmt.Sprintf("INSERT INTO %s VALUES", table)
+	resp, err := c.InsertWithStage(ctx, sql, stage, c.NewFileFormatOptions(format), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "insert with stage failed")
+	}
+	return resp, nil
+}
+
+// UnloadQuery unloads the query result into a temporary location in the user stage
+// with the given format, and downloads the produced files. The returned readers are
+// backed by local temporary files, which are removed on Close. The files are
+// downloaded by the presigned urls, so it fails if they are disabled.
+func (c *APIClient) UnloadQuery(ctx context.Context, query, format string) ([]io.ReadCloser, error) {
+	if c.PresignedURLDisabled {
+		return nil, errors.Wrap(ErrPresignDisabled, "failed to unload query")
+	}
+	if format == "" {
+		format = c.fileFormat
+	}
+	if format == "" {
+		format = "parquet"
+	}
+	stage := NewTempStageLocation("")
+	sql := fmt.Sprintf("COPY INTO %s FROM (%s) FILE_FORMAT = (TYPE = %s)", stage, query, strings.ToUpper(format))
+	if _, err := c.QuerySingle(ctx, sql, nil); err != nil {
+		return nil, errors.Wrap(err, "failed to unload query")
+	}
+	defer func() {
+		if err := c.RemoveStageFiles(context.Background(), stage, ""); err != nil {
+			logger.WithContext(ctx).Warnf("remove unloaded files %s failed: %v", stage, err)
+		}
+	}()
+
+	files, err := c.ListStageFiles(ctx, stage, "")
+	if err != nil {
+		return nil, err
+	}
+	readers := make([]io.ReadCloser, 0, len(files))
+	for _, file := range files {
+		r, err := c.downloadToTempFile(ctx, NewUserStageLocation(file.Name))
+		if err != nil {
+			for _, r := range readers {
+				_ = r.Close()
+			}
+			return nil, err
+		}
+		readers = append(readers, r)
+	}
+	return readers, nil
+}
+
+func (c *APIClient) downloadToTempFile(ctx context.Context, stage *StageLocation) (io.ReadCloser, error) {
+	f, err := os.CreateTemp("", "databend-unload-*")
+	if err != nil {
+		return nil, errors.Wrap(err, "create temp file failed")
+	}
+	r := &tempFileReader{f}
+	if err = c.DownloadFromStage(ctx, stage, f); err != nil {
+		_ = r.Close()
+		return nil, errors.Wrapf(err, "failed to download %s", stage)
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		_ = r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// tempFileReader removes the file on Close.
+type tempFileReader struct {
+	*os.File
+}
+
+func (r *tempFileReader) Close() error {
+	err := r.File.Close()
+	if rmErr := os.Remove(r.Name()); err == nil {
+		err = rmErr
+	}
+	return err
+}
+
+func parseStageFileTime(s string) (time.Time, error) {
+	var err error
+	for _, layout := range stageFileTimeFormats {
+		var t time.Time
+		if t, err = time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
@@ -0,0 +1,150 @@
+package godatabend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListStageFiles(t *testing.T) {
+	var gotSQL string
+	mockDoRequest := func(method, path string, req interface{}, resp interface{}) error {
+		gotSQL = req.(QueryRequest).SQL
+		result := QueryResponse{
+			Data: [][]string{
+				{"a/1.csv", "12", "\"9f1c\"", "2023-04-12 09:23:13.000 +0000", "NULL"},
+				{"a/2.csv", "0", "NULL", "2023-04-12 09:23:14.000 +0000", "NULL"},
+			},
+		}
+		buf, _ := json.Marshal(result)
+		return json.Unmarshal(buf, resp)
+	}
+	c := APIClient{
+		user:          "root",
+		doRequestFunc: mockDoRequest,
+	}
+	files, err := c.ListStageFiles(context.Background(), &StageLocation{Name: "s1", Path: "a"}, `.*\.csv`)
+	require.NoError(t, err)
+	assert.Equal(t, `LIST @s1/a PATTERN = '.*\\.csv'`, gotSQL)
+	require.Len(t, files, 2)
+	assert.Equal(t, "a/1.csv", files[0].Name)
+	assert.Equal(t, uint64(12), files[0].Size)
+	assert.Equal(t, "\"9f1c\"", files[0].MD5)
+	assert.Equal(t, time.Date(2023, 4, 12, 9, 23, 13, 0, time.UTC), files[0].LastModified.UTC())
+	assert.Equal(t, "", files[1].MD5)
+
+	err = c.RemoveStageFiles(context.Background(), &StageLocation{Name: "s1", Path: "a"}, "")
+	require.NoError(t, err)
+	assert.Equal(t, "REMOVE @s1/a", gotSQL)
+}
+
+func TestStageLocation(t *testing.T) {
+	sl, err := ParseStageLocation("@~/a/b.csv")
+	require.NoError(t, err)
+	assert.True(t, sl.IsUserStage())
+	assert.Equal(t, "a/b.csv", sl.Path)
+	assert.Equal(t, "@~/a/b.csv", sl.String())
+
+	sl, err = ParseStageLocation("@s1")
+	require.NoError(t, err)
+	assert.False(t, sl.IsUserStage())
+	assert.Equal(t, "@s1", sl.String())
+
+	_, err = ParseStageLocation("s1/a")
+	assert.Error(t, err)
+
+	tmp := NewTempStageLocation("data.csv")
+	assert.True(t, tmp.IsUserStage())
+	assert.True(t, strings.HasPrefix(tmp.String(), "@~/tmp/"))
+	assert.True(t, strings.HasSuffix(tmp.String(), "/data.csv"))
+}
+
+func TestLoadFile(t *testing.T) {
+	var uploaded string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/upload_to_stage", r.URL.Path)
+		assert.Equal(t, UserStageName, r.Header.Get("stage_name"))
+		f, _, err := r.FormFile("upload")
+		require.NoError(t, err)
+		buf, _ := io.ReadAll(f)
+		uploaded = string(buf)
+	}))
+	defer ts.Close()
+
+	var requests []QueryRequest
+	mockDoRequest := func(method, path string, req interface{}, resp interface{}) error {
+		requests = append(requests, req.(QueryRequest))
+		return nil
+	}
+	c := APIClient{
+		apiEndpoint:          ts.URL,
+		user:                 "root",
+		PresignedURLDisabled: true,
+		doRequestFunc:        mockDoRequest,
+	}
+
+	localPath := filepath.Join(t.TempDir(), "data.csv")
+	require.NoError(t, os.WriteFile(localPath, []byte("1,a\n"), 0644))
+	_, err := c.LoadFile(context.Background(), localPath, "t1", "csv")
+	require.NoError(t, err)
+	assert.Equal(t, "1,a\n", uploaded)
+
+	require.Len(t, requests, 2)
+	assert.Equal(t, "INSERT INTO t1 VALUES", requests[0].SQL)
+	assert.Equal(t, "CSV", requests[0].StageAttachment.FileFormatOptions["type"])
+	assert.True(t, strings.HasSuffix(requests[0].StageAttachment.Location, "/data.csv"))
+	assert.Equal(t, "REMOVE "+requests[0].StageAttachment.Location, requests[1].SQL)
+}
+
+func TestUnloadQuery(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.Path))
+	}))
+	defer ts.Close()
+
+	var sqls []string
+	c := APIClient{
+		user: "root",
+		doRequestFunc: func(method, path string, req interface{}, resp interface{}) error {
+			sql := req.(QueryRequest).SQL
+			sqls = append(sqls, sql)
+			var result QueryResponse
+			switch {
+			case strings.HasPrefix(sql, "LIST"):
+				result.Data = [][]string{
+					{"tmp/x/data_1.csv", "2", "NULL", "2023-04-12 09:23:13.000 +0000"},
+					{"tmp/x/data_2.csv", "2", "NULL", "2023-04-12 09:23:13.000 +0000"},
+				}
+			case strings.HasPrefix(sql, "PRESIGN DOWNLOAD @~/"):
+				result.Data = [][]string{{"GET", "{}", ts.URL + "/" + strings.TrimPrefix(sql, "PRESIGN DOWNLOAD @~/")}}
+			}
+			buf, _ := json.Marshal(result)
+			return json.Unmarshal(buf, resp)
+		},
+	}
+	readers, err := c.UnloadQuery(context.Background(), "SELECT 1", "csv")
+	require.NoError(t, err)
+	require.Len(t, readers, 2)
+	for i, r := range readers {
+		buf, err := io.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("/tmp/x/data_%d.csv", i+1), string(buf))
+		require.NoError(t, r.Close())
+		_, err = os.Stat(r.(*tempFileReader).Name())
+		assert.True(t, os.IsNotExist(err))
+	}
+	assert.True(t, strings.HasPrefix(sqls[0], "COPY INTO @~/tmp/"))
+	assert.True(t, strings.HasSuffix(sqls[0], " FROM (SELECT 1) FILE_FORMAT = (TYPE = CSV)"))
+	assert.True(t, strings.HasPrefix(sqls[len(sqls)-1], "REMOVE @~/tmp/"))
+}
@@ -21,7 +21,10 @@ type databendStmt struct {
 	batchMode bool
 	args      [][]driver.Value
 	query     string
-	batch     Batch
+	// parsed is the cached parse of query, the statements other than the batch
+	// inserts run with it
+	parsed *parsedQuery
+	batch  Batch
 }
 
 func (stmt *databendStmt) Close() error {
@@ -35,6 +38,9 @@ func (stmt *databendStmt) NumInput() int {
 }
 
 func (stmt *databendStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if stmt.batch == nil {
+		return stmt.runExec(context.Background(), args)
+	}
 	//1. trans args to csv file
 	err := stmt.batch.AppendToFile(args)
 	if err != nil {
@@ -50,17 +56,50 @@ func (stmt *databendStmt) Exec(args []driver.Value) (driver.Result, error) {
 	return driver.RowsAffected(0), nil
 }
 
-//func (stmt *databendStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
-//	values := make([]driver.Value, 0, len(args))
-//	for _, v := range args {
-//		values = append(values, v.Value)
-//	}
-//	return stmt.Exec(values)
-//}
+func (stmt *databendStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	values := make([]driver.Value, 0, len(args))
+	for _, v := range args {
+		values = append(values, v.Value)
+	}
+	if stmt.batch != nil {
+		return stmt.Exec(values)
+	}
+	return stmt.runExec(ctx, values)
+}
+
+func (stmt *databendStmt) runExec(ctx context.Context, args []driver.Value) (driver.Result, error) {
+	query, err := stmt.parsed.interpolate(args)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.dc.exec(ctx, query)
+}
 
 func (stmt *databendStmt) Query(args []driver.Value) (driver.Rows, error) {
 	logger.WithContext(stmt.dc.ctx).Infoln("Stmt.Query")
-	return nil, errors.New("only Exec method supported in batch mode")
+	if stmt.batch != nil {
+		return nil, errors.New("only Exec method supported in batch mode")
+	}
+	return stmt.runQuery(context.Background(), args)
+}
+
+func (stmt *databendStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	values := make([]driver.Value, 0, len(args))
+	for _, v := range args {
+		values = append(values, v.Value)
+	}
+	if stmt.batch != nil {
+		return nil, errors.New("only Exec method supported in batch mode")
+	}
+	return stmt.runQuery(ctx, values)
+}
+
+func (stmt *databendStmt) runQuery(ctx context.Context, args []driver.Value) (driver.Rows, error) {
+	query, err := stmt.parsed.interpolate(args)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.dc.query(ctx, query)
 }
 
 func (stmt *databendStmt) commit(ctx context.Context) error {
@@ -0,0 +1,90 @@
+package godatabend
+
+import (
+	"container/list"
+	"database/sql/driver"
+	"strings"
+)
+
+// defaultStmtCacheSize is the number of the parsed statements cached per connection.
+const defaultStmtCacheSize = 64
+
+// parsedQuery is the statement parsed once per connection for its prepares.
+type parsedQuery struct {
+	// query is trimmed of the spaces and the trailing semicolons
+	query string
+	// index is the positions of the placeholders in query
+	index []int
+	// batch is true for INSERT ... VALUES, which is prepared as a batch insert
+	batch bool
+}
+
+func parseQuery(query string) *parsedQuery {
+	q := strings.TrimRight(strings.TrimSpace(query), "; \t\r\n")
+	return &parsedQuery{
+		query: q,
+		index: placeholders(q),
+		batch: httpInsertRe.MatchString(q),
+	}
+}
+
+// interpolate replaces the placeholders with the args.
+func (p *parsedQuery) interpolate(args []driver.Value) (string, error) {
+	if len(args) == 0 {
+		return p.query, nil
+	}
+	return interpolateParams2(p.query, args, p.index)
+}
+
+// stmtCache is the LRU cache of the parsed statements keyed by the query text, it's
+// used by a single connection, so it's not locked.
+type stmtCache struct {
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type stmtCacheEntry struct {
+	key    string
+	parsed *parsedQuery
+}
+
+// newStmtCache creates the cache, size 0 is the default size, and the negative size
+// disables it.
+func newStmtCache(size int) *stmtCache {
+	if size == 0 {
+		size = defaultStmtCacheSize
+	}
+	return &stmtCache{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// get returns the parsed query, it's parsed on the cache misses.
+func (c *stmtCache) get(query string) *parsedQuery {
+	if c == nil || c.size < 0 {
+		return parseQuery(query)
+	}
+	if e, ok := c.items[query]; ok {
+		c.ll.MoveToFront(e)
+		return e.Value.(*stmtCacheEntry).parsed
+	}
+	parsed := parseQuery(query)
+	c.items[query] = c.ll.PushFront(&stmtCacheEntry{key: query, parsed: parsed})
+	if c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*stmtCacheEntry).key)
+	}
+	return parsed
+}
+
+// len returns the number of the cached statements.
+func (c *stmtCache) len() int {
+	if c == nil {
+		return 0
+	}
+	return c.ll.Len()
+}
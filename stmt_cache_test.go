@@ -0,0 +1,74 @@
+package godatabend
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStmtCache(t *testing.T) {
+	c := newStmtCache(2)
+	p := c.get("SELECT * FROM t WHERE a = ? AND b = '?';")
+	assert.Equal(t, "SELECT * FROM t WHERE a = ? AND b = '?'", p.query)
+	assert.Equal(t, []int{26}, p.index)
+	assert.False(t, p.batch)
+	assert.Same(t, p, c.get("SELECT * FROM t WHERE a = ? AND b = '?';"))
+
+	assert.True(t, c.get("INSERT INTO t VALUES").batch)
+	c.get("SELECT 1")
+	assert.Equal(t, 2, c.len())
+	// the least recently used one is evicted
+	assert.NotSame(t, p, c.get("SELECT * FROM t WHERE a = ? AND b = '?';"))
+
+	c = newStmtCache(-1)
+	c.get("SELECT 1")
+	assert.Equal(t, 0, c.len())
+}
+
+func TestPreparedQuery(t *testing.T) {
+	var sqls []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req QueryRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		sqls = append(sqls, req.SQL)
+		_, _ = w.Write([]byte(`{"id":"q1","state":"Succeeded","schema":[{"name":"a","type":"Int32"}],"data":[["1"]]}`))
+	}))
+	defer ts.Close()
+
+	db, err := sql.Open("databend", "databend://root:root@"+strings.TrimPrefix(ts.URL, "http://")+"/default?sslmode=disable&stmt_cache_size=8")
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	for i := 0; i < 2; i++ {
+		stmt, err := db.Prepare("SELECT a FROM t WHERE a = ? AND b = ?")
+		require.NoError(t, err)
+		var a int
+		require.NoError(t, stmt.QueryRow(i, "x").Scan(&a))
+		assert.Equal(t, 1, a)
+		_, err = stmt.Exec(i, nil)
+		require.NoError(t, err)
+		require.NoError(t, stmt.Close())
+	}
+	assert.Equal(t, []string{
+		"SELECT a FROM t WHERE a = 0 AND b = 'x'",
+		"SELECT a FROM t WHERE a = 0 AND b = NULL",
+		"SELECT a FROM t WHERE a = 1 AND b = 'x'",
+		"SELECT a FROM t WHERE a = 1 AND b = NULL",
+	}, sqls)
+
+	conn, err := db.Conn(context.Background())
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.Raw(func(driverConn interface{}) error {
+		assert.Equal(t, 1, driverConn.(*DatabendConn).stmtCache.len())
+		return nil
+	}))
+}
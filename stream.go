@@ -0,0 +1,98 @@
+package godatabend
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+)
+
+// QueryStream runs the query and calls fn with each row as the pages arrive, the
+// values are parsed by the types of the columns. If fn returns an error, the query
+// is killed and the error is returned. row is reused for the next row, so copy it
+// to keep it.
+func (c *APIClient) QueryStream(ctx context.Context, query string, args []driver.Value, fn func(row []driver.Value) error, opts ...QueryOption) error {
+	ctx = checkQueryID(withQueryOptions(ctx, opts))
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	respCh := make(chan QueryResponse)
+	errCh := make(chan error, 1)
+	go func() {
+		err := c.QuerySync(ctx, query, args, respCh)
+		close(respCh)
+		errCh <- err
+	}()
+
+	var parsers []DataParser
+	var row []driver.Value
+	var fnErr error
+	var killURI string
+	for resp := range respCh {
+		if fnErr != nil {
+			continue
+		}
+		if resp.KillURI != "" {
+			killURI = resp.KillURI
+		}
+		if parsers == nil && len(resp.Schema) > 0 {
+			if parsers, fnErr = c.streamParsers(resp.Schema); fnErr != nil {
+				c.abortStream(cancel, killURI)
+				continue
+			}
+			row = make([]driver.Value, len(parsers))
+		}
+		for i, data := range resp.Data {
+			if fnErr = parseRow(parsers, data, resp.rowNulls(i), row); fnErr == nil {
+				fnErr = fn(row)
+			}
+			if fnErr != nil {
+				c.abortStream(cancel, killURI)
+				break
+			}
+		}
+	}
+	err := <-errCh
+	if fnErr != nil {
+		return fnErr
+	}
+	return err
+}
+
+// abortStream kills the query on the server and stops reading its pages.
+func (c *APIClient) abortStream(cancel context.CancelFunc, killURI string) {
+	cancel()
+	if killURI != "" {
+		if err := c.KillQuery(context.Background(), killURI); err != nil {
+			c.log().Warn("failed to kill the query of the stream", "error", err)
+		}
+	}
+}
+
+func (c *APIClient) streamParsers(schema []DataField) ([]DataParser, error) {
+	parsers := make([]DataParser, len(schema))
+	for i, field := range schema {
+		desc, err := ParseTypeDesc(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse a description of the type '%s': %w", field.Type, err)
+		}
+		parsers[i], err = NewDataParser(desc, &DataParserOptions{TypedValues: c.typedValues})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create a data parser for the type '%s': %w", field.Type, err)
+		}
+	}
+	return parsers, nil
+}
+
+func parseRow(parsers []DataParser, data []string, nulls []bool, row []driver.Value) error {
+	if len(data) != len(parsers) {
+		return fmt.Errorf("%d values in a row of %d columns", len(data), len(parsers))
+	}
+	for i, cell := range data {
+		v, err := parseCell(parsers[i], cell, i < len(nulls) && nulls[i])
+		if err != nil {
+			return err
+		}
+		row[i] = v
+	}
+	return nil
+}
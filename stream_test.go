@@ -0,0 +1,80 @@
+package godatabend
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryStream(t *testing.T) {
+	var killed int32
+	pages := map[string]string{
+		"/v1/query": `{"id":"q1","state":"Running","schema":[{"name":"a","type":"Int64"},{"name":"b","type":"String"}],` +
+			`"data":[["1","x"],["2","y"]],"next_uri":"/v1/query/q1/page/1","kill_uri":"/v1/query/q1/kill"}`,
+		"/v1/query/q1/page/1": `{"id":"q1","state":"Running","data":[["3","z"]],"next_uri":"/v1/query/q1/page/2","kill_uri":"/v1/query/q1/kill"}`,
+		"/v1/query/q1/page/2": `{"id":"q1","state":"Succeeded","data":[]}`,
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/query/q1/kill" {
+			atomic.AddInt32(&killed, 1)
+			return
+		}
+		_, _ = w.Write([]byte(pages[r.URL.Path]))
+	}))
+	defer ts.Close()
+
+	cfg, err := ParseDSN("databend://root:root@" + strings.TrimPrefix(ts.URL, "http://") + "/default?sslmode=disable")
+	require.NoError(t, err)
+	c := NewAPIClientFromConfig(cfg)
+	ctx := context.Background()
+
+	var rows [][]driver.Value
+	err = c.QueryStream(ctx, "SELECT a, b FROM t", nil, func(row []driver.Value) error {
+		rows = append(rows, append([]driver.Value(nil), row...))
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, [][]driver.Value{{int64(1), "x"}, {int64(2), "y"}, {int64(3), "z"}}, rows)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&killed))
+
+	errStop := errors.New("stop")
+	n := 0
+	err = c.QueryStream(ctx, "SELECT a, b FROM t", nil, func(row []driver.Value) error {
+		n++
+		if n == 2 {
+			return errStop
+		}
+		return nil
+	})
+	assert.Equal(t, errStop, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&killed))
+}
+
+func TestQueryStreamTypedNulls(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"id":"q1","state":"Succeeded","schema":[{"name":"s","type":"Nullable(String)"}],` +
+			`"data":[[null],["NULL"],[""]]}`))
+	}))
+	defer ts.Close()
+
+	cfg, err := ParseDSN("databend://root:root@" + strings.TrimPrefix(ts.URL, "http://") + "/default?sslmode=disable&typed_values=true")
+	require.NoError(t, err)
+	c := NewAPIClientFromConfig(cfg)
+
+	var values []driver.Value
+	err = c.QueryStream(context.Background(), "SELECT s FROM t", nil, func(row []driver.Value) error {
+		values = append(values, row[0])
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []driver.Value{nil, "NULL", ""}, values)
+}
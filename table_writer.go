@@ -0,0 +1,104 @@
+package godatabend
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+const defaultTableWriterChunkSize = 64 * 1024 * 1024
+
+// TableWriter loads raw encoded rows into a table. The written bytes are split into
+// chunks on record boundaries and uploaded to the user stage, the staged chunks are
+// loaded into the table on Flush or Close.
+//
+// Only line delimited formats like CSV, TSV and NDJSON are supported, every record
+// must be terminated by a '\n'.
+type TableWriter struct {
+	ctx       context.Context
+	client    *APIClient
+	table     string
+	format    string
+	chunkSize int
+
+	buf    bytes.Buffer
+	stages []*StageLocation
+	closed bool
+}
+
+// NewTableWriter creates a TableWriter, chunkSize is the approximate size of each
+// staged file, the default value is used if it is not positive.
+func (c *APIClient) NewTableWriter(ctx context.Context, table, format string, chunkSize int) *TableWriter {
+	if chunkSize <= 0 {
+		chunkSize = defaultTableWriterChunkSize
+	}
+	return &TableWriter{
+		ctx:       ctx,
+		client:    c,
+		table:     table,
+		format:    format,
+		chunkSize: chunkSize,
+	}
+}
+
+// Write implements io.Writer.
+func (w *TableWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("write to closed table writer")
+	}
+	n, _ := w.buf.Write(p)
+	if w.buf.Len() < w.chunkSize {
+		return n, nil
+	}
+	// only upload the complete records, the rest is kept for the next chunk
+	end := bytes.LastIndexByte(w.buf.Bytes(), '\n')
+	if end < 0 {
+		return n, nil
+	}
+	if err := w.upload(w.buf.Bytes()[:end+1]); err != nil {
+		// the chunk is kept in the buffer to be uploaded by the next Write or Flush
+		return n, err
+	}
+	w.buf.Next(end + 1)
+	return n, nil
+}
+
+// Flush uploads the buffered records and loads all the staged chunks into the table.
+func (w *TableWriter) Flush() error {
+	if w.buf.Len() > 0 {
+		if err := w.upload(w.buf.Bytes()); err != nil {
+			return err
+		}
+		w.buf.Reset()
+	}
+	sql := fmt.Sprintf("INSERT INTO %s VALUES", w.table)
+	for len(w.stages) > 0 {
+		stage := w.stages[0]
+		if _, err := w.client.InsertWithStage(w.ctx, sql, stage, w.client.NewFileFormatOptions(w.format), nil); err != nil {
+			return errors.Wrapf(err, "failed to load %s", stage)
+		}
+		w.stages = w.stages[1:]
+	}
+	return nil
+}
+
+// Close flushes the writer, it can not be written anymore afterwards.
+func (w *TableWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.Flush()
+}
+
+func (w *TableWriter) upload(chunk []byte) error {
+	stage := NewTempStageLocation(fmt.Sprintf("%s-%d.%s", w.table, len(w.stages), w.format))
+	if err := w.client.UploadToStage(w.ctx, stage, bufio.NewReader(bytes.NewReader(chunk)), int64(len(chunk))); err != nil {
+		return errors.Wrap(err, "upload to stage failed")
+	}
+	w.stages = append(w.stages, stage)
+	return nil
+}
@@ -0,0 +1,94 @@
+package godatabend
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTableWriter(t *testing.T) {
+	var uploaded []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, _, err := r.FormFile("upload")
+		require.NoError(t, err)
+		buf, _ := io.ReadAll(f)
+		uploaded = append(uploaded, string(buf))
+	}))
+	defer ts.Close()
+
+	var requests []QueryRequest
+	c := APIClient{
+		apiEndpoint:          ts.URL,
+		user:                 "root",
+		PresignedURLDisabled: true,
+		doRequestFunc: func(method, path string, req interface{}, resp interface{}) error {
+			requests = append(requests, req.(QueryRequest))
+			return nil
+		},
+	}
+
+	w := c.NewTableWriter(context.Background(), "t1", "ndjson", 8)
+	_, err := w.Write([]byte(`{"a":1}` + "\n" + `{"a"`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{`{"a":1}` + "\n"}, uploaded)
+	assert.Empty(t, requests)
+
+	_, err = w.Write([]byte(`:2}` + "\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	assert.Equal(t, []string{`{"a":1}` + "\n", `{"a":2}` + "\n"}, uploaded)
+	require.Len(t, requests, 2)
+	assert.Equal(t, "INSERT INTO t1 VALUES", requests[0].SQL)
+	assert.Equal(t, "NDJSON", requests[0].StageAttachment.FileFormatOptions["type"])
+
+	_, err = w.Write([]byte("x\n"))
+	assert.Error(t, err)
+}
+
+func TestTableWriterRetryUpload(t *testing.T) {
+	var uploaded []string
+	fail := true
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			fail = false
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f, _, err := r.FormFile("upload")
+		require.NoError(t, err)
+		buf, _ := io.ReadAll(f)
+		uploaded = append(uploaded, string(buf))
+	}))
+	defer ts.Close()
+
+	c := APIClient{
+		apiEndpoint:          ts.URL,
+		user:                 "root",
+		PresignedURLDisabled: true,
+		doRequestFunc: func(method, path string, req interface{}, resp interface{}) error {
+			return nil
+		},
+	}
+
+	// the rows of the failed upload are uploaded by the next Write
+	w := c.NewTableWriter(context.Background(), "t1", "csv", 4)
+	_, err := w.Write([]byte("1,a\n"))
+	assert.Error(t, err)
+	assert.Empty(t, uploaded)
+	_, err = w.Write([]byte("2,b\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1,a\n2,b\n"}, uploaded)
+
+	// and by Flush
+	fail = true
+	_, err = w.Write([]byte("3,c"))
+	require.NoError(t, err)
+	assert.Error(t, w.Flush())
+	require.NoError(t, w.Close())
+	assert.Equal(t, []string{"1,a\n2,b\n", "3,c"}, uploaded)
+}
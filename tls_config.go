@@ -2,7 +2,11 @@ package godatabend
 
 import (
 	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
 	"sync"
+
+	"github.com/pkg/errors"
 )
 
 // Based on the original implementation in the project go-sql-driver/mysql:
@@ -42,3 +46,71 @@ func getTLSConfigClone(key string) (config *tls.Config) {
 	tlsConfigLock.RUnlock()
 	return
 }
+
+// buildTLSConfig returns the tls.Config for the http client.
+func (cfg *Config) buildTLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+	if cfg.TLSClientConfig != nil {
+		tlsConfig = cfg.TLSClientConfig.Clone()
+	} else if cfg.TLSConfig != "" {
+		if tlsConfig = getTLSConfigClone(cfg.TLSConfig); tlsConfig == nil {
+			return nil, errors.Errorf("invalid value / unknown config name: %s", cfg.TLSConfig)
+		}
+	}
+	if cfg.TLSCAFile != "" {
+		buf, err := ioutil.ReadFile(cfg.TLSCAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read CA file")
+		}
+		rootCAs := x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(buf) {
+			return nil, errors.Errorf("no valid certificates found in CA file %s", cfg.TLSCAFile)
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+	switch cfg.SSLMode {
+	case SSL_MODE_SKIP_VERIFY:
+		tlsConfig.InsecureSkipVerify = true
+	case SSL_MODE_VERIFY_CA:
+		// skip the default verification which checks the host name as well
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyPeerCertificate = verifyCertificateChain(tlsConfig.RootCAs)
+	}
+	if cfg.TLSClientCert != nil {
+		tlsConfig.Certificates = append(tlsConfig.Certificates, *cfg.TLSClientCert)
+	} else if cfg.TLSClientCertFile != "" || cfg.TLSClientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSClientCertFile, cfg.TLSClientKeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load client certificate")
+		}
+		tlsConfig.Certificates = append(tlsConfig.Certificates, cert)
+	}
+	return tlsConfig, nil
+}
+
+// verifyCertificateChain verifies the server certificate chain against the roots, the
+// system roots are used if it's nil.
+func verifyCertificateChain(roots *x509.CertPool) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("no server certificate")
+		}
+		certs := make([]*x509.Certificate, len(rawCerts))
+		for i, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return errors.Wrap(err, "failed to parse server certificate")
+			}
+			certs[i] = cert
+		}
+		opts := x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: x509.NewCertPool(),
+		}
+		for _, cert := range certs[1:] {
+			opts.Intermediates.AddCert(cert)
+		}
+		_, err := certs[0].Verify(opts)
+		return err
+	}
+}
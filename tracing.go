@@ -0,0 +1,148 @@
+package godatabend
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/datafuselabs/databend-go"
+
+// noopSpan is used when OpenTelemetry is not enabled.
+var noopSpan = trace.SpanFromContext(context.Background())
+
+func (cfg *Config) tracerProvider() trace.TracerProvider {
+	if cfg.TracerProvider != nil {
+		return cfg.TracerProvider
+	}
+	return otel.GetTracerProvider()
+}
+
+// newTracer returns the tracer of the driver level spans, it's nil if
+// OpenTelemetry is not enabled.
+func newTracer(cfg *Config) trace.Tracer {
+	if !cfg.EnableOpenTelemetry {
+		return nil
+	}
+	return cfg.tracerProvider().Tracer(tracerName, trace.WithInstrumentationVersion(version))
+}
+
+// traceTransport traces every http request sent by the transport.
+func traceTransport(cfg *Config, transport http.RoundTripper) http.RoundTripper {
+	if !cfg.EnableOpenTelemetry {
+		return transport
+	}
+	return otelhttp.NewTransport(transport, otelhttp.WithTracerProvider(cfg.tracerProvider()))
+}
+
+// startQuerySpan starts the span of a logical query, the returned context carries it
+// to the page requests.
+func (c *APIClient) startQuerySpan(ctx context.Context, query string) (context.Context, trace.Span) {
+	if c.tracer == nil {
+		return ctx, noopSpan
+	}
+	statement := query
+	if c.traceRedactSQL {
+		statement = redactSQL(query)
+	}
+	ctx, span := c.tracer.Start(ctx, "databend.query",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "databend"),
+			attribute.String("db.user", c.user),
+			attribute.String("db.name", c.database),
+			attribute.String("db.statement", statement),
+		),
+	)
+	if queryID := queryIDOf(ctx); queryID != "" {
+		span.SetAttributes(attribute.String("databend.query_id", queryID))
+	}
+	return ctx, span
+}
+
+// setStartedAttributes records the response of the query request.
+func setStartedAttributes(span trace.Span, resp *QueryResponse) {
+	if resp.ID != "" {
+		span.SetAttributes(attribute.String("databend.query_id", resp.ID))
+	}
+	if resp.NodeID != "" {
+		span.SetAttributes(attribute.String("databend.node_id", resp.NodeID))
+	}
+}
+
+// endQuerySpan ends the span with the stats of the last response and the error of
+// the query.
+func endQuerySpan(span trace.Span, resp *QueryResponse, err error) {
+	if resp != nil {
+		stats := resp.Stats
+		span.SetAttributes(
+			attribute.Int64("databend.result.rows", int64(stats.ResultProgress.Rows)),
+			attribute.Int64("databend.result.bytes", int64(stats.ResultProgress.Bytes)),
+			attribute.Int64("databend.scan.rows", int64(stats.ScanProgress.Rows)),
+			attribute.Int64("databend.scan.bytes", int64(stats.ScanProgress.Bytes)),
+			attribute.Int64("databend.write.rows", int64(stats.WriteProgress.Rows)),
+			attribute.Int64("databend.write.bytes", int64(stats.WriteProgress.Bytes)),
+			attribute.Float64("databend.running_time_ms", stats.RunningTimeMS),
+		)
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// startPageSpan starts the span of a page request as a child of the query span.
+func (c *APIClient) startPageSpan(ctx context.Context, nextURI string) (context.Context, trace.Span) {
+	if c.tracer == nil {
+		return ctx, noopSpan
+	}
+	return c.tracer.Start(ctx, "databend.page",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("databend.next_uri", nextURI)),
+	)
+}
+
+// redactSQL replaces the string and number literals of the query with '?'.
+func redactSQL(query string) string {
+	var b strings.Builder
+	runes := []rune(query)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\'':
+			for i++; i < len(runes); i++ {
+				if runes[i] == '\\' {
+					i++
+				} else if runes[i] == '\'' {
+					// '' is an escaped quote
+					if i+1 < len(runes) && runes[i+1] == '\'' {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			b.WriteRune('?')
+		case unicode.IsDigit(r) && (i == 0 || !isIdentRune(runes[i-1])):
+			for i+1 < len(runes) && (unicode.IsDigit(runes[i+1]) || runes[i+1] == '.') {
+				i++
+			}
+			b.WriteRune('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,64 @@
+package godatabend
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestQuerySpans(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/query" {
+			_, _ = w.Write([]byte(`{"id":"q1","node_id":"n1","data":[["1"]],"next_uri":"/v1/query/q1/page/1"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"q1","data":[["2"]],"stats":{"result_progress":{"rows":2,"bytes":16}}}`))
+	}))
+	defer ts.Close()
+
+	recorder := tracetest.NewSpanRecorder()
+	cfg := NewConfig()
+	cfg.Host = strings.TrimPrefix(ts.URL, "http://")
+	cfg.SSLMode = SSL_MODE_DISABLE
+	cfg.User = "root"
+	cfg.EnableOpenTelemetry = true
+	cfg.TraceRedactSQL = true
+	cfg.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	c, err := NewAPIClient(cfg)
+	require.NoError(t, err)
+
+	err = c.QuerySync(context.Background(), "SELECT * FROM t WHERE a = 'x' AND b > 10", nil, make(chan QueryResponse, 2))
+	require.NoError(t, err)
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	query, page := spans["databend.query"], spans["databend.page"]
+	require.NotNil(t, query)
+	require.NotNil(t, page)
+	assert.Equal(t, query.SpanContext().SpanID(), page.Parent().SpanID())
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range query.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	assert.Equal(t, "SELECT * FROM t WHERE a = ? AND b > ?", attrs["db.statement"].AsString())
+	assert.Equal(t, "q1", attrs["databend.query_id"].AsString())
+	assert.Equal(t, "n1", attrs["databend.node_id"].AsString())
+	assert.Equal(t, int64(2), attrs["databend.result.rows"].AsInt64())
+	// the http requests are traced too
+	assert.Len(t, recorder.Ended(), 4)
+}
+
+func TestRedactSQL(t *testing.T) {
+	assert.Equal(t, "INSERT INTO t1 VALUES (?, ?, ?)", redactSQL(`INSERT INTO t1 VALUES (1, 'a''b', 'c\'d')`))
+	assert.Equal(t, "SELECT ? FROM t2", redactSQL("SELECT 3.14 FROM t2"))
+}
@@ -0,0 +1,157 @@
+package godatabend
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultMaxIdleConnsPerHost = 10
+	defaultUploadTimeout       = 60 * time.Second
+	defaultDialTimeout         = 30 * time.Second
+	defaultPingTimeout         = 5 * time.Second
+	// pageTimeoutMargin is added to WaitTimeSecs for the default page timeout.
+	pageTimeoutMargin = 5 * time.Second
+)
+
+func newHTTPClient(cfg *Config) (*http.Client, error) {
+	transport, err := newTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	// the timeouts are applied to each request by the APIClient, so the page
+	// requests could long-poll for longer
+	return &http.Client{
+		Transport: traceTransport(cfg, transport),
+	}, nil
+}
+
+// pageTimeout returns the timeout of the page requests.
+func pageTimeout(cfg *Config) time.Duration {
+	if cfg.PageTimeout != 0 || cfg.Timeout == 0 {
+		return cfg.PageTimeout
+	}
+	timeout := cfg.Timeout
+	if wait := time.Duration(cfg.WaitTimeSecs)*time.Second + pageTimeoutMargin; timeout < wait {
+		timeout = wait
+	}
+	return timeout
+}
+
+// newUploadHTTPClient builds the client of the stage uploads and downloads, it reuses
+// the transport of cli but with its own timeout, since the files could be large.
+func newUploadHTTPClient(cfg *Config, cli *http.Client) *http.Client {
+	if cfg.UploadHTTPClient != nil {
+		return cfg.UploadHTTPClient
+	}
+	timeout := cfg.UploadTimeout
+	if timeout == 0 {
+		timeout = defaultUploadTimeout
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: cli.Transport,
+	}
+}
+
+func newTransport(cfg *Config) (http.RoundTripper, error) {
+	if cfg.Transport != nil {
+		return cfg.Transport, nil
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	tlsConfig, err := cfg.buildTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	if cfg.ProxyURL != "" {
+		proxyURL, err := url.Parse(cfg.ProxyURL)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid proxy url")
+		}
+		switch proxyURL.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, errors.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if cfg.DialContext != nil {
+		transport.DialContext = cfg.DialContext
+	} else if cfg.Resolver != nil || cfg.DialTimeout > 0 {
+		dialTimeout := defaultDialTimeout
+		if cfg.DialTimeout > 0 {
+			dialTimeout = cfg.DialTimeout
+		}
+		transport.DialContext = (&net.Dialer{
+			Timeout:   dialTimeout,
+			KeepAlive: 30 * time.Second,
+			Resolver:  cfg.Resolver,
+		}).DialContext
+	}
+	if cfg.ResponseHeaderTimeout > 0 {
+		transport.ResponseHeaderTimeout = cfg.ResponseHeaderTimeout
+	}
+
+	transport.MaxIdleConnsPerHost = defaultMaxIdleConnsPerHost
+	if cfg.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	}
+	if cfg.MaxIdleConns > 0 {
+		transport.MaxIdleConns = cfg.MaxIdleConns
+	}
+	if cfg.MaxConnsPerHost > 0 {
+		transport.MaxConnsPerHost = cfg.MaxConnsPerHost
+	}
+	if cfg.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = cfg.IdleConnTimeout
+	}
+	if cfg.TLSHandshakeTimeout > 0 {
+		transport.TLSHandshakeTimeout = cfg.TLSHandshakeTimeout
+	}
+	if cfg.DisableHTTP2 {
+		// a non-nil empty TLSNextProto map disables HTTP/2
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return transport, nil
+}
+
+// failedRoundTripper fails all the requests with the error to build the transport.
+type failedRoundTripper struct {
+	err error
+}
+
+func (t failedRoundTripper) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, t.err
+}
+
+// Interceptor is called around every http request sent by the APIClient, including
+// the query, page, session and upload requests. It can modify the request, inspect
+// the response, or return without calling next.
+type Interceptor func(req *http.Request, next func(*http.Request) (*http.Response, error)) (*http.Response, error)
+
+// doHTTP sends the request with cli through the interceptors, the first interceptor
+// is the outermost one.
+func (c *APIClient) doHTTP(cli *http.Client, req *http.Request) (*http.Response, error) {
+	if c.debugHTTP {
+		return c.dumpHTTP(cli, req)
+	}
+	return c.intercept(0, cli, req)
+}
+
+func (c *APIClient) intercept(i int, cli *http.Client, req *http.Request) (*http.Response, error) {
+	if i == len(c.interceptors) {
+		return cli.Do(req)
+	}
+	return c.interceptors[i](req, func(req *http.Request) (*http.Response, error) {
+		return c.intercept(i+1, cli, req)
+	})
+}
@@ -0,0 +1,186 @@
+package godatabend
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrTransactionAborted is returned without sending the query when the transaction
+// has been aborted on the server, it must be rolled back before the next query.
+var ErrTransactionAborted = errors.New("transaction is aborted, please rollback")
+
+// ErrReadOnlyTxn is returned without sending the query when a read-only transaction
+// runs a statement which writes.
+var ErrReadOnlyTxn = errors.New("databend: write in a read-only transaction")
+
+// ErrTxnNeedsRestart is matched by the errors of the statements which aborted the
+// transaction or found it aborted or timed out, the transaction should be rolled
+// back and run again from the start rather than retrying the statement.
+var ErrTxnNeedsRestart = errors.New("databend: transaction needs restart")
+
+// TxnNeedsRestartError is the error of a statement in an aborted transaction.
+type TxnNeedsRestartError struct {
+	Err error
+}
+
+func (e *TxnNeedsRestartError) Error() string {
+	return "transaction needs restart: " + e.Err.Error()
+}
+
+func (e *TxnNeedsRestartError) Unwrap() error {
+	return e.Err
+}
+
+func (e *TxnNeedsRestartError) Is(target error) bool {
+	return target == ErrTxnNeedsRestart
+}
+
+// isTxnTimeout tells whether the query failed since the transaction timed out.
+func isTxnTimeout(err *QueryError) bool {
+	return err.Kind == "TransactionTimeout" || strings.Contains(strings.ToLower(err.Message), "transaction timeout")
+}
+
+type databendTx struct {
+	dc *DatabendConn
+}
+
+// BeginTx starts a transaction on the server, the queries of the transaction stay
+// on the node which runs it. The transactions are snapshot isolated, so the levels
+// up to sql.LevelSnapshot are accepted. The writes of a read-only transaction fail
+// with ErrReadOnlyTxn before they are sent.
+func (dc *DatabendConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	switch sql.IsolationLevel(opts.Isolation) {
+	case sql.LevelDefault, sql.LevelReadUncommitted, sql.LevelReadCommitted, sql.LevelWriteCommitted,
+		sql.LevelRepeatableRead, sql.LevelSnapshot:
+	default:
+		return nil, fmt.Errorf("databend: isolation level %s is not supported, the transactions are snapshot isolated",
+			sql.IsolationLevel(opts.Isolation))
+	}
+	if dc.rest == nil {
+		return nil, driver.ErrBadConn
+	}
+	if _, err := dc.exec(ctx, "BEGIN"); err != nil {
+		return nil, err
+	}
+	dc.readOnlyTxn = opts.ReadOnly
+	return &databendTx{dc: dc}, nil
+}
+
+// checkReadOnly rejects the writes in a read-only transaction.
+func (dc *DatabendConn) checkReadOnly(query string) error {
+	if dc.readOnlyTxn && isModifyingQuery(query) {
+		return ErrReadOnlyTxn
+	}
+	return nil
+}
+
+// isModifyingQuery tells whether the statement changes the data or the schema, the
+// statement of WITH is the one after the common table expressions.
+func isModifyingQuery(query string) bool {
+	tokens := topLevelTokens(query)
+	if len(tokens) == 0 {
+		return false
+	}
+	keyword := tokens[0]
+	if keyword == "WITH" {
+		keyword = ""
+		for i := 1; i+1 < len(tokens); i++ {
+			// WITH [RECURSIVE] name [(columns)] AS [MATERIALIZED] (query) [, ...] statement
+			if tokens[i] == "()" && (tokens[i-1] == "AS" || tokens[i-1] == "MATERIALIZED") && tokens[i+1] != "," {
+				keyword = tokens[i+1]
+				break
+			}
+		}
+	}
+	switch keyword {
+	case "INSERT", "REPLACE", "COPY", "UPDATE", "DELETE", "MERGE", "CREATE", "DROP", "ALTER",
+		"TRUNCATE", "OPTIMIZE", "VACUUM", "UNDROP", "RENAME", "GRANT", "REVOKE":
+		return true
+	}
+	return false
+}
+
+// topLevelTokens splits the query outside of the parentheses into the upper case
+// words, the literals as "?", the parenthesized parts as "()" and the commas, the
+// comments are skipped.
+func topLevelTokens(query string) []string {
+	var tokens []string
+	runes := []rune(query)
+	depth := 0
+	add := func(token string) {
+		if depth == 0 {
+			tokens = append(tokens, token)
+		}
+	}
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			for i += 3; i < len(runes) && !(runes[i-1] == '*' && runes[i] == '/'); i++ {
+			}
+		case r == '\'' || r == '"' || r == '`':
+			for i++; i < len(runes) && runes[i] != r; i++ {
+				if runes[i] == '\\' {
+					i++
+				}
+			}
+			add("?")
+		case r == '(':
+			add("()")
+			depth++
+		case r == ')':
+			if depth > 0 {
+				depth--
+			}
+		case r == ',':
+			add(",")
+		case isIdentRune(r):
+			start := i
+			for i+1 < len(runes) && isIdentRune(runes[i+1]) {
+				i++
+			}
+			add(strings.ToUpper(string(runes[start : i+1])))
+		}
+	}
+	return tokens
+}
+
+// Commit applies the prepared batch inserts and commits the transaction.
+func (tx *databendTx) Commit() error {
+	tx.dc.readOnlyTxn = false
+	if err := tx.dc.Commit(); err != nil {
+		_, _ = tx.dc.exec(tx.dc.ctx, "ROLLBACK")
+		return err
+	}
+	_, err := tx.dc.exec(tx.dc.ctx, "COMMIT")
+	return err
+}
+
+// Rollback drops the prepared batch inserts and rolls back the transaction.
+func (tx *databendTx) Rollback() error {
+	tx.dc.readOnlyTxn = false
+	tx.dc.commit = nil
+	_, err := tx.dc.exec(tx.dc.ctx, "ROLLBACK")
+	return err
+}
+
+func isTxnEnd(sql string) bool {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToUpper(strings.TrimSuffix(fields[0], ";")) {
+	case "COMMIT", "ROLLBACK", "ABORT":
+		return true
+	}
+	return false
+}
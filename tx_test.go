@@ -0,0 +1,250 @@
+package godatabend
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransaction(t *testing.T) {
+	var sessions []map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			SQL     string                 `json:"sql"`
+			Session map[string]interface{} `json:"session"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		sessions = append(sessions, req.Session)
+		switch req.SQL {
+		case "BEGIN":
+			_, _ = w.Write([]byte(`{"session":{"txn_state":"Active","need_sticky":true,"internal":"i1"}}`))
+		case "INSERT INTO t VALUES (1)":
+			_, _ = w.Write([]byte(`{"session":{"txn_state":"Fail","need_sticky":true,"internal":"i1"},"error":{"code":1,"message":"failed"}}`))
+		default:
+			_, _ = w.Write([]byte(`{"session":{"txn_state":"AutoCommit"}}`))
+		}
+	}))
+	defer ts.Close()
+
+	cfg, err := ParseDSN("databend://root:root@" + strings.TrimPrefix(ts.URL, "http://") + "/default?sslmode=disable")
+	require.NoError(t, err)
+	dc, err := buildDatabendConn(context.Background(), *cfg)
+	require.NoError(t, err)
+
+	tx, err := dc.Begin()
+	require.NoError(t, err)
+	assert.True(t, dc.rest.isSticky())
+
+	_, err = dc.exec(context.Background(), "INSERT INTO t VALUES (1)")
+	assert.True(t, errors.Is(err, ErrTxnNeedsRestart))
+	assert.Equal(t, "Active", sessions[1]["txn_state"])
+	assert.Equal(t, "i1", sessions[1]["internal"])
+
+	_, err = dc.exec(context.Background(), "SELECT 1")
+	assert.True(t, errors.Is(err, ErrTransactionAborted))
+	assert.True(t, errors.Is(err, ErrTxnNeedsRestart))
+	assert.Len(t, sessions, 2)
+
+	require.NoError(t, tx.Rollback())
+	assert.Equal(t, "Fail", sessions[2]["txn_state"])
+	assert.Equal(t, TxnStateAutoCommit, dc.rest.txnState)
+	assert.False(t, dc.rest.isSticky())
+
+	_, err = dc.BeginTx(context.Background(), driver.TxOptions{Isolation: driver.IsolationLevel(sql.LevelSerializable)})
+	assert.EqualError(t, err, "databend: isolation level Serializable is not supported, the transactions are snapshot isolated")
+
+	tx, err = dc.BeginTx(context.Background(), driver.TxOptions{ReadOnly: true, Isolation: driver.IsolationLevel(sql.LevelSnapshot)})
+	require.NoError(t, err)
+	_, err = dc.exec(context.Background(), "SELECT 1")
+	assert.NoError(t, err)
+	_, err = dc.exec(context.Background(), "delete from t")
+	assert.Equal(t, ErrReadOnlyTxn, err)
+	_, err = dc.PrepareContext(context.Background(), "INSERT INTO t VALUES")
+	assert.Equal(t, ErrReadOnlyTxn, err)
+	require.NoError(t, tx.Commit())
+	_, err = dc.exec(context.Background(), "DELETE FROM t")
+	assert.NoError(t, err)
+}
+
+func TestIsModifyingQuery(t *testing.T) {
+	for query, modifying := range map[string]bool{
+		"SELECT 1":                                           false,
+		"  delete from t":                                    true,
+		"(SELECT 1) UNION (SELECT 2)":                        false,
+		"-- comment\nINSERT INTO t VALUES (1)":               true,
+		"/* INSERT */ SELECT 1/**/":                          false,
+		"/* hint */\n\tUPDATE t SET a = 1":                   true,
+		"WITH s AS (SELECT 1) SELECT * FROM s":               false,
+		"WITH s AS (SELECT 1) INSERT INTO t SELECT * FROM s": true,
+		"with recursive s(a) as (select 1), u as materialized (select 'x') delete from t": true,
+		"WITH s AS (SELECT 1) SELECT replace(a, 'x', 'y') FROM s":                         false,
+		"SELECT 'DROP TABLE t'": false,
+		"":                      false,
+	} {
+		assert.Equal(t, modifying, isModifyingQuery(query), query)
+	}
+}
+
+func TestStickyNode(t *testing.T) {
+	var nodes []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nodes = append(nodes, r.Header.Get(DatabendStickyNodeHeader))
+		if r.URL.Path == "/v1/query" {
+			_, _ = w.Write([]byte(`{"id":"q1","node_id":"n1","next_uri":"/v1/query/q1/page/1","session":{"need_sticky":true}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"q1","node_id":"n1","session":{"need_sticky":true}}`))
+	}))
+	defer ts.Close()
+
+	c := APIClient{
+		cli:         &http.Client{},
+		apiEndpoint: ts.URL,
+		user:        "root",
+	}
+	for i := 0; i < 2; i++ {
+		resp, err := c.DoQuery(context.Background(), "SELECT 1", nil)
+		require.NoError(t, err)
+		_, err = c.QueryPage(context.Background(), resp.NextURI)
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{"", "n1", "n1", "n1"}, nodes)
+}
+
+func TestSessionKeepAlive(t *testing.T) {
+	heartbeats := make(chan map[string]interface{}, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == sessionHeartbeatPath {
+			var req map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			heartbeats <- req
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"q1","session":{"need_keep_alive":true,"internal":"i1"}}`))
+	}))
+	defer ts.Close()
+
+	cfg, err := ParseDSN("databend://root:root@" + strings.TrimPrefix(ts.URL, "http://") + "/default?sslmode=disable&session_keep_alive_interval=20ms")
+	require.NoError(t, err)
+	dc, err := buildDatabendConn(context.Background(), *cfg)
+	require.NoError(t, err)
+
+	// no heartbeats until the server asks to
+	time.Sleep(60 * time.Millisecond)
+	assert.Len(t, heartbeats, 0)
+
+	_, err = dc.exec(context.Background(), "CREATE TEMP TABLE t(a int)")
+	require.NoError(t, err)
+	select {
+	case req := <-heartbeats:
+		assert.Equal(t, "i1", req["session"].(map[string]interface{})["internal"])
+	case <-time.After(time.Second):
+		t.Fatal("no heartbeat")
+	}
+	require.NoError(t, dc.Close())
+}
+
+func TestLogoutOnClose(t *testing.T) {
+	var logouts int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == sessionLogoutPath {
+			atomic.AddInt32(&logouts, 1)
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"q1","session":{"need_keep_alive":true}}`))
+	}))
+	defer ts.Close()
+
+	cfg, err := ParseDSN("databend://root:root@" + strings.TrimPrefix(ts.URL, "http://") + "/default?sslmode=disable")
+	require.NoError(t, err)
+
+	// nothing to release on the server
+	dc, err := buildDatabendConn(context.Background(), *cfg)
+	require.NoError(t, err)
+	require.NoError(t, dc.Close())
+	assert.Equal(t, int32(0), atomic.LoadInt32(&logouts))
+
+	dc, err = buildDatabendConn(context.Background(), *cfg)
+	require.NoError(t, err)
+	_, err = dc.exec(context.Background(), "CREATE TEMP TABLE t(a int)")
+	require.NoError(t, err)
+	require.NoError(t, dc.Close())
+	require.NoError(t, dc.Close())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&logouts))
+}
+
+func TestResetSession(t *testing.T) {
+	var queries []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req QueryRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		queries = append(queries, req.SQL)
+		switch req.SQL {
+		case "ROLLBACK":
+			_, _ = w.Write([]byte(`{"id":"q2","session":{"txn_state":"AutoCommit"}}`))
+		default:
+			_, _ = w.Write([]byte(`{"id":"q1","session":{"txn_state":"Fail","settings":{"max_threads":"1"}}}`))
+		}
+	}))
+	defer ts.Close()
+
+	cfg, err := ParseDSN("databend://root:root@" + strings.TrimPrefix(ts.URL, "http://") + "/default?sslmode=disable&timezone=UTC")
+	require.NoError(t, err)
+	dc, err := buildDatabendConn(context.Background(), *cfg)
+	require.NoError(t, err)
+
+	_, err = dc.exec(context.Background(), "SET max_threads = 1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"max_threads": "1"}, dc.rest.getSessionState().Settings)
+
+	require.NoError(t, dc.ResetSession(context.Background()))
+	assert.Equal(t, []string{"SET max_threads = 1", "ROLLBACK"}, queries)
+	assert.Equal(t, map[string]string{"timezone": "UTC"}, dc.rest.getSessionState().Settings)
+
+	// nothing to roll back
+	require.NoError(t, dc.ResetSession(context.Background()))
+	assert.Len(t, queries, 2)
+
+	require.NoError(t, dc.Close())
+	assert.Equal(t, driver.ErrBadConn, dc.ResetSession(context.Background()))
+}
+
+func TestResetSessionIdentity(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"id":"q1","session":{"database":"db2","role":"admin","settings":{"max_threads":"1"}}}`))
+	}))
+	defer ts.Close()
+
+	cfg, err := ParseDSN("databend://root:root@" + strings.TrimPrefix(ts.URL, "http://") + "/default?sslmode=disable&role=reader&warehouse=wh0")
+	require.NoError(t, err)
+	dc, err := buildDatabendConn(context.Background(), *cfg)
+	require.NoError(t, err)
+
+	require.NoError(t, dc.SetRole(context.Background(), "admin"))
+	require.NoError(t, dc.UseWarehouse(context.Background(), "wh1"))
+	require.NoError(t, dc.rest.SetSecondaryRoles(context.Background(), nil))
+	_, err = dc.exec(context.Background(), "USE db2")
+	require.NoError(t, err)
+	state := dc.rest.getSessionState()
+	assert.Equal(t, "admin", state.Role)
+	assert.Equal(t, "db2", state.Database)
+
+	require.NoError(t, dc.ResetSession(context.Background()))
+	state = dc.rest.getSessionState()
+	assert.Equal(t, "reader", state.Role)
+	assert.Equal(t, []string{}, *state.SecondaryRoles)
+	assert.Equal(t, "default", state.Database)
+	assert.Empty(t, state.Settings)
+	assert.Equal(t, "wh0", dc.rest.makeCommonHeaders(context.Background()).Get(DatabendWarehouseHeader))
+}